@@ -0,0 +1,552 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+)
+
+// maxBinaryRecord limits the length of one record to refuse corrupted input instead of allocating its length.
+const maxBinaryRecord = 64 << 20
+
+var (
+	// Errors returned when failure occurs
+	errNotCorrectBinaryHeader = errors.New("input doesn't start with the binary header")
+	errNotCorrectBinaryRecord = errors.New("binary record is corrupted")
+)
+
+// BinaryDecoder type is behaviour structure that implements Decoder interface and supports decoding of the binary
+// writer output (see write.BinaryWriter for the description of the format).
+type BinaryDecoder struct {
+	r       *bufio.Reader
+	o       Options
+	header  bool
+	version uint64
+	err     error
+}
+
+// NewBinaryDecoder creates new decoder with the implementation of BinaryDecoder. All records of the input have to be
+// of the file type given by options, otherwise the wrong file type error is returned. The header of the input is
+// checked by the first decoding, inputs created by a newer version of the writer are refused.
+func NewBinaryDecoder(reader io.Reader, options *Options) Decoder {
+	d := &BinaryDecoder{}
+
+	if reader == nil {
+		d.err = errReaderIsNull
+	}
+
+	if options == nil {
+		options = &Options{}
+	}
+	d.SetOptions(*options)
+
+	d.r = bufio.NewReader(reader)
+	return d
+}
+
+// Error provides the state error.
+func (b *BinaryDecoder) Error() error {
+	return b.err
+}
+
+// Options returns options from binary decoder.
+func (b *BinaryDecoder) Options() Options {
+	return b.o
+}
+
+// SetOptions sets new options
+func (b *BinaryDecoder) SetOptions(opt Options) {
+	b.o = defaultOptions(opt)
+}
+
+// Version returns the version of the format read from the header, zero is returned before the first decoding.
+func (b *BinaryDecoder) Version() int {
+	return int(b.version)
+}
+
+// Decode function parses data and saves the result into the writer, the same way as the XMLDecoder does.
+func (b *BinaryDecoder) Decode(w write.Writer) error {
+	if b.err != nil {
+		return b.err
+	}
+
+	b.err = decode(b, w)
+	return b.err
+}
+
+// Artists function performs decoding the artist items from provided binary input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (b *BinaryDecoder) Artists() (int, []model.Artist, error) {
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+
+	var artists []model.Artist
+	for len(artists) < b.o.Block.ItemSize {
+		var r *binaryRecord
+		r, b.err = b.next(write.BinaryArtist)
+		if b.err != nil {
+			break
+		}
+
+		// the partial record of the corrupted input is dropped
+		item := r.artist()
+		if b.err = r.err; b.err != nil {
+			break
+		}
+
+		artists = append(artists, item)
+	}
+
+	if b.err == nil || b.err == io.EOF {
+		artists = b.o.QualityLevel.filterArtists(artists)
+	}
+	return len(artists), artists, b.err
+}
+
+// Labels function performs decoding the label items from provided binary input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (b *BinaryDecoder) Labels() (int, []model.Label, error) {
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+
+	var labels []model.Label
+	for len(labels) < b.o.Block.ItemSize {
+		var r *binaryRecord
+		r, b.err = b.next(write.BinaryLabel)
+		if b.err != nil {
+			break
+		}
+
+		// the partial record of the corrupted input is dropped
+		item := r.label()
+		if b.err = r.err; b.err != nil {
+			break
+		}
+
+		labels = append(labels, item)
+	}
+
+	if b.err == nil || b.err == io.EOF {
+		labels = b.o.QualityLevel.filterLabels(labels)
+	}
+	return len(labels), labels, b.err
+}
+
+// Masters function performs decoding the master items from provided binary input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (b *BinaryDecoder) Masters() (int, []model.Master, error) {
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+
+	var masters []model.Master
+	for len(masters) < b.o.Block.ItemSize {
+		var r *binaryRecord
+		r, b.err = b.next(write.BinaryMaster)
+		if b.err != nil {
+			break
+		}
+
+		// the partial record of the corrupted input is dropped
+		item := r.master()
+		if b.err = r.err; b.err != nil {
+			break
+		}
+
+		masters = append(masters, item)
+	}
+
+	if b.err == nil || b.err == io.EOF {
+		masters = b.o.QualityLevel.filterMasters(masters)
+	}
+	return len(masters), masters, b.err
+}
+
+// Releases function performs decoding the release items from provided binary input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (b *BinaryDecoder) Releases() (int, []model.Release, error) {
+	if b.err != nil {
+		return 0, nil, b.err
+	}
+
+	var releases []model.Release
+	for len(releases) < b.o.Block.ItemSize {
+		var r *binaryRecord
+		r, b.err = b.next(write.BinaryRelease)
+		if b.err != nil {
+			break
+		}
+
+		// the partial record of the corrupted input is dropped
+		item := r.release()
+		if b.err = r.err; b.err != nil {
+			break
+		}
+
+		releases = append(releases, item)
+	}
+
+	if b.err == nil || b.err == io.EOF {
+		releases = b.o.QualityLevel.filterReleases(releases)
+	}
+	return len(releases), releases, b.err
+}
+
+//--------------------------------------------------- Helpers ---------------------------------------------------
+
+// next reads the next record of the kind. The end of the input results in io.EOF, the input ending in the middle
+// of a record results in io.ErrUnexpectedEOF.
+func (b *BinaryDecoder) next(kind byte) (*binaryRecord, error) {
+	if !b.header {
+		err := b.readHeader()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	k, err := b.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	if k != kind {
+		return nil, errWrongTypeSpecified
+	}
+
+	length, err := binary.ReadUvarint(b.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if length > maxBinaryRecord {
+		return nil, errNotCorrectBinaryRecord
+	}
+
+	r := &binaryRecord{b: make([]byte, length)}
+	_, err = io.ReadFull(b.r, r.b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return r, err
+}
+
+// readHeader checks the magic string and the version of the format.
+func (b *BinaryDecoder) readHeader() error {
+	magic := make([]byte, len(write.BinaryMagic))
+	_, err := io.ReadFull(b.r, magic)
+	if err == io.EOF {
+		return err
+	}
+
+	if err != nil || string(magic) != write.BinaryMagic {
+		return errNotCorrectBinaryHeader
+	}
+
+	b.version, err = binary.ReadUvarint(b.r)
+	if err != nil {
+		return errNotCorrectBinaryHeader
+	}
+
+	if b.version == 0 || b.version > write.BinaryVersion {
+		return fmt.Errorf("binary format version %d is not supported", b.version)
+	}
+
+	b.header = true
+	return nil
+}
+
+// binaryRecord reads fields of one record in the order they were written by the binary writer. The first failure
+// is kept and all following reads return zero values.
+type binaryRecord struct {
+	b   []byte
+	err error
+}
+
+func (r *binaryRecord) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	x, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errNotCorrectBinaryRecord
+		return 0
+	}
+
+	r.b = r.b[n:]
+	return x
+}
+
+func (r *binaryRecord) string() string {
+	n := r.uvarint()
+	if r.err != nil {
+		return ""
+	}
+
+	if n > uint64(len(r.b)) {
+		r.err = errNotCorrectBinaryRecord
+		return ""
+	}
+
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+// count reads the number of items, the nil slice is reported by the second value. Each item takes at least one byte,
+// thus the count can't be greater than the remaining length.
+func (r *binaryRecord) count() (int, bool) {
+	n := r.uvarint()
+	if r.err != nil || n == 0 {
+		return 0, true
+	}
+
+	if n-1 > uint64(len(r.b)) {
+		r.err = errNotCorrectBinaryRecord
+		return 0, true
+	}
+
+	return int(n - 1), false
+}
+
+func (r *binaryRecord) strings() []string {
+	n, null := r.count()
+	if null {
+		return nil
+	}
+
+	ss := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ss = append(ss, r.string())
+	}
+
+	return ss
+}
+
+func (r *binaryRecord) artist() model.Artist {
+	a := model.Artist{
+		ID:             r.string(),
+		Name:           r.string(),
+		RealName:       r.string(),
+		Images:         r.images(),
+		Profile:        r.string(),
+		DataQuality:    r.string(),
+		NameVariations: r.strings(),
+		Urls:           r.strings(),
+	}
+
+	if n, null := r.count(); !null {
+		a.Aliases = make([]model.Alias, 0, n)
+		for i := 0; i < n; i++ {
+			a.Aliases = append(a.Aliases, model.Alias{ID: r.string(), Name: r.string()})
+		}
+	}
+
+	if n, null := r.count(); !null {
+		a.Members = make([]model.Member, 0, n)
+		for i := 0; i < n; i++ {
+			a.Members = append(a.Members, model.Member{ID: r.string(), Name: r.string()})
+		}
+	}
+
+	return a
+}
+
+func (r *binaryRecord) label() model.Label {
+	l := model.Label{
+		ID:          r.string(),
+		Name:        r.string(),
+		Images:      r.images(),
+		ContactInfo: r.string(),
+		Profile:     r.string(),
+		DataQuality: r.string(),
+		Urls:        r.strings(),
+	}
+
+	if _, null := r.count(); !null {
+		l.ParentLabel = &model.LabelLabel{ID: r.string(), Name: r.string()}
+	}
+
+	if n, null := r.count(); !null {
+		l.SubLabels = make([]model.LabelLabel, 0, n)
+		for i := 0; i < n; i++ {
+			l.SubLabels = append(l.SubLabels, model.LabelLabel{ID: r.string(), Name: r.string()})
+		}
+	}
+
+	return l
+}
+
+func (r *binaryRecord) master() model.Master {
+	return model.Master{
+		ID:          r.string(),
+		MainRelease: r.string(),
+		Images:      r.images(),
+		Artists:     r.releaseArtists(),
+		Genres:      r.strings(),
+		Styles:      r.strings(),
+		Year:        r.string(),
+		Title:       r.string(),
+		DataQuality: r.string(),
+		Videos:      r.videos(),
+	}
+}
+
+func (r *binaryRecord) release() model.Release {
+	rel := model.Release{
+		ID:           r.string(),
+		Status:       r.string(),
+		Images:       r.images(),
+		Artists:      r.releaseArtists(),
+		ExtraArtists: r.releaseArtists(),
+		Title:        r.string(),
+	}
+
+	if n, null := r.count(); !null {
+		rel.Formats = make([]model.Format, 0, n)
+		for i := 0; i < n; i++ {
+			rel.Formats = append(rel.Formats, model.Format{
+				Name:         r.string(),
+				Quantity:     r.string(),
+				Text:         r.string(),
+				Descriptions: r.strings(),
+			})
+		}
+	}
+
+	rel.Genres = r.strings()
+	rel.Styles = r.strings()
+	rel.Country = r.string()
+	rel.Released = r.string()
+	rel.Notes = r.string()
+	rel.DataQuality = r.string()
+	rel.MasterID = r.string()
+	rel.MainRelease = r.string()
+
+	if n, null := r.count(); !null {
+		rel.TrackList = make([]model.Track, 0, n)
+		for i := 0; i < n; i++ {
+			rel.TrackList = append(rel.TrackList, model.Track{Position: r.string(), Title: r.string(), Duration: r.string()})
+		}
+	}
+
+	if n, null := r.count(); !null {
+		rel.Identifiers = make([]model.Identifier, 0, n)
+		for i := 0; i < n; i++ {
+			rel.Identifiers = append(rel.Identifiers, model.Identifier{
+				Description: r.string(),
+				Type:        r.string(),
+				Value:       r.string(),
+			})
+		}
+	}
+
+	rel.Videos = r.videos()
+
+	if n, null := r.count(); !null {
+		rel.Labels = make([]model.ReleaseLabel, 0, n)
+		for i := 0; i < n; i++ {
+			rel.Labels = append(rel.Labels, model.ReleaseLabel{ID: r.string(), Name: r.string(), Category: r.string()})
+		}
+	}
+
+	if n, null := r.count(); !null {
+		rel.Companies = make([]model.Company, 0, n)
+		for i := 0; i < n; i++ {
+			rel.Companies = append(rel.Companies, model.Company{
+				ID:             r.string(),
+				Name:           r.string(),
+				Category:       r.string(),
+				EntityType:     r.string(),
+				EntityTypeName: r.string(),
+				ResourceURL:    r.string(),
+			})
+		}
+	}
+
+	return rel
+}
+
+func (r *binaryRecord) releaseArtists() []model.ReleaseArtist {
+	n, null := r.count()
+	if null {
+		return nil
+	}
+
+	artists := make([]model.ReleaseArtist, 0, n)
+	for i := 0; i < n; i++ {
+		artists = append(artists, model.ReleaseArtist{
+			ID:     r.string(),
+			Name:   r.string(),
+			Join:   r.string(),
+			Anv:    r.string(),
+			Role:   r.string(),
+			Tracks: r.string(),
+		})
+	}
+
+	return artists
+}
+
+func (r *binaryRecord) images() []model.Image {
+	n, null := r.count()
+	if null {
+		return nil
+	}
+
+	images := make([]model.Image, 0, n)
+	for i := 0; i < n; i++ {
+		images = append(images, model.Image{
+			Height: r.string(),
+			Width:  r.string(),
+			Type:   r.string(),
+			URI:    r.string(),
+			URI150: r.string(),
+		})
+	}
+
+	return images
+}
+
+func (r *binaryRecord) videos() []model.Video {
+	n, null := r.count()
+	if null {
+		return nil
+	}
+
+	videos := make([]model.Video, 0, n)
+	for i := 0; i < n; i++ {
+		videos = append(videos, model.Video{
+			Duration:    r.string(),
+			Embed:       r.string(),
+			Src:         r.string(),
+			Title:       r.string(),
+			Description: r.string(),
+		})
+	}
+
+	return videos
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"bytes"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewBinaryDecoder(t *testing.T) {
+	d := NewBinaryDecoder(nil, nil)
+	if d.Error() != errReaderIsNull {
+		t.Errorf("there should be an error %v", errReaderIsNull)
+	}
+}
+
+func TestBinaryDecoder_Artists(t *testing.T) {
+	_, expected, _ := NewXMLDecoder(strings.NewReader(artists), nil).Artists()
+	b := &bytes.Buffer{}
+	_ = write.NewBinaryWriter(b, nil).WriteArtists(expected)
+
+	_, got, err := NewBinaryDecoder(b, nil).Artists()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("decoded artists differ from written artists\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestBinaryDecoder_Labels(t *testing.T) {
+	_, expected, _ := NewXMLDecoder(strings.NewReader(labels), nil).Labels()
+	b := &bytes.Buffer{}
+	_ = write.NewBinaryWriter(b, nil).WriteLabels(expected)
+
+	_, got, err := NewBinaryDecoder(b, nil).Labels()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("decoded labels differ from written labels\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestBinaryDecoder_Masters(t *testing.T) {
+	_, expected, _ := NewXMLDecoder(strings.NewReader(masters), nil).Masters()
+	b := &bytes.Buffer{}
+	_ = write.NewBinaryWriter(b, nil).WriteMasters(expected)
+
+	_, got, err := NewBinaryDecoder(b, nil).Masters()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("decoded masters differ from written masters\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestBinaryDecoder_Releases(t *testing.T) {
+	expected := xmlReleases(t)
+	b := &bytes.Buffer{}
+	w := write.NewBinaryWriter(b, nil)
+	for _, r := range expected {
+		_ = w.WriteRelease(r)
+	}
+
+	d := NewBinaryDecoder(b, &Options{Block: Block{ItemSize: 3}})
+	var got []model.Release
+	for {
+		_, rs, err := d.Releases()
+		got = append(got, rs...)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("decoded releases differ from written releases\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestBinaryDecoder_Decode(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := NewXMLDecoder(strings.NewReader(releases), &Options{FileType: Releases}).Decode(write.NewBinaryWriter(b, nil))
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	got := &bytes.Buffer{}
+	err = NewBinaryDecoder(b, &Options{FileType: Releases}).Decode(write.NewJSONWriter(got, nil))
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	expected := &bytes.Buffer{}
+	_ = NewXMLDecoder(strings.NewReader(releases), &Options{FileType: Releases}).Decode(write.NewJSONWriter(expected, nil))
+	if got.String() != expected.String() {
+		t.Errorf("decoded releases should be written the same way\n%s\n%s", got, expected)
+	}
+}
+
+func TestBinaryDecoder_Empty(t *testing.T) {
+	b := &bytes.Buffer{}
+	_ = write.Open(write.NewBinaryWriter(b, nil))
+
+	d := NewBinaryDecoder(b, nil).(*BinaryDecoder)
+	num, _, err := d.Labels()
+	if num != 0 || err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if d.Version() != write.BinaryVersion {
+		t.Errorf("version %d should be read from the header", d.Version())
+	}
+}
+
+func TestBinaryDecoder_Invalid(t *testing.T) {
+	b := &bytes.Buffer{}
+	_ = write.NewBinaryWriter(b, nil).WriteMasters([]model.Master{{ID: "1", Title: "Title"}})
+	data := b.Bytes()
+
+	tests := []struct {
+		name  string
+		input []byte
+		err   error
+	}{
+		{name: "header", input: []byte("<masters>"), err: errNotCorrectBinaryHeader},
+		{name: "kind", input: append([]byte(write.BinaryMagic+"\x01"), write.BinaryRelease, 0), err: errWrongTypeSpecified},
+		{name: "truncated", input: data[:len(data)-2], err: io.ErrUnexpectedEOF},
+		{name: "corrupted", input: append([]byte(write.BinaryMagic+"\x01"), write.BinaryMaster, 1, 9),
+			err: errNotCorrectBinaryRecord},
+	}
+
+	for _, tt := range tests {
+		_, _, err := NewBinaryDecoder(bytes.NewReader(tt.input), nil).Masters()
+		if err != tt.err {
+			t.Errorf("%s: there should be an error %v instead of %v", tt.name, tt.err, err)
+		}
+	}
+
+	// the valid record is kept, the partial one is dropped
+	input := append(append([]byte(nil), data...), write.BinaryMaster, 1, 9)
+	n, ms, err := NewBinaryDecoder(bytes.NewReader(input), nil).Masters()
+	if err != errNotCorrectBinaryRecord || n != 1 || len(ms) != 1 || ms[0].ID != "1" {
+		t.Errorf("only the valid master should be decoded, got %d %+v %v", n, ms, err)
+	}
+
+	_, _, err = NewBinaryDecoder(strings.NewReader(write.BinaryMagic+"\x02"), nil).Masters()
+	if err == nil || err == io.EOF {
+		t.Error("newer version of the format should be refused")
+	}
+}
@@ -0,0 +1,601 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+	"strings"
+)
+
+var (
+	// Errors returned when failure occurs
+	errDBIsNull = errors.New("database is null")
+)
+
+// DBDecoder type is behaviour structure that implements Decoder interface and supports decoding of data stored
+// by the DB writer in tables created by the tables.sql script in the sql_scripts folder. Entities are rebuilt
+// including all their child rows (images, aliases, tracks, etc.), thus the stored data can be processed again without
+// the original XML dump.
+type DBDecoder struct {
+	db   *sql.DB
+	o    Options
+	wo   write.Options
+	last string
+	err  error
+}
+
+// NewDBDecoder creates new decoder with the implementation of DBDecoder. Entities of the file type given by options
+// are read in the order of their IDs (compared as text), each block by one query of the main entity table and one
+// query per child table. Child rows are read in the order they were inserted, which is the order of ctid
+// in PostgreSQL and rowid in SQLite (see the writer Dialect option), thus collections keep their original order.
+// MySQL tables without a primary key return rows in the insertion order.
+//
+// Writer options are options of the writer the data were written by, nil means default ones. Tables and columns are
+// read by names given by Names options (schema, prefix and mapping functions) and, when the Documents mode is
+// enabled, entities are decoded from JSON documents of document tables instead of rows of relational tables. Other
+// writer options don't affect reading.
+func NewDBDecoder(db *sql.DB, options *Options, writerOptions *write.Options) Decoder {
+	d := &DBDecoder{}
+
+	if db == nil {
+		d.err = errDBIsNull
+	}
+
+	if options == nil {
+		options = &Options{}
+	}
+	d.SetOptions(*options)
+
+	if writerOptions != nil {
+		d.wo = *writerOptions
+	}
+
+	d.db = db
+	return d
+}
+
+// Error provides the state error.
+func (d *DBDecoder) Error() error {
+	return d.err
+}
+
+// Options returns options from DB decoder.
+func (d *DBDecoder) Options() Options {
+	return d.o
+}
+
+// SetOptions sets new options
+func (d *DBDecoder) SetOptions(opt Options) {
+	d.o = defaultOptions(opt)
+}
+
+// Decode function reads data and saves the result into the writer, the same way as the XMLDecoder does.
+func (d *DBDecoder) Decode(w write.Writer) error {
+	if d.err != nil {
+		return d.err
+	}
+
+	d.err = decode(d, w)
+	return d.err
+}
+
+// Artists function performs reading the artist items from the database and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of read and filtered items, slice of items and possible error, when occurs.
+func (d *DBDecoder) Artists() (int, []model.Artist, error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+
+	artists := d.readArtists()
+	if d.err == nil || d.err == io.EOF {
+		artists = d.o.QualityLevel.filterArtists(artists)
+	}
+	return len(artists), artists, d.err
+}
+
+// Labels function performs reading the label items from the database and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of read and filtered items, slice of items and possible error, when occurs.
+func (d *DBDecoder) Labels() (int, []model.Label, error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+
+	labels := d.readLabels()
+	if d.err == nil || d.err == io.EOF {
+		labels = d.o.QualityLevel.filterLabels(labels)
+	}
+	return len(labels), labels, d.err
+}
+
+// Masters function performs reading the master items from the database and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of read and filtered items, slice of items and possible error, when occurs.
+func (d *DBDecoder) Masters() (int, []model.Master, error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+
+	masters := d.readMasters()
+	if d.err == nil || d.err == io.EOF {
+		masters = d.o.QualityLevel.filterMasters(masters)
+	}
+	return len(masters), masters, d.err
+}
+
+// Releases function performs reading the release items from the database and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of read and filtered items, slice of items and possible error, when occurs.
+func (d *DBDecoder) Releases() (int, []model.Release, error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+
+	releases := d.readReleases()
+	if d.err == nil || d.err == io.EOF {
+		releases = d.o.QualityLevel.filterReleases(releases)
+	}
+	return len(releases), releases, d.err
+}
+
+//--------------------------------------------------- Helpers ---------------------------------------------------
+
+// query executes the query and calls the function with values of each row, NULL values are empty strings.
+func (d *DBDecoder) query(query string, fn func(values []string)) {
+	if d.err != nil {
+		return
+	}
+
+	rows, err := d.db.Query(query)
+	if err != nil {
+		d.err = err
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		d.err = err
+		return
+	}
+
+	ns := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range ns {
+		dest[i] = &ns[i]
+	}
+
+	values := make([]string, len(columns))
+	for rows.Next() {
+		err = rows.Scan(dest...)
+		if err != nil {
+			d.err = err
+			return
+		}
+
+		for i, n := range ns {
+			values[i] = n.String
+		}
+
+		fn(values)
+	}
+
+	d.err = rows.Err()
+}
+
+// table returns the name of the table according to Names options of the writer.
+func (d *DBDecoder) table(name string) string {
+	n := d.wo.Names
+	if n.Table != nil {
+		name = n.Table(name)
+	}
+
+	name = n.Prefix + name
+	if n.Schema != "" {
+		name = n.Schema + "." + name
+	}
+
+	return name
+}
+
+// columns returns the comma separated list of columns with names according to Names options of the writer.
+func (d *DBDecoder) columns(columns string) string {
+	if d.wo.Names.Column == nil {
+		return columns
+	}
+
+	cols := strings.Split(columns, ", ")
+	for i, c := range cols {
+		cols[i] = d.wo.Names.Column(c)
+	}
+
+	return strings.Join(cols, ", ")
+}
+
+// readBlock reads the next block of main entity rows ordered by the key column. The end of the table results
+// in io.EOF.
+func (d *DBDecoder) readBlock(table, key, columns string, fn func(values []string)) {
+	cnt := 0
+	key = d.columns(key)
+	d.query(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s > '%s' ORDER BY %s LIMIT %d", key, d.columns(columns),
+		d.table(table), key, quote(d.last), key, d.o.Block.ItemSize), func(values []string) {
+		d.last = values[0]
+		cnt++
+		fn(values)
+	})
+
+	if d.err == nil && cnt < d.o.Block.ItemSize {
+		d.err = io.EOF
+	}
+}
+
+// readDocuments reads the next block of documents of the document table ordered by the ID, each document is decoded
+// by the function.
+func (d *DBDecoder) readDocuments(table string, fn func(doc []byte) error) {
+	var err error
+	d.readBlock(table, "id", "doc", func(values []string) {
+		if err == nil {
+			err = fn([]byte(values[1]))
+		}
+	})
+
+	if err != nil {
+		d.err = err
+	}
+}
+
+// readChildren reads rows of the child table belonging to entities with IDs in the order they were inserted.
+// The entity ID is the first value.
+func (d *DBDecoder) readChildren(table, column, columns string, ids []string, fn func(values []string)) {
+	if len(ids) == 0 || (d.err != nil && d.err != io.EOF) {
+		return
+	}
+
+	in := make([]string, 0, len(ids))
+	for _, id := range ids {
+		in = append(in, "'"+quote(id)+"'")
+	}
+
+	// the end of the main table must not stop reading of child rows
+	err := d.err
+	d.err = nil
+	column = d.columns(column)
+	d.query(fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IN (%s)%s", column, d.columns(columns), d.table(table),
+		column, strings.Join(in, ", "), d.insertionOrder()), fn)
+
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// insertionOrder returns the ORDER BY clause sorting rows by their physical location according to the writer Dialect.
+// MySQL has no such column, but rows of tables without a primary key are read in the order they were inserted.
+func (d *DBDecoder) insertionOrder() string {
+	switch d.wo.Dialect {
+	case write.MySQL:
+		return ""
+	case write.SQLite:
+		return " ORDER BY rowid"
+	default:
+		return " ORDER BY ctid"
+	}
+}
+
+func quote(str string) string {
+	return strings.ReplaceAll(str, "'", "''")
+}
+
+// parseArray parses the PostgreSQL array literal, such as {a,"b c"}, or the JSON array stored in MySQL. Empty values
+// result in nil, the same as arrays with one empty element, which is how DB and SQL writers store empty slices.
+func parseArray(str string) []string {
+	if strings.HasPrefix(str, "[") {
+		var values []string
+		if json.Unmarshal([]byte(str), &values) != nil || len(values) == 0 {
+			return nil
+		}
+
+		return values
+	}
+
+	str = strings.TrimPrefix(strings.TrimSuffix(str, "}"), "{")
+	if str == "" || str == `""` {
+		return nil
+	}
+
+	var values []string
+	sb := strings.Builder{}
+	quoted, escaped := false, false
+	for _, r := range str {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			values = append(values, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	return append(values, sb.String())
+}
+
+const (
+	imageColumns         = "height, width, type, uri, uri_150"
+	releaseArtistColumns = "release_artist_id, name, extra, joiner, anv, role, tracks"
+	videoColumns         = "duration, embed, src, title, description"
+)
+
+func image(v []string) model.Image {
+	return model.Image{Height: v[1], Width: v[2], Type: v[3], URI: v[4], URI150: v[5]}
+}
+
+func releaseArtist(v []string) model.ReleaseArtist {
+	return model.ReleaseArtist{ID: v[1], Name: v[2], Join: v[4], Anv: v[5], Role: v[6], Tracks: v[7]}
+}
+
+func video(v []string) model.Video {
+	return model.Video{Duration: v[1], Embed: v[2], Src: v[3], Title: v[4], Description: v[5]}
+}
+
+//=================================================== Readers ===================================================
+
+//--------------------------------------------------- Artist ---------------------------------------------------
+
+func (d *DBDecoder) readArtists() (artists []model.Artist) {
+	if d.wo.Documents.Enabled {
+		d.readDocuments("artists", func(doc []byte) error {
+			a := model.Artist{}
+			err := json.Unmarshal(doc, &a)
+			artists = append(artists, a)
+			return err
+		})
+
+		return artists
+	}
+
+	index := make(map[string]int)
+	d.readBlock("artists", "artist_id", "name, real_name, profile, data_quality, name_variations, urls",
+		func(v []string) {
+			index[v[0]] = len(artists)
+			artists = append(artists, model.Artist{
+				ID:             v[0],
+				Name:           v[1],
+				RealName:       v[2],
+				Profile:        v[3],
+				DataQuality:    v[4],
+				NameVariations: parseArray(v[5]),
+				Urls:           parseArray(v[6]),
+			})
+		})
+
+	ids := make([]string, 0, len(artists))
+	for _, a := range artists {
+		ids = append(ids, a.ID)
+	}
+
+	d.readChildren("images", "artist_id", imageColumns, ids, func(v []string) {
+		a := &artists[index[v[0]]]
+		a.Images = append(a.Images, image(v))
+	})
+
+	d.readChildren("artist_aliases", "artist_id", "alias_id, name", ids, func(v []string) {
+		a := &artists[index[v[0]]]
+		a.Aliases = append(a.Aliases, model.Alias{ID: v[1], Name: v[2]})
+	})
+
+	d.readChildren("artist_members", "artist_id", "member_id, name", ids, func(v []string) {
+		a := &artists[index[v[0]]]
+		a.Members = append(a.Members, model.Member{ID: v[1], Name: v[2]})
+	})
+
+	return artists
+}
+
+//--------------------------------------------------- Label ---------------------------------------------------
+
+func (d *DBDecoder) readLabels() (labels []model.Label) {
+	if d.wo.Documents.Enabled {
+		d.readDocuments("labels", func(doc []byte) error {
+			l := model.Label{}
+			err := json.Unmarshal(doc, &l)
+			labels = append(labels, l)
+			return err
+		})
+
+		return labels
+	}
+
+	index := make(map[string]int)
+	d.readBlock("labels", "label_id", "name, contact_info, profile, data_quality, urls", func(v []string) {
+		index[v[0]] = len(labels)
+		labels = append(labels, model.Label{
+			ID:          v[0],
+			Name:        v[1],
+			ContactInfo: v[2],
+			Profile:     v[3],
+			DataQuality: v[4],
+			Urls:        parseArray(v[5]),
+		})
+	})
+
+	ids := make([]string, 0, len(labels))
+	for _, l := range labels {
+		ids = append(ids, l.ID)
+	}
+
+	d.readChildren("images", "label_id", imageColumns, ids, func(v []string) {
+		l := &labels[index[v[0]]]
+		l.Images = append(l.Images, image(v))
+	})
+
+	d.readChildren("label_labels", "label_id", "sub_label_id, name, parent", ids, func(v []string) {
+		l := &labels[index[v[0]]]
+		ll := model.LabelLabel{ID: v[1], Name: v[2]}
+		if v[3] == "true" {
+			l.ParentLabel = &ll
+		} else {
+			l.SubLabels = append(l.SubLabels, ll)
+		}
+	})
+
+	return labels
+}
+
+//--------------------------------------------------- Master ---------------------------------------------------
+
+func (d *DBDecoder) readMasters() (masters []model.Master) {
+	if d.wo.Documents.Enabled {
+		d.readDocuments("masters", func(doc []byte) error {
+			m := model.Master{}
+			err := json.Unmarshal(doc, &m)
+			masters = append(masters, m)
+			return err
+		})
+
+		return masters
+	}
+
+	index := make(map[string]int)
+	d.readBlock("masters", "master_id", "main_release, genres, styles, year, title, data_quality",
+		func(v []string) {
+			index[v[0]] = len(masters)
+			masters = append(masters, model.Master{
+				ID:          v[0],
+				MainRelease: v[1],
+				Genres:      parseArray(v[2]),
+				Styles:      parseArray(v[3]),
+				Year:        v[4],
+				Title:       v[5],
+				DataQuality: v[6],
+			})
+		})
+
+	ids := make([]string, 0, len(masters))
+	for _, m := range masters {
+		ids = append(ids, m.ID)
+	}
+
+	d.readChildren("images", "master_id", imageColumns, ids, func(v []string) {
+		m := &masters[index[v[0]]]
+		m.Images = append(m.Images, image(v))
+	})
+
+	d.readChildren("release_artists", "master_id", releaseArtistColumns, ids, func(v []string) {
+		m := &masters[index[v[0]]]
+		m.Artists = append(m.Artists, releaseArtist(v))
+	})
+
+	d.readChildren("videos", "master_id", videoColumns, ids, func(v []string) {
+		m := &masters[index[v[0]]]
+		m.Videos = append(m.Videos, video(v))
+	})
+
+	return masters
+}
+
+//--------------------------------------------------- Release ---------------------------------------------------
+
+func (d *DBDecoder) readReleases() (releases []model.Release) {
+	if d.wo.Documents.Enabled {
+		d.readDocuments("releases", func(doc []byte) error {
+			r := model.Release{}
+			err := json.Unmarshal(doc, &r)
+			releases = append(releases, r)
+			return err
+		})
+
+		return releases
+	}
+
+	index := make(map[string]int)
+	d.readBlock("releases", "release_id", "status, title, genres, styles, country, released, notes, data_quality, "+
+		"master_id, main_release", func(v []string) {
+		index[v[0]] = len(releases)
+		releases = append(releases, model.Release{
+			ID:          v[0],
+			Status:      v[1],
+			Title:       v[2],
+			Genres:      parseArray(v[3]),
+			Styles:      parseArray(v[4]),
+			Country:     v[5],
+			Released:    v[6],
+			Notes:       v[7],
+			DataQuality: v[8],
+			MasterID:    v[9],
+			MainRelease: v[10],
+		})
+	})
+
+	ids := make([]string, 0, len(releases))
+	for _, r := range releases {
+		ids = append(ids, r.ID)
+	}
+
+	d.readChildren("images", "release_id", imageColumns, ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Images = append(r.Images, image(v))
+	})
+
+	d.readChildren("release_artists", "release_id", releaseArtistColumns, ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		if v[3] == "true" {
+			r.ExtraArtists = append(r.ExtraArtists, releaseArtist(v))
+		} else {
+			r.Artists = append(r.Artists, releaseArtist(v))
+		}
+	})
+
+	d.readChildren("release_formats", "release_id", "name, quantity, text, descriptions", ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Formats = append(r.Formats, model.Format{Name: v[1], Quantity: v[2], Text: v[3],
+			Descriptions: parseArray(v[4])})
+	})
+
+	d.readChildren("release_tracks", "release_id", "position, title, duration", ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.TrackList = append(r.TrackList, model.Track{Position: v[1], Title: v[2], Duration: v[3]})
+	})
+
+	d.readChildren("release_identifiers", "release_id", "description, type, value", ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Identifiers = append(r.Identifiers, model.Identifier{Description: v[1], Type: v[2], Value: v[3]})
+	})
+
+	d.readChildren("release_labels", "release_id", "release_label_id, name, category", ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Labels = append(r.Labels, model.ReleaseLabel{ID: v[1], Name: v[2], Category: v[3]})
+	})
+
+	d.readChildren("release_companies", "release_id", "release_company_id, name, category, entity_type, "+
+		"entity_type_name, resource_url", ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Companies = append(r.Companies, model.Company{ID: v[1], Name: v[2], Category: v[3], EntityType: v[4],
+			EntityTypeName: v[5], ResourceURL: v[6]})
+	})
+
+	d.readChildren("videos", "release_id", videoColumns, ids, func(v []string) {
+		r := &releases[index[v[0]]]
+		r.Videos = append(r.Videos, video(v))
+	})
+
+	return releases
+}
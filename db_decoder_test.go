@@ -0,0 +1,246 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewDBDecoder(t *testing.T) {
+	d := NewDBDecoder(nil, nil, nil)
+	if d.Error() != errDBIsNull {
+		t.Errorf("there should be an error %v", errDBIsNull)
+	}
+}
+
+func TestDBDecoder_Artists(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	_, expected, _ := NewXMLDecoder(strings.NewReader(artists), nil).Artists()
+	_ = write.NewDBWriter(db, nil).WriteArtists(expected)
+
+	_, got, err := NewDBDecoder(db, nil, nil).Artists()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("read artists differ from written artists\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestDBDecoder_Labels(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	_, expected, _ := NewXMLDecoder(strings.NewReader(labels), nil).Labels()
+	_ = write.NewDBWriter(db, nil).WriteLabels(expected)
+
+	_, got, err := NewDBDecoder(db, nil, nil).Labels()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("read labels differ from written labels\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestDBDecoder_Masters(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	_, expected, _ := NewXMLDecoder(strings.NewReader(masters), nil).Masters()
+	_ = write.NewDBWriter(db, nil).WriteMasters(expected)
+
+	_, got, err := NewDBDecoder(db, nil, nil).Masters()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("read masters differ from written masters\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestDBDecoder_Masters_MySQL(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	wo := &write.Options{Dialect: write.MySQL}
+	_, expected, _ := NewXMLDecoder(strings.NewReader(masters), nil).Masters()
+	_ = write.NewDBWriter(db, wo).WriteMasters(expected)
+
+	_, got, _ := NewDBDecoder(db, nil, wo).Masters()
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if len(got) != len(expected) {
+		t.Fatalf("%d masters expected, got %d", len(expected), len(got))
+	}
+
+	for i, m := range got {
+		if !sameJSON(m.Genres, expected[i].Genres) || !sameJSON(m.Styles, expected[i].Styles) {
+			t.Errorf("arrays should be read from JSON values: %v %v", m.Genres, m.Styles)
+		}
+	}
+}
+
+func TestDBDecoder_Releases(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	expected := xmlReleases(t)
+	_ = write.NewDBWriter(db, nil).WriteReleases(expected)
+
+	d := NewDBDecoder(db, &Options{Block: Block{ItemSize: 1}}, nil)
+	var got []model.Release
+	for {
+		_, rs, err := d.Releases()
+		got = append(got, rs...)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("read releases differ from written releases\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestDBDecoder_Decode(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	_, rs, _ := NewXMLDecoder(strings.NewReader(releases), nil).Releases()
+	_ = write.NewDBWriter(db, nil).WriteReleases(rs)
+
+	var written []model.Release
+	w := write.Transform(discard{}, func(record interface{}) bool {
+		written = append(written, *record.(*model.Release))
+		return true
+	})
+
+	o := &Options{QualityLevel: CompleteAndCorrect, FileType: Releases, Block: Block{ItemSize: 1, Skip: 1}}
+	err := NewDBDecoder(db, o, nil).Decode(w)
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	for _, r := range written {
+		if r.DataQuality != "Complete and Correct" {
+			t.Errorf("release %s should be filtered out", r.ID)
+		}
+	}
+
+	queries := f.recorded()
+	if !strings.HasPrefix(queries[0], "SELECT release_id, status, title") ||
+		!strings.HasSuffix(queries[0], "FROM releases WHERE release_id > '' ORDER BY release_id LIMIT 1") {
+		t.Errorf("unexpected query %s", queries[0])
+	}
+}
+
+func TestDBDecoder_Releases_InsertionOrder(t *testing.T) {
+	for _, dialect := range []write.Dialect{write.PostgreSQL, write.SQLite} {
+		f, db := newFakeDB()
+
+		expected := xmlReleases(t)
+		_ = write.NewDBWriter(db, nil).WriteReleases(expected)
+
+		d := NewDBDecoder(db, nil, &write.Options{Dialect: dialect})
+		_, got, _ := d.Releases()
+		sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+		if !sameJSON(got, expected) {
+			t.Errorf("collections should keep the insertion order in dialect %d\n%+v\n%+v", dialect, got, expected)
+		}
+
+		if q := f.recorded(); !strings.HasSuffix(q[len(q)-1], map[write.Dialect]string{
+			write.PostgreSQL: " ORDER BY ctid", write.SQLite: " ORDER BY rowid"}[dialect]) {
+			t.Errorf("child rows should be ordered by the row location: %s", q[len(q)-1])
+		}
+
+		_ = db.Close()
+	}
+}
+
+func TestDBDecoder_WriterOptions_Names(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	wo := write.Options{Names: write.Names{
+		Prefix: "dump_",
+		Column: func(name string) string { return "c_" + name },
+	}}
+
+	_, expected, _ := NewXMLDecoder(strings.NewReader(labels), nil).Labels()
+	_ = write.NewDBWriter(db, &wo).WriteLabels(expected)
+
+	d := NewDBDecoder(db, nil, &wo)
+	_, got, err := d.Labels()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("labels should be read from mapped tables\n%+v\n%+v", got, expected)
+	}
+}
+
+func TestDBDecoder_WriterOptions_Documents(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	wo := write.Options{Documents: write.Documents{Enabled: true}}
+
+	_, expected, _ := NewXMLDecoder(strings.NewReader(artists), nil).Artists()
+	_ = write.NewDBWriter(db, &wo).WriteArtists(expected)
+
+	d := NewDBDecoder(db, nil, &wo)
+	_, got, err := d.Artists()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i].ID < expected[j].ID })
+	if !sameJSON(got, expected) {
+		t.Errorf("artists should be read from documents\n%+v\n%+v", got, expected)
+	}
+}
+
+// sameJSON compares values by their JSON encoding, thus nil and empty slices are considered the same as the database
+// doesn't distinguish them.
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// discard is the writer throwing away all written records.
+type discard struct{}
+
+func (discard) WriteArtist(model.Artist) error      { return nil }
+func (discard) WriteArtists([]model.Artist) error   { return nil }
+func (discard) WriteLabel(model.Label) error        { return nil }
+func (discard) WriteLabels([]model.Label) error     { return nil }
+func (discard) WriteMaster(model.Master) error      { return nil }
+func (discard) WriteMasters([]model.Master) error   { return nil }
+func (discard) WriteRelease(model.Release) error    { return nil }
+func (discard) WriteReleases([]model.Release) error { return nil }
+func (discard) Options() write.Options              { return write.Options{} }
@@ -5,8 +5,22 @@
 package discogs
 
 import (
+	"errors"
 	"github.com/lukasaron/data-discogs/model"
 	"github.com/lukasaron/data-discogs/write"
+	"io"
+	"log"
+)
+
+const (
+	defaultBlockSize  = 10
+	defaultBlockLimit = int(^uint(0) >> 1)
+)
+
+var (
+	// Errors returned when failure occurs
+	errReaderIsNull       = errors.New("reader is null")
+	errWrongTypeSpecified = errors.New("wrong file type specified")
 )
 
 // Decoder is the interface that wraps the basic decoding method.
@@ -105,3 +119,212 @@ type Options struct {
 	Block        Block        // Specifies the decoding Block values
 	FileType     FileType     // Identifies XML file type
 }
+
+//--------------------------------------------------- Decoders ---------------------------------------------------
+
+// decodeFunc decodes the next block and writes it into the writer when the write parameter is true. It returns the
+// number of decoded items.
+type decodeFunc func(w write.Writer, write bool) (int, error)
+
+// defaultOptions returns options with default values of the block settings, when they are not set.
+func defaultOptions(o Options) Options {
+	if o.Block.Limit < 1 {
+		o.Block.Limit = defaultBlockLimit
+	}
+
+	if o.Block.ItemSize < 1 {
+		o.Block.ItemSize = defaultBlockSize
+	}
+
+	if o.Block.Skip < 0 {
+		o.Block.Skip = 0
+	}
+
+	return o
+}
+
+// decode is the decoding loop shared by all decoders. It decodes blocks of the file type given by options by the
+// decoder and saves them into the writer, the writer lifecycle is driven as well (see XMLDecoder Decode function).
+// The returned error is io.EOF when the end of the input is reached.
+func decode(d Decoder, w write.Writer) error {
+	df, err := decodeFunction(d)
+	if err != nil {
+		return err
+	}
+
+	write.Skip(w, d.Options().Block.Skip)
+	err = write.Open(w)
+	if err != nil {
+		return err
+	}
+
+	err = decodeBlocks(d.Options().Block, w, df)
+
+	// finish the writer with the run error, the end of stream is not considered as an error
+	runErr := err
+	if runErr == io.EOF {
+		runErr = nil
+	}
+
+	fErr := write.Finish(w, runErr)
+	if fErr != nil && runErr == nil {
+		err = fErr
+	}
+
+	return err
+}
+
+func decodeBlocks(b Block, w write.Writer, df decodeFunc) (err error) {
+	var num int
+	for blockCount := 1; blockCount <= b.Limit; blockCount++ {
+		// call appropriate decoder function
+		num, err = df(w, blockCount > b.Skip)
+		// error occurs (not the end of stream)
+		if err != nil && err != io.EOF {
+			log.Printf("Block %d failed [%d]\n", blockCount, num)
+			return err
+		}
+
+		// no data anymore, end of stream
+		if num == 0 && err == io.EOF {
+			break
+		}
+
+		// we have data and no error (except end of stream)
+		if blockCount > b.Skip {
+			if fErr := write.Flush(w); fErr != nil {
+				log.Printf("Block %d failed [%d]\n", blockCount, num)
+				return fErr
+			}
+
+			log.Printf("Block %d written [%d]\n", blockCount, num)
+		} else {
+			log.Printf("Block %d skipped [%d]\n", blockCount, num)
+		}
+	}
+
+	return err
+}
+
+func decodeFunction(d Decoder) (decodeFunc, error) {
+	switch d.Options().FileType {
+	case Artists:
+		return decodeArtists(d), nil
+	case Labels:
+		return decodeLabels(d), nil
+	case Masters:
+		return decodeMasters(d), nil
+	case Releases:
+		return decodeReleases(d), nil
+	case Unknown:
+		fallthrough
+	default:
+		return nil, errWrongTypeSpecified
+	}
+}
+
+func decodeArtists(d Decoder) decodeFunc {
+	return func(w write.Writer, write bool) (int, error) {
+		num, a, err := d.Artists()
+		if (err != nil && err != io.EOF) || num == 0 {
+			return num, err
+		}
+
+		if write {
+			return num, w.WriteArtists(a)
+		}
+
+		return num, err
+	}
+}
+
+func decodeLabels(d Decoder) decodeFunc {
+	return func(w write.Writer, write bool) (int, error) {
+		num, l, err := d.Labels()
+		if (err != nil && err != io.EOF) || num == 0 {
+			return num, err
+		}
+
+		if write {
+			return num, w.WriteLabels(l)
+		}
+
+		return num, err
+	}
+}
+
+func decodeMasters(d Decoder) decodeFunc {
+	return func(w write.Writer, write bool) (int, error) {
+		num, m, err := d.Masters()
+		if (err != nil && err != io.EOF) || num == 0 {
+			return num, err
+		}
+
+		if write {
+			return num, w.WriteMasters(m)
+		}
+
+		return num, err
+	}
+}
+
+func decodeReleases(d Decoder) decodeFunc {
+	return func(w write.Writer, write bool) (int, error) {
+		num, r, err := d.Releases()
+		if (err != nil && err != io.EOF) || num == 0 {
+			return num, err
+		}
+
+		if write {
+			return num, w.WriteReleases(r)
+		}
+
+		return num, err
+	}
+}
+
+//--------------------------------------------------- FILTERS ---------------------------------------------------
+
+func (ql QualityLevel) filterArtists(as []model.Artist) []model.Artist {
+	fa := make([]model.Artist, 0, len(as))
+	for _, a := range as {
+		if ql.Includes(ToQualityLevel(a.DataQuality)) {
+			fa = append(fa, a)
+		}
+	}
+
+	return fa
+}
+
+func (ql QualityLevel) filterLabels(ls []model.Label) []model.Label {
+	fl := make([]model.Label, 0, len(ls))
+	for _, l := range ls {
+		if ql.Includes(ToQualityLevel(l.DataQuality)) {
+			fl = append(fl, l)
+		}
+	}
+
+	return fl
+}
+
+func (ql QualityLevel) filterMasters(ms []model.Master) []model.Master {
+	fm := make([]model.Master, 0, len(ms))
+	for _, m := range ms {
+		if ql.Includes(ToQualityLevel(m.DataQuality)) {
+			fm = append(fm, m)
+		}
+	}
+
+	return fm
+}
+
+func (ql QualityLevel) filterReleases(rs []model.Release) []model.Release {
+	fr := make([]model.Release, 0, len(rs))
+	for _, r := range rs {
+		if ql.Includes(ToQualityLevel(r.DataQuality)) {
+			fr = append(fr, r)
+		}
+	}
+
+	return fr
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// fakeDB is an in memory database/sql driver that understands insert commands created by the DB writer and queries
+// created by the DB decoder. Array values are stored as PostgreSQL array literals. Child rows are returned in the order
+// they were inserted only when they are ordered by ctid or rowid, otherwise they are returned in the reverse order.
+type fakeDB struct {
+	mu      sync.Mutex
+	tables  map[string]*fakeTable
+	queries []string
+}
+
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+var (
+	insertRegexp = regexp.MustCompile(`(?s)^INSERT INTO (\w+) \(([^)]*)\) VALUES (.*)$`)
+	blockRegexp  = regexp.MustCompile(`(?s)^SELECT (.+) FROM (\w+) WHERE (\w+) > '((?:[^']|'')*)' ORDER BY \w+ LIMIT (\d+)$`)
+	inRegexp     = regexp.MustCompile(`(?s)^SELECT (.+) FROM (\w+) WHERE (\w+) IN \((.*)\)(?: ORDER BY (ctid|rowid))?$`)
+)
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{tables: make(map[string]*fakeTable)}
+	return f, sql.OpenDB(fakeConnector{f: f})
+}
+
+func (f *fakeDB) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.queries...)
+}
+
+func (f *fakeDB) exec(query string) error {
+	m := insertRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return fmt.Errorf("unsupported command %s", query)
+	}
+
+	values, err := parseValues(m[3])
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t, ok := f.tables[m[1]]
+	if !ok {
+		t = &fakeTable{columns: strings.Split(m[2], ", ")}
+		f.tables[m[1]] = t
+	}
+
+	for len(values) > 0 {
+		t.rows = append(t.rows, values[:len(t.columns)])
+		values = values[len(t.columns):]
+	}
+
+	return nil
+}
+
+func (f *fakeDB) query(query string) (driver.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, query)
+	if m := blockRegexp.FindStringSubmatch(query); m != nil {
+		t := f.tables[m[2]]
+		key := t.index(m[3])
+		last := strings.ReplaceAll(m[4], "''", "'")
+		limit, _ := strconv.Atoi(m[5])
+
+		var rows [][]driver.Value
+		for _, r := range t.rows {
+			if r[key].(string) > last {
+				rows = append(rows, r)
+			}
+		}
+
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i][key].(string) < rows[j][key].(string) })
+		if len(rows) > limit {
+			rows = rows[:limit]
+		}
+
+		return t.selectRows(m[1], rows), nil
+	}
+
+	if m := inRegexp.FindStringSubmatch(query); m != nil {
+		t, ok := f.tables[m[2]]
+		if !ok {
+			return &fakeRows{columns: strings.Split(m[1], ", ")}, nil
+		}
+
+		in, err := parseValues("(" + m[4] + ")")
+		if err != nil {
+			return nil, err
+		}
+
+		key := t.index(m[3])
+		var rows [][]driver.Value
+		for _, r := range t.rows {
+			for _, v := range in {
+				if r[key] == v {
+					rows = append(rows, r)
+					break
+				}
+			}
+		}
+
+		if m[5] == "" {
+			for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+				rows[i], rows[j] = rows[j], rows[i]
+			}
+		}
+
+		return t.selectRows(m[1], rows), nil
+	}
+
+	return nil, fmt.Errorf("unsupported query %s", query)
+}
+
+func (t *fakeTable) index(column string) int {
+	for i, c := range t.columns {
+		if c == column {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (t *fakeTable) selectRows(columns string, rows [][]driver.Value) *fakeRows {
+	fr := &fakeRows{columns: strings.Split(columns, ", ")}
+	for _, r := range rows {
+		values := make([]driver.Value, 0, len(fr.columns))
+		for _, c := range fr.columns {
+			values = append(values, r[t.index(c)])
+		}
+
+		fr.rows = append(fr.rows, values)
+	}
+
+	return fr
+}
+
+// parseValues parses SQL values of insert commands, such as ('a', ARRAY['b','c'], NULL), ('d', ...).
+func parseValues(str string) ([]driver.Value, error) {
+	var values []driver.Value
+	for i := 0; i < len(str); {
+		switch {
+		case str[i] == '(' || str[i] == ')' || str[i] == ',' || str[i] == ' ':
+			i++
+		case strings.HasPrefix(str[i:], "NULL"):
+			values = append(values, nil)
+			i += len("NULL")
+		case strings.HasPrefix(str[i:], "ARRAY["):
+			end := strings.Index(str[i:], "]")
+			elems, err := parseValues(str[i+len("ARRAY[") : i+end])
+			if err != nil {
+				return nil, err
+			}
+
+			quoted := make([]string, 0, len(elems))
+			for _, e := range elems {
+				quoted = append(quoted, strconv.Quote(e.(string)))
+			}
+
+			values = append(values, "{"+strings.Join(quoted, ",")+"}")
+			i += end + 1
+		case str[i] == '\'':
+			sb := strings.Builder{}
+			for i++; i < len(str); i++ {
+				if str[i] == '\'' {
+					if i+1 < len(str) && str[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+
+				sb.WriteByte(str[i])
+			}
+
+			values = append(values, sb.String())
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected value %s", str[i:])
+		}
+	}
+
+	return values, nil
+}
+
+type fakeConnector struct {
+	f *fakeDB
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{f: c.f}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver opens connections by the connector only")
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), c.f.exec(query)
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return c.f.query(query)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+)
+
+var (
+	// Errors returned when failure occurs
+	errNotCorrectJSONArray = errors.New("token is not a correct JSON array delimiter")
+)
+
+// JSONDecoder type is behaviour structure that implements Decoder interface and supports decoding of the JSON writer
+// output, thus the stored data can be processed again without the original XML dump.
+type JSONDecoder struct {
+	r      *bufio.Reader
+	d      *json.Decoder
+	o      Options
+	arrays bool
+	open   bool
+	err    error
+}
+
+// NewJSONDecoder creates new decoder with the implementation of JSONDecoder. The input could be a JSON array
+// (or more arrays following each other, as the JSON writer creates one array per block when it's not opened) or
+// newline delimited JSON (JSON Lines) with one record per line. The format is detected by the first character.
+//
+// All records of the input have to be of the file type given by options, the type field (see write.JSON options)
+// is ignored.
+func NewJSONDecoder(reader io.Reader, options *Options) Decoder {
+	d := &JSONDecoder{}
+
+	if reader == nil {
+		d.err = errReaderIsNull
+	}
+
+	if options == nil {
+		options = &Options{}
+	}
+	d.SetOptions(*options)
+
+	d.r = bufio.NewReader(reader)
+	return d
+}
+
+// Error provides the state error.
+func (j *JSONDecoder) Error() error {
+	return j.err
+}
+
+// Options returns options from JSON decoder.
+func (j *JSONDecoder) Options() Options {
+	return j.o
+}
+
+// SetOptions sets new options
+func (j *JSONDecoder) SetOptions(opt Options) {
+	j.o = defaultOptions(opt)
+}
+
+// Decode function parses data and saves the result into the writer, the same way as the XMLDecoder does.
+func (j *JSONDecoder) Decode(w write.Writer) error {
+	if j.err != nil {
+		return j.err
+	}
+
+	j.err = decode(j, w)
+	return j.err
+}
+
+// Artists function performs decoding the artist items from provided JSON input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (j *JSONDecoder) Artists() (int, []model.Artist, error) {
+	if j.err != nil {
+		return 0, nil, j.err
+	}
+
+	var artists []model.Artist
+	for len(artists) < j.o.Block.ItemSize {
+		a := model.Artist{}
+		j.err = j.next(&a)
+		if j.err != nil {
+			break
+		}
+
+		artists = append(artists, a)
+	}
+
+	if j.err == nil || j.err == io.EOF {
+		artists = j.o.QualityLevel.filterArtists(artists)
+	}
+	return len(artists), artists, j.err
+}
+
+// Labels function performs decoding the label items from provided JSON input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (j *JSONDecoder) Labels() (int, []model.Label, error) {
+	if j.err != nil {
+		return 0, nil, j.err
+	}
+
+	var labels []model.Label
+	for len(labels) < j.o.Block.ItemSize {
+		l := model.Label{}
+		j.err = j.next(&l)
+		if j.err != nil {
+			break
+		}
+
+		labels = append(labels, l)
+	}
+
+	if j.err == nil || j.err == io.EOF {
+		labels = j.o.QualityLevel.filterLabels(labels)
+	}
+	return len(labels), labels, j.err
+}
+
+// Masters function performs decoding the master items from provided JSON input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (j *JSONDecoder) Masters() (int, []model.Master, error) {
+	if j.err != nil {
+		return 0, nil, j.err
+	}
+
+	var masters []model.Master
+	for len(masters) < j.o.Block.ItemSize {
+		m := model.Master{}
+		j.err = j.next(&m)
+		if j.err != nil {
+			break
+		}
+
+		masters = append(masters, m)
+	}
+
+	if j.err == nil || j.err == io.EOF {
+		masters = j.o.QualityLevel.filterMasters(masters)
+	}
+	return len(masters), masters, j.err
+}
+
+// Releases function performs decoding the release items from provided JSON input and uses Options,
+// especially the block ItemSize value.
+//
+// Function returns number of decoded and filtered items, slice of items and possible error, when occurs.
+func (j *JSONDecoder) Releases() (int, []model.Release, error) {
+	if j.err != nil {
+		return 0, nil, j.err
+	}
+
+	var releases []model.Release
+	for len(releases) < j.o.Block.ItemSize {
+		r := model.Release{}
+		j.err = j.next(&r)
+		if j.err != nil {
+			break
+		}
+
+		releases = append(releases, r)
+	}
+
+	if j.err == nil || j.err == io.EOF {
+		releases = j.o.QualityLevel.filterReleases(releases)
+	}
+	return len(releases), releases, j.err
+}
+
+//--------------------------------------------------- Helpers ---------------------------------------------------
+
+// next decodes the next record into the value. Items of arrays are decoded one by one, so the whole array is never
+// loaded into memory. The end of the input results in io.EOF.
+func (j *JSONDecoder) next(v interface{}) error {
+	if j.d == nil {
+		err := j.start()
+		if err != nil {
+			return err
+		}
+	}
+
+	for j.arrays {
+		if j.open && j.d.More() {
+			return j.d.Decode(v)
+		}
+
+		t, err := j.d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case json.Delim('['):
+			j.open = true
+		case json.Delim(']'):
+			j.open = false
+		default:
+			return errNotCorrectJSONArray
+		}
+	}
+
+	return j.d.Decode(v)
+}
+
+// start detects the format of the input by the first character other than white space and creates the JSON decoder.
+func (j *JSONDecoder) start() error {
+	for {
+		b, err := j.r.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = j.r.ReadByte()
+			continue
+		}
+
+		j.arrays = b[0] == '['
+		j.d = json.NewDecoder(j.r)
+		return nil
+	}
+}
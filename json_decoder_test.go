@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package discogs
+
+import (
+	"bytes"
+	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewJSONDecoder(t *testing.T) {
+	d := NewJSONDecoder(nil, nil)
+	if d.Error() != errReaderIsNull {
+		t.Errorf("there should be an error %v", errReaderIsNull)
+	}
+
+	o := d.Options()
+	if o.Block.ItemSize != defaultBlockSize || o.Block.Limit != defaultBlockLimit {
+		t.Error("default block options should be set")
+	}
+}
+
+func TestJSONDecoder_Releases(t *testing.T) {
+	expected := xmlReleases(t)
+	for _, o := range []*write.Options{nil, {JSON: write.JSON{Lines: true}}, {JSON: write.JSON{TypeField: "type"}}} {
+		b := &bytes.Buffer{}
+		w := write.NewJSONWriter(b, o)
+		for _, r := range expected {
+			_ = w.WriteRelease(r)
+		}
+
+		d := NewJSONDecoder(b, &Options{Block: Block{ItemSize: 3}})
+
+		var got []model.Release
+		for {
+			_, rs, err := d.Releases()
+			got = append(got, rs...)
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("decoded releases differ from written releases, options %+v", o)
+		}
+	}
+}
+
+func TestJSONDecoder_Decode(t *testing.T) {
+	expected := &bytes.Buffer{}
+	err := NewXMLDecoder(strings.NewReader(artists), &Options{FileType: Artists}).
+		Decode(write.NewJSONWriter(expected, nil))
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	got := &bytes.Buffer{}
+	err = NewJSONDecoder(bytes.NewReader(expected.Bytes()), &Options{FileType: Artists}).
+		Decode(write.NewJSONWriter(got, nil))
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+
+	if got.String() != expected.String() {
+		t.Errorf("decoded artists should be written the same way\n%s\n%s", got, expected)
+	}
+}
+
+func TestJSONDecoder_QualityLevel(t *testing.T) {
+	d := NewJSONDecoder(strings.NewReader(qualityLabels), &Options{QualityLevel: Correct})
+	num, labels, err := d.Labels()
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if num != 1 || labels[0].ID != "2" {
+		t.Errorf("only the correct label should be decoded, got %v", labels)
+	}
+}
+
+func TestJSONDecoder_Invalid(t *testing.T) {
+	d := NewJSONDecoder(strings.NewReader(`[{"id":"1"},"x"]`), nil)
+	_, _, err := d.Masters()
+	if err == nil || err == io.EOF {
+		t.Errorf("invalid input should result in an error, got %v", err)
+	}
+}
+
+func xmlReleases(t *testing.T) []model.Release {
+	_, rs, err := NewXMLDecoder(strings.NewReader(releases), nil).Releases()
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	return rs
+}
+
+// ------------------------------------------------------- DATA -------------------------------------------------------
+
+const qualityLabels = `
+{"id":"1","name":"Label 1","data_quality":"Needs Vote"}
+{"id":"2","name":"Label 2","data_quality":"Correct"}
+`
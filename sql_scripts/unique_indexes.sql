@@ -0,0 +1,4 @@
+CREATE UNIQUE INDEX artists_artist_id_unique ON artists(artist_id);
+CREATE UNIQUE INDEX labels_label_id_unique ON labels(label_id);
+CREATE UNIQUE INDEX masters_master_id_unique ON masters(master_id);
+CREATE UNIQUE INDEX releases_release_id_unique ON releases(release_id);
@@ -0,0 +1,311 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+// Batch options are used by SQL and DB writers only.
+//
+// By default each written block results in one transaction. Records and Bytes decouple transactions from blocks:
+// the transaction is committed as soon as it contains the number of records or the size of commands in bytes,
+// whichever comes first, regardless of the block size. The last transaction is committed by the Finish function
+// (see Finisher interface), therefore writers have to be finished. When any command fails the whole transaction is
+// rolled back, including records of previous blocks that belong to it.
+//
+// Rows is the maximal number of rows inserted by one multi-row INSERT command, rows are inserted one by one by
+// default. Rows of multi-row inserts are collected per table and inserted when the limit is reached or when the
+// transaction is committed. Pending rows of main entity tables (artists, labels, masters and releases) are always
+// inserted before rows of their child tables, so foreign keys can be used. In the Upsert mode an entity repeated
+// within the transaction causes pending rows to be inserted first, thus the last occurrence of the entity wins and its
+// child rows are not duplicated.
+type Batch struct {
+	Records int
+	Bytes   int
+	Rows    int
+}
+
+// enabled returns true when transactions are decoupled from blocks.
+func (b Batch) enabled() bool {
+	return b.Records > 0 || b.Bytes > 0
+}
+
+// full returns true when the transaction with the number of records and bytes should be committed.
+func (b Batch) full(records, bytes int) bool {
+	return (b.Records > 0 && records >= b.Records) || (b.Bytes > 0 && bytes >= b.Bytes)
+}
+
+// rows returns the number of rows inserted by one command.
+func (b Batch) rows() int {
+	if b.Rows < 1 {
+		return 1
+	}
+
+	return b.Rows
+}
+
+// transaction is the target of batched SQL commands, such as the output of the SQL writer or the database connection.
+type transaction interface {
+	begin() error
+	exec(cmd string) error
+	commit() error
+	rollback() error
+}
+
+// batcher groups SQL commands of records into transactions and multi-row inserts according to Batch options.
+// When the RetryPolicy allows retries, executed commands are logged to be able to replay the whole transaction.
+type batcher struct {
+	o       Options
+	tx      transaction
+	open    bool
+	records int
+	bytes   int
+	tables  []table
+	pending map[string][]row
+	log     []string
+}
+
+func newBatcher(o Options, tx transaction) *batcher {
+	return &batcher{
+		o:       o,
+		tx:      tx,
+		pending: make(map[string][]row),
+	}
+}
+
+// write executes delete commands and inserts rows of one record. The transaction is started when it's needed and
+// committed when it's full. When any command fails the transaction is rolled back.
+func (b *batcher) write(deletes []string, rows []row) error {
+	err := b.begin()
+	if err != nil {
+		return err
+	}
+
+	if b.o.Mode == Upsert && len(rows) > 0 && b.isPending(rows[0]) {
+		err = b.insertAll()
+		if err != nil {
+			return b.abort(err)
+		}
+	}
+
+	for _, cmd := range deletes {
+		err = b.exec(cmd)
+		if err != nil {
+			return b.abort(err)
+		}
+	}
+
+	for _, r := range rows {
+		err = b.add(r)
+		if err != nil {
+			return b.abort(err)
+		}
+	}
+
+	b.records++
+	if b.o.Batch.full(b.records, b.bytes) {
+		return b.commit()
+	}
+
+	return nil
+}
+
+// block is called at the end of each written block. Unless transactions are decoupled from blocks the transaction is
+// committed, an empty block results in an empty transaction.
+func (b *batcher) block() error {
+	if b.o.Batch.enabled() {
+		return nil
+	}
+
+	err := b.begin()
+	if err != nil {
+		return err
+	}
+
+	return b.commit()
+}
+
+// commit inserts all pending rows and commits the transaction, if there is any.
+func (b *batcher) commit() error {
+	if !b.open {
+		return nil
+	}
+
+	err := b.insertAll()
+	if err != nil {
+		return b.abort(err)
+	}
+
+	err = b.tx.commit()
+	if err != nil {
+		b.open = false
+		err = b.recover(err, b.tx.commit)
+	}
+
+	if err != nil {
+		return b.abort(err)
+	}
+
+	b.reset()
+	return nil
+}
+
+// abort rolls back the transaction and returns the error that caused it.
+func (b *batcher) abort(err error) error {
+	if b.open {
+		_ = b.tx.rollback()
+	}
+
+	b.reset()
+	return err
+}
+
+func (b *batcher) begin() error {
+	if b.open {
+		return nil
+	}
+
+	err := b.tx.begin()
+	if err == nil {
+		b.open = true
+		return nil
+	}
+
+	return b.recover(err, nil)
+}
+
+func (b *batcher) exec(cmd string) error {
+	b.bytes += len(cmd)
+
+	err := b.tx.exec(cmd)
+	if err != nil {
+		err = b.recover(err, func() error { return b.tx.exec(cmd) })
+	}
+
+	if err == nil && b.o.Retry.MaxAttempts > 1 {
+		b.log = append(b.log, cmd)
+	}
+
+	return err
+}
+
+// recover retries the failed transaction according to the RetryPolicy. The transaction is rolled back, started again
+// and all logged commands are replayed, then the failed operation is repeated (when it's set).
+func (b *batcher) recover(err error, op func() error) error {
+	for attempt := 1; b.o.Retry.retry(attempt, err); attempt++ {
+		if b.open {
+			_ = b.tx.rollback()
+			b.open = false
+		}
+
+		b.o.Retry.wait(attempt)
+		err = b.replay()
+		if err == nil && op != nil {
+			err = op()
+		}
+	}
+
+	return err
+}
+
+// replay starts the transaction again and executes all logged commands.
+func (b *batcher) replay() error {
+	err := b.tx.begin()
+	if err != nil {
+		return err
+	}
+
+	b.open = true
+	for _, cmd := range b.log {
+		err = b.tx.exec(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add inserts the row or keeps it pending until there are enough rows of the table for a multi-row insert.
+func (b *batcher) add(r row) error {
+	rows, ok := b.pending[r.table.name]
+	if !ok {
+		b.tables = append(b.tables, r.table)
+	}
+
+	b.pending[r.table.name] = append(rows, r)
+	if len(b.pending[r.table.name]) < b.o.Batch.rows() {
+		return nil
+	}
+
+	// parent rows of the child table have to be inserted first
+	if r.table.key == "" {
+		err := b.insertTables(true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.insert(r.table)
+}
+
+// insertAll inserts pending rows of all tables, main entity tables go first.
+func (b *batcher) insertAll() error {
+	err := b.insertTables(true)
+	if err != nil {
+		return err
+	}
+
+	return b.insertTables(false)
+}
+
+// insertTables inserts pending rows of main entity tables (tables with the key) or of child tables.
+func (b *batcher) insertTables(main bool) error {
+	for _, t := range b.tables {
+		if (t.key != "") != main {
+			continue
+		}
+
+		err := b.insert(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isPending returns true when the row of the main entity table with the same key is pending.
+func (b *batcher) isPending(r row) bool {
+	if r.table.key == "" {
+		return false
+	}
+
+	key := r.keyValue()
+	for _, p := range b.pending[r.table.name] {
+		if p.keyValue() == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// insert inserts all pending rows of the table by one command.
+func (b *batcher) insert(t table) error {
+	rows := b.pending[t.name]
+	if len(rows) == 0 {
+		return nil
+	}
+
+	b.pending[t.name] = rows[:0]
+	return b.exec(b.o.insertCommand(t, rows))
+}
+
+func (b *batcher) reset() {
+	b.open = false
+	b.records = 0
+	b.bytes = 0
+	b.tables = nil
+	b.pending = make(map[string][]row)
+	b.log = nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"github.com/lukasaron/data-discogs/model"
+	"strings"
+	"testing"
+)
+
+func TestSQLWriter_Batch_Records(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Batch: Batch{Records: 2}})
+
+	for i := 0; i < 3; i++ {
+		err := s.WriteLabels(labels)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if strings.Count(b.String(), "BEGIN;") != 2 || strings.Count(b.String(), "COMMIT;") != 1 {
+		t.Error("the first transaction should contain two records and the second one should be open")
+	}
+
+	err := Finish(s, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if strings.Count(b.String(), "COMMIT;") != 2 || !strings.HasSuffix(b.String(), "COMMIT;\n") {
+		t.Error("the last transaction should be committed by the Finish function")
+	}
+}
+
+func TestSQLWriter_Batch_Rows(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Batch: Batch{Rows: 2}})
+
+	err := s.WriteArtists([]model.Artist{batchArtist, batchArtist})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := b.String(); got != expectedBatchArtists {
+		t.Errorf("unexpected multi-row inserts %s", got)
+	}
+}
+
+func TestSQLWriter_Batch_Upsert(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert, Batch: Batch{Rows: 10}})
+
+	err := s.WriteArtists([]model.Artist{batchArtist, batchArtist})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := b.String(); got != expectedBatchUpsert {
+		t.Errorf("the repeated artist should be inserted after pending rows %s", got)
+	}
+}
+
+func TestOptions_InsertCommand_Upsert(t *testing.T) {
+	first := row{table: labelsTable, values: []interface{}{"1", "Planet E", "", "", "", []string{}}}
+	second := row{table: labelsTable, values: []interface{}{"2", "Tresor", "", "", "", []string{}}}
+	last := row{table: labelsTable, values: []interface{}{"1", "Planet E Communications", "", "", "", []string{}}}
+
+	o := Options{Mode: Upsert}
+	cmd := o.insertCommand(labelsTable, []row{first, second, last})
+	if strings.Count(cmd, "('1'") != 1 || !strings.Contains(cmd, "VALUES ('1', 'Planet E Communications'") {
+		t.Errorf("rows with the same key should be inserted once keeping the last one %s", cmd)
+	}
+
+	cmd = Options{}.insertCommand(labelsTable, []row{first, last})
+	if strings.Count(cmd, "('1'") != 2 {
+		t.Errorf("rows should be deduplicated in the Upsert mode only %s", cmd)
+	}
+}
+
+func TestDBWriter_Batch_Bytes(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{Batch: Batch{Bytes: 1}})
+	_ = w.WriteArtists([]model.Artist{batchArtist})
+	err := w.WriteArtists([]model.Artist{batchArtist})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	statements := f.recorded()
+	if len(statements) != 12 || statements[5] != "COMMIT" || statements[6] != "BEGIN" || statements[11] != "COMMIT" {
+		t.Errorf("each record should be committed in its own transaction, got %v", statements)
+	}
+}
+
+// ---------------------------------------------------- DATA ----------------------------------------------------
+
+var batchArtist = model.Artist{
+	ID:   "1",
+	Name: "The Persuader",
+	Aliases: []model.Alias{
+		{ID: "2", Name: "Jesper Dahlbäck"},
+		{ID: "3", Name: "Lenk"},
+		{ID: "4", Name: "Dahlback"},
+	},
+}
+
+const expectedBatchArtists = "BEGIN;\n" +
+	"INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES ('1', 'The Persuader', '', '', '', ARRAY[''], ARRAY['']);\n" +
+	"INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('1', '2', 'Jesper Dahlbäck'), ('1', '3', 'Lenk');\n" +
+	"INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES ('1', 'The Persuader', '', '', '', ARRAY[''], ARRAY['']);\n" +
+	"INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('1', '4', 'Dahlback'), ('1', '2', 'Jesper Dahlbäck');\n" +
+	"INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('1', '3', 'Lenk'), ('1', '4', 'Dahlback');\n" +
+	"COMMIT;\n"
+
+const expectedBatchUpsert = "BEGIN;\n" +
+	"DELETE FROM artist_aliases WHERE artist_id = '1';\n" +
+	"DELETE FROM artist_members WHERE artist_id = '1';\n" +
+	"DELETE FROM images WHERE artist_id = '1';\n" +
+	"INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES ('1', 'The Persuader', '', '', '', ARRAY[''], ARRAY['']) ON CONFLICT (artist_id) DO UPDATE SET name = EXCLUDED.name, real_name = EXCLUDED.real_name, profile = EXCLUDED.profile, data_quality = EXCLUDED.data_quality, name_variations = EXCLUDED.name_variations, urls = EXCLUDED.urls;\n" +
+	"INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('1', '2', 'Jesper Dahlbäck'), ('1', '3', 'Lenk'), ('1', '4', 'Dahlback');\n" +
+	"DELETE FROM artist_aliases WHERE artist_id = '1';\n" +
+	"DELETE FROM artist_members WHERE artist_id = '1';\n" +
+	"DELETE FROM images WHERE artist_id = '1';\n" +
+	"INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES ('1', 'The Persuader', '', '', '', ARRAY[''], ARRAY['']) ON CONFLICT (artist_id) DO UPDATE SET name = EXCLUDED.name, real_name = EXCLUDED.real_name, profile = EXCLUDED.profile, data_quality = EXCLUDED.data_quality, name_variations = EXCLUDED.name_variations, urls = EXCLUDED.urls;\n" +
+	"INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('1', '2', 'Jesper Dahlbäck'), ('1', '3', 'Lenk'), ('1', '4', 'Dahlback');\n" +
+	"COMMIT;\n"
@@ -0,0 +1,373 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+)
+
+// Header of the binary format, the magic string is followed by the version encoded as an unsigned varint.
+// The version is increased whenever the layout of records changes.
+const (
+	BinaryMagic   = "DDGB"
+	BinaryVersion = 1
+)
+
+// Kinds of binary records, the kind byte precedes each record.
+const (
+	BinaryArtist  byte = 'a'
+	BinaryLabel   byte = 'l'
+	BinaryMaster  byte = 'm'
+	BinaryRelease byte = 'r'
+)
+
+// BinaryWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// data in a compact binary format, which is much smaller and faster to read than JSON. The intended usage is a cache
+// between pipeline stages, the output can be decoded by the BinaryDecoder.
+//
+// The output starts with the header (BinaryMagic and BinaryVersion) and each record consists of the kind byte,
+// the length of the record encoded as an unsigned varint and the record itself. Fields of the record are encoded
+// in the order of the model structure, strings as the length followed by bytes and slices as the number of items
+// increased by one followed by items (zero stands for the nil slice), all numbers are unsigned varints.
+type BinaryWriter struct {
+	o      Options
+	w      io.Writer
+	buf    bytes.Buffer
+	rec    bytes.Buffer
+	varint [binary.MaxVarintLen64]byte
+	header bool
+	err    error
+}
+
+// NewBinaryWriter creates a new Writer instance based on the provided output writer (for instance a file).
+// Options with ExcludeImages or Exclude fields can be set when we don't want images (or other sub-collections) as
+// part of the final solution.
+func NewBinaryWriter(output io.Writer, options *Options) Writer {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	return &BinaryWriter{
+		o: *options,
+		w: output,
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (b BinaryWriter) Options() Options {
+	return b.o
+}
+
+// WriteArtist function writes an artist to the binary output.
+func (b *BinaryWriter) WriteArtist(artist model.Artist) error {
+	b.writeArtist(b.o.excludeArtist(artist))
+	b.flush()
+
+	return b.err
+}
+
+// WriteArtists function writes a slice of artists to the binary output.
+func (b *BinaryWriter) WriteArtists(artists []model.Artist) error {
+	for _, a := range artists {
+		b.writeArtist(b.o.excludeArtist(a))
+	}
+
+	b.flush()
+	return b.err
+}
+
+// WriteLabel function writes a label to the binary output.
+func (b *BinaryWriter) WriteLabel(label model.Label) error {
+	b.writeLabel(b.o.excludeLabel(label))
+	b.flush()
+
+	return b.err
+}
+
+// WriteLabels function writes a slice of labels to the binary output.
+func (b *BinaryWriter) WriteLabels(labels []model.Label) error {
+	for _, l := range labels {
+		b.writeLabel(b.o.excludeLabel(l))
+	}
+
+	b.flush()
+	return b.err
+}
+
+// WriteMaster function writes a master to the binary output.
+func (b *BinaryWriter) WriteMaster(master model.Master) error {
+	b.writeMaster(b.o.excludeMaster(master))
+	b.flush()
+
+	return b.err
+}
+
+// WriteMasters function writes a slice of masters to the binary output.
+func (b *BinaryWriter) WriteMasters(masters []model.Master) error {
+	for _, m := range masters {
+		b.writeMaster(b.o.excludeMaster(m))
+	}
+
+	b.flush()
+	return b.err
+}
+
+// WriteRelease function writes a release to the binary output.
+func (b *BinaryWriter) WriteRelease(release model.Release) error {
+	b.writeRelease(b.o.excludeRelease(release))
+	b.flush()
+
+	return b.err
+}
+
+// WriteReleases function writes a slice of releases to the binary output.
+func (b *BinaryWriter) WriteReleases(releases []model.Release) error {
+	for _, r := range releases {
+		b.writeRelease(b.o.excludeRelease(r))
+	}
+
+	b.flush()
+	return b.err
+}
+
+// Open writes the header, thus the output is valid even when there are no records.
+func (b *BinaryWriter) Open() error {
+	b.flush()
+	return b.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (b *BinaryWriter) Flush() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	return flushOutput(b.w)
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// flush writes the header (once per writer) and buffered records into the output.
+func (b *BinaryWriter) flush() {
+	if b.err == nil && !b.header {
+		b.header = true
+		_, b.err = b.w.Write(append([]byte(BinaryMagic), b.varint[:binary.PutUvarint(b.varint[:], BinaryVersion)]...))
+	}
+
+	if b.err == nil && b.buf.Len() > 0 {
+		_, b.err = b.w.Write(b.buf.Bytes())
+	}
+
+	b.buf.Reset()
+}
+
+// record moves the encoded record into the buffer together with its kind and length.
+func (b *BinaryWriter) record(kind byte) {
+	b.buf.WriteByte(kind)
+	b.buf.Write(b.varint[:binary.PutUvarint(b.varint[:], uint64(b.rec.Len()))])
+	b.buf.Write(b.rec.Bytes())
+	b.rec.Reset()
+}
+
+func (b *BinaryWriter) uvarint(x uint64) {
+	b.rec.Write(b.varint[:binary.PutUvarint(b.varint[:], x)])
+}
+
+func (b *BinaryWriter) string(s string) {
+	b.uvarint(uint64(len(s)))
+	b.rec.WriteString(s)
+}
+
+// count writes the number of items increased by one, zero stands for the nil slice.
+func (b *BinaryWriter) count(n int, null bool) {
+	if null {
+		b.uvarint(0)
+		return
+	}
+
+	b.uvarint(uint64(n) + 1)
+}
+
+func (b *BinaryWriter) strings(ss []string) {
+	b.count(len(ss), ss == nil)
+	for _, s := range ss {
+		b.string(s)
+	}
+}
+
+// ----------------------------------------------- ARTIST -----------------------------------------------
+
+func (b *BinaryWriter) writeArtist(a model.Artist) {
+	b.string(a.ID)
+	b.string(a.Name)
+	b.string(a.RealName)
+	b.images(a.Images)
+	b.string(a.Profile)
+	b.string(a.DataQuality)
+	b.strings(a.NameVariations)
+	b.strings(a.Urls)
+
+	b.count(len(a.Aliases), a.Aliases == nil)
+	for _, al := range a.Aliases {
+		b.string(al.ID)
+		b.string(al.Name)
+	}
+
+	b.count(len(a.Members), a.Members == nil)
+	for _, m := range a.Members {
+		b.string(m.ID)
+		b.string(m.Name)
+	}
+
+	b.record(BinaryArtist)
+}
+
+// ----------------------------------------------- LABEL -----------------------------------------------
+
+func (b *BinaryWriter) writeLabel(l model.Label) {
+	b.string(l.ID)
+	b.string(l.Name)
+	b.images(l.Images)
+	b.string(l.ContactInfo)
+	b.string(l.Profile)
+	b.string(l.DataQuality)
+	b.strings(l.Urls)
+
+	if l.ParentLabel == nil {
+		b.count(0, true)
+	} else {
+		b.count(1, false)
+		b.string(l.ParentLabel.ID)
+		b.string(l.ParentLabel.Name)
+	}
+
+	b.count(len(l.SubLabels), l.SubLabels == nil)
+	for _, sl := range l.SubLabels {
+		b.string(sl.ID)
+		b.string(sl.Name)
+	}
+
+	b.record(BinaryLabel)
+}
+
+// ----------------------------------------------- MASTER -----------------------------------------------
+
+func (b *BinaryWriter) writeMaster(m model.Master) {
+	b.string(m.ID)
+	b.string(m.MainRelease)
+	b.images(m.Images)
+	b.releaseArtists(m.Artists)
+	b.strings(m.Genres)
+	b.strings(m.Styles)
+	b.string(m.Year)
+	b.string(m.Title)
+	b.string(m.DataQuality)
+	b.videos(m.Videos)
+
+	b.record(BinaryMaster)
+}
+
+// ----------------------------------------------- RELEASE -----------------------------------------------
+
+func (b *BinaryWriter) writeRelease(r model.Release) {
+	b.string(r.ID)
+	b.string(r.Status)
+	b.images(r.Images)
+	b.releaseArtists(r.Artists)
+	b.releaseArtists(r.ExtraArtists)
+	b.string(r.Title)
+
+	b.count(len(r.Formats), r.Formats == nil)
+	for _, f := range r.Formats {
+		b.string(f.Name)
+		b.string(f.Quantity)
+		b.string(f.Text)
+		b.strings(f.Descriptions)
+	}
+
+	b.strings(r.Genres)
+	b.strings(r.Styles)
+	b.string(r.Country)
+	b.string(r.Released)
+	b.string(r.Notes)
+	b.string(r.DataQuality)
+	b.string(r.MasterID)
+	b.string(r.MainRelease)
+
+	b.count(len(r.TrackList), r.TrackList == nil)
+	for _, t := range r.TrackList {
+		b.string(t.Position)
+		b.string(t.Title)
+		b.string(t.Duration)
+	}
+
+	b.count(len(r.Identifiers), r.Identifiers == nil)
+	for _, i := range r.Identifiers {
+		b.string(i.Description)
+		b.string(i.Type)
+		b.string(i.Value)
+	}
+
+	b.videos(r.Videos)
+
+	b.count(len(r.Labels), r.Labels == nil)
+	for _, l := range r.Labels {
+		b.string(l.ID)
+		b.string(l.Name)
+		b.string(l.Category)
+	}
+
+	b.count(len(r.Companies), r.Companies == nil)
+	for _, c := range r.Companies {
+		b.string(c.ID)
+		b.string(c.Name)
+		b.string(c.Category)
+		b.string(c.EntityType)
+		b.string(c.EntityTypeName)
+		b.string(c.ResourceURL)
+	}
+
+	b.record(BinaryRelease)
+}
+
+func (b *BinaryWriter) releaseArtists(artists []model.ReleaseArtist) {
+	b.count(len(artists), artists == nil)
+	for _, a := range artists {
+		b.string(a.ID)
+		b.string(a.Name)
+		b.string(a.Join)
+		b.string(a.Anv)
+		b.string(a.Role)
+		b.string(a.Tracks)
+	}
+}
+
+// ----------------------------------------------- SHARED -----------------------------------------------
+
+func (b *BinaryWriter) images(images []model.Image) {
+	b.count(len(images), images == nil)
+	for _, img := range images {
+		b.string(img.Height)
+		b.string(img.Width)
+		b.string(img.Type)
+		b.string(img.URI)
+		b.string(img.URI150)
+	}
+}
+
+func (b *BinaryWriter) videos(videos []model.Video) {
+	b.count(len(videos), videos == nil)
+	for _, v := range videos {
+		b.string(v.Duration)
+		b.string(v.Embed)
+		b.string(v.Src)
+		b.string(v.Title)
+		b.string(v.Description)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestBinaryWriter_Options(t *testing.T) {
+	b := NewBinaryWriter(nil, nil)
+	opt := b.Options()
+
+	if opt.ExcludeImages {
+		t.Error("exclude images should be false as a default value")
+	}
+}
+
+func TestBinaryWriter_Header(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewBinaryWriter(b, nil)
+	_ = Open(w)
+	_ = w.WriteArtist(artists[0])
+	_ = w.WriteArtists(artists)
+
+	if !bytes.HasPrefix(b.Bytes(), []byte(BinaryMagic+"\x01"+string(BinaryArtist))) {
+		t.Errorf("output should start with the header followed by the artist record %q", b.Bytes())
+	}
+
+	if bytes.Count(b.Bytes(), []byte(BinaryMagic)) != 1 {
+		t.Error("header should be written only once")
+	}
+}
+
+func TestBinaryWriter_WriteReleases(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := NewBinaryWriter(b, nil).WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	j, _ := json.Marshal(releases)
+	if b.Len() == 0 || b.Len() >= len(j) {
+		t.Errorf("binary output (%d bytes) should be smaller than JSON (%d bytes)", b.Len(), len(j))
+	}
+}
+
+func TestBinaryWriter_ExcludeImages(t *testing.T) {
+	all := &bytes.Buffer{}
+	_ = NewBinaryWriter(all, nil).WriteLabels(labels)
+
+	excluded := &bytes.Buffer{}
+	_ = NewBinaryWriter(excluded, &Options{ExcludeImages: true}).WriteLabels(labels)
+
+	if excluded.Len() >= all.Len() {
+		t.Error("images should be excluded")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Codec compresses outputs of writers. NewWriter wraps the output by the compressing writer, which has to be closed
+// to complete the compressed stream. When the compressing writer implements the Flusher interface, pending data are
+// flushed at the end of each block. Extension is the usual file extension of the format, such as .gz.
+type Codec interface {
+	NewWriter(output io.Writer) (io.WriteCloser, error)
+	Extension() string
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{"gzip": Gzip(gzip.DefaultCompression)}
+)
+
+// RegisterCodec makes the codec available by the name, such as zstd implemented by a third party package.
+// The codec registered with the same name before is replaced, the gzip codec is registered by default.
+func RegisterCodec(name string, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[name] = codec
+}
+
+// CodecByName returns the codec registered by the name.
+func CodecByName(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("codec %s is not registered", name)
+	}
+
+	return c, nil
+}
+
+type gzipCodec struct {
+	level int
+}
+
+// Gzip returns the codec compressing outputs by the gzip format with the compression level, such as
+// gzip.BestSpeed or gzip.DefaultCompression.
+func Gzip(level int) Codec {
+	return gzipCodec{level: level}
+}
+
+func (g gzipCodec) NewWriter(output io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(output, g.level)
+}
+
+func (g gzipCodec) Extension() string {
+	return ".gz"
+}
+
+// Compress wraps the output by the compressing writer of the codec, the result is meant to be passed to writers,
+// such as JSONWriter or SQLWriter. Writers flush it at the end of each block (see Flusher interface), so complete
+// blocks can be read from the compressed stream. The returned writer has to be closed to complete the stream,
+// which closes the output as well when it implements the io.Closer interface.
+func Compress(output io.Writer, codec Codec) (io.WriteCloser, error) {
+	cw, err := codec.NewWriter(output)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressedOutput{cw: cw, output: output}, nil
+}
+
+// CompressChunks wraps outputs of chunks opened by the function (see FileTemplate and NewRotatingWriter) by
+// the compressing writer of the codec, so each chunk is compressed independently.
+func CompressChunks(open func(chunk int) (io.Writer, error), codec Codec) func(chunk int) (io.Writer, error) {
+	return func(chunk int) (io.Writer, error) {
+		output, err := open(chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		return Compress(output, codec)
+	}
+}
+
+// CompressTables wraps outputs of tables opened by the function (see NewCSVWriterFunc) by the compressing writer
+// of the codec.
+func CompressTables(open func(table string) (io.Writer, error), codec Codec) func(table string) (io.Writer, error) {
+	return func(table string) (io.Writer, error) {
+		output, err := open(table)
+		if err != nil {
+			return nil, err
+		}
+
+		return Compress(output, codec)
+	}
+}
+
+// compressedOutput flushes and closes both the compressing writer and the output.
+type compressedOutput struct {
+	cw     io.WriteCloser
+	output io.Writer
+}
+
+func (c *compressedOutput) Write(p []byte) (int, error) {
+	return c.cw.Write(p)
+}
+
+func (c *compressedOutput) Flush() error {
+	err := flushOutput(c.cw)
+	if err != nil {
+		return err
+	}
+
+	return flushOutput(c.output)
+}
+
+func (c *compressedOutput) Close() error {
+	err := c.cw.Close()
+	if cl, ok := c.output.(io.Closer); ok {
+		if cErr := cl.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// nopCodec writes the output as it is, it stands for codecs registered by callers.
+type nopCodec struct{}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (nopCodec) NewWriter(output io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{Writer: output}, nil
+}
+
+func (nopCodec) Extension() string {
+	return ""
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestCodecByName(t *testing.T) {
+	c, err := CodecByName("gzip")
+	if err != nil || c.Extension() != ".gz" {
+		t.Errorf("gzip codec should be registered by default, got %v", err)
+	}
+
+	_, err = CodecByName("zstd")
+	if err == nil {
+		t.Error("zstd codec should not be registered")
+	}
+
+	RegisterCodec("nop", nopCodec{})
+	c, err = CodecByName("nop")
+	if err != nil || c != (nopCodec{}) {
+		t.Errorf("registered codec should be returned, got %v", err)
+	}
+}
+
+func TestCompress_JSONWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	output, err := Compress(b, Gzip(gzip.BestSpeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewJSONWriter(output, nil)
+	_ = Open(w)
+	_ = w.WriteReleases(releases)
+	_ = Flush(w)
+
+	// the stream is not complete yet, but the flushed block can be read already
+	gr, _ := gzip.NewReader(bytes.NewReader(b.Bytes()))
+	block, _ := ioutil.ReadAll(gr)
+	if !strings.HasPrefix(string(block), `[{"id":"2"`) {
+		t.Errorf("flushed block should be readable from the compressed stream: %s", block)
+	}
+
+	_ = Finish(w, nil)
+	err = output.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := &strings.Builder{}
+	j := NewJSONWriter(expected, nil)
+	_ = Open(j)
+	_ = j.WriteReleases(releases)
+	_ = Finish(j, nil)
+
+	if got := gunzip(t, b.Bytes()); got != expected.String() {
+		t.Errorf("decompressed output differs from the JSON output\n%s\n%s", got, expected)
+	}
+}
+
+func TestCompress_Level(t *testing.T) {
+	_, err := Compress(&bytes.Buffer{}, Gzip(42))
+	if err == nil {
+		t.Error("invalid compression level should result in an error")
+	}
+}
+
+func TestCompressChunks(t *testing.T) {
+	var chunks []*bytes.Buffer
+	open := CompressChunks(func(int) (io.Writer, error) {
+		b := &bytes.Buffer{}
+		chunks = append(chunks, b)
+		return b, nil
+	}, Gzip(gzip.DefaultCompression))
+
+	w := NewRotatingWriter(open, NewSQLWriter, &Options{Rotation: Rotation{Blocks: 1}})
+	_ = Open(w)
+	for i := 0; i < 2; i++ {
+		_ = w.WriteMasters(masters)
+		_ = Flush(w)
+	}
+
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(chunks) != 2 {
+		t.Fatalf("2 chunks should be written, got %d", len(chunks))
+	}
+
+	for i, c := range chunks {
+		sql := gunzip(t, c.Bytes())
+		if !strings.HasPrefix(sql, "BEGIN;\n") || !strings.HasSuffix(sql, "COMMIT;\n") {
+			t.Errorf("chunk %d should be compressed independently: %s", i, sql)
+		}
+	}
+}
+
+func TestCompressTables(t *testing.T) {
+	outputs := make(map[string]*bytes.Buffer)
+	open := CompressTables(func(table string) (io.Writer, error) {
+		b := &bytes.Buffer{}
+		outputs[table] = b
+		return b, nil
+	}, Gzip(gzip.DefaultCompression))
+
+	c := NewCSVWriterFunc(open, nil)
+	_ = c.WriteArtists(artists)
+	_ = Flush(c)
+
+	if outputs["artist_members"].Len() == 0 {
+		t.Error("flushed block should be written into the compressed output")
+	}
+
+	_ = Close(c)
+
+	expected := "artist_id,member_id,name\n2,26,Alexi Delano\n2,27,Cari Lekebusch\n"
+	if got := gunzip(t, outputs["artist_members"].Bytes()); got != expected {
+		t.Errorf("artist members differ from what it's expected: %s", got)
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"sync"
+)
+
+// WorkerError describes the failure of one block written by the ConcurrentDBWriter. Block is the sequence number of
+// the block counted from zero from the beginning of the input, including blocks skipped by the decoder.
+type WorkerError struct {
+	Worker int
+	Block  int
+	Err    error
+}
+
+// Error returns the message of the original error with the worker and the block identification.
+func (e WorkerError) Error() string {
+	return fmt.Sprintf("worker %d: block %d: %v", e.Worker, e.Block, e.Err)
+}
+
+// ConcurrentDBWriter is the DB writer that spreads written blocks across a pool of workers. Each worker holds its own
+// database connection and writes each block within one transaction, the same way as the DBWriter does.
+type ConcurrentDBWriter struct {
+	o       Options
+	db      *sql.DB
+	workers int
+	lc      *DBWriter
+
+	jobs    chan job
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	cond    *sync.Cond
+	running bool
+
+	skip      int
+	next      int
+	committed int
+	done      map[int]bool
+	inFlight  map[string]int
+	errs      []error
+}
+
+// job is one block of records waiting for a worker.
+type job struct {
+	seq   int
+	keys  []string
+	write func(w Writer) error
+}
+
+// NewConcurrentDBWriter creates a new Writer instance that writes blocks into the database by the number of
+// concurrent workers. Options are the same as the DBWriter uses, except Batch Records and Bytes, which are ignored as
+// each block is always committed within its own transaction.
+//
+// Write functions only pass the block to a free worker, so the slice must not be changed after the call. Errors of
+// workers are returned as WorkerError (combined into MultiError when there are more of them) by following write
+// calls and by the Finish function, which waits until all blocks are written. Blocks touching the same entity
+// (artist, label, master or release ID) are never written at the same time, thus upserts of the same entity and its
+// shared child tables can't interfere.
+//
+// Blocks are committed out of order, the Committed function of the *ConcurrentDBWriter returns the number of blocks
+// committed without any gap from the beginning of the input, including blocks skipped by the decoder (see Skipper
+// interface). It's a safe checkpoint for the Skip option of the decoder when the import is resumed, even repeatedly.
+func NewConcurrentDBWriter(db *sql.DB, workers int, options *Options) Writer {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	o := *options
+	o.Batch.Records = 0
+	o.Batch.Bytes = 0
+
+	c := &ConcurrentDBWriter{
+		o:       o,
+		db:      db,
+		workers: workers,
+	}
+
+	c.lc = newDBWriter(db, o)
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Options function gets options. Can be used to get the default values.
+func (c *ConcurrentDBWriter) Options() Options {
+	return c.o
+}
+
+// WriteArtist function passes an artist as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteArtist(artist model.Artist) error {
+	return c.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function passes a slice of artists as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteArtists(artists []model.Artist) error {
+	keys := make([]string, 0, len(artists))
+	for _, a := range artists {
+		keys = append(keys, "artist:"+a.ID)
+	}
+
+	return c.dispatch(keys, func(w Writer) error { return w.WriteArtists(artists) })
+}
+
+// WriteLabel function passes a label as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteLabel(label model.Label) error {
+	return c.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function passes a slice of labels as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteLabels(labels []model.Label) error {
+	keys := make([]string, 0, len(labels))
+	for _, l := range labels {
+		keys = append(keys, "label:"+l.ID)
+	}
+
+	return c.dispatch(keys, func(w Writer) error { return w.WriteLabels(labels) })
+}
+
+// WriteMaster function passes a master as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteMaster(master model.Master) error {
+	return c.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function passes a slice of masters as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteMasters(masters []model.Master) error {
+	keys := make([]string, 0, len(masters))
+	for _, m := range masters {
+		keys = append(keys, "master:"+m.ID)
+	}
+
+	return c.dispatch(keys, func(w Writer) error { return w.WriteMasters(masters) })
+}
+
+// WriteRelease function passes a release as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteRelease(release model.Release) error {
+	return c.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function passes a slice of releases as a block to one of workers.
+func (c *ConcurrentDBWriter) WriteReleases(releases []model.Release) error {
+	keys := make([]string, 0, len(releases))
+	for _, r := range releases {
+		keys = append(keys, "release:"+r.ID)
+	}
+
+	return c.dispatch(keys, func(w Writer) error { return w.WriteReleases(releases) })
+}
+
+// Committed returns the number of blocks skipped by the decoder plus the number of blocks of the current run that are
+// committed, including all blocks before them. Blocks committed after a gap (a failed or a running block) are not
+// counted.
+func (c *ConcurrentDBWriter) Committed() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.skip + c.committed
+}
+
+// Skip sets the number of blocks skipped by the decoder before the run, blocks of the run are counted after them.
+func (c *ConcurrentDBWriter) Skip(blocks int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.skip = blocks
+}
+
+// Open executes PreLoad commands from options, creates staging tables (see Staging options) and starts workers.
+func (c *ConcurrentDBWriter) Open() error {
+	err := c.lc.Open()
+	if err != nil {
+		return err
+	}
+
+	c.start()
+	return nil
+}
+
+// Finish waits until all blocks are written and stops workers. Staging tables are swapped and PostLoad commands from
+// options are executed only when the run and all workers succeed, otherwise errors of workers are returned and
+// the next run creates staging tables again.
+func (c *ConcurrentDBWriter) Finish(err error) error {
+	c.stop()
+
+	c.mu.Lock()
+	wErr := combine(c.errs)
+	c.mu.Unlock()
+
+	if err != nil || wErr != nil {
+		c.lc.unstage()
+		return wErr
+	}
+
+	return c.lc.Finish(nil)
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// start starts workers of a new run, unless they are already running.
+func (c *ConcurrentDBWriter) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.running {
+		return
+	}
+
+	c.running = true
+	c.next = 0
+	c.committed = 0
+	c.done = make(map[int]bool)
+	c.inFlight = make(map[string]int)
+	c.errs = nil
+
+	c.jobs = make(chan job)
+	for i := 0; i < c.workers; i++ {
+		c.wg.Add(1)
+		go c.work(i)
+	}
+}
+
+// stop waits until all passed blocks are written and stops workers.
+func (c *ConcurrentDBWriter) stop() {
+	c.mu.Lock()
+	running := c.running
+	c.running = false
+	c.mu.Unlock()
+
+	if running {
+		close(c.jobs)
+		c.wg.Wait()
+	}
+}
+
+// dispatch passes the block to a free worker. When any of the block entities is being written by another worker,
+// the block waits until it's finished.
+func (c *ConcurrentDBWriter) dispatch(keys []string, write func(w Writer) error) error {
+	c.start()
+
+	c.mu.Lock()
+	if err := combine(c.errs); err != nil {
+		c.mu.Unlock()
+		return err
+	}
+
+	for c.busy(keys) {
+		c.cond.Wait()
+	}
+
+	for _, k := range keys {
+		c.inFlight[k]++
+	}
+
+	seq := c.next
+	c.next++
+	c.mu.Unlock()
+
+	c.jobs <- job{seq: seq, keys: keys, write: write}
+	return nil
+}
+
+func (c *ConcurrentDBWriter) busy(keys []string) bool {
+	for _, k := range keys {
+		if c.inFlight[k] > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// work writes blocks by the worker's own connection until the run is finished.
+func (c *ConcurrentDBWriter) work(id int) {
+	defer c.wg.Done()
+
+	conn, err := c.db.Conn(context.Background())
+	if err == nil {
+		defer conn.Close()
+	}
+
+	w := newDBWriter(conn, c.o)
+	for j := range c.jobs {
+		jErr := err
+		if jErr == nil {
+			jErr = j.write(w)
+		}
+
+		c.finish(id, j, jErr)
+	}
+}
+
+// finish records the result of the job, advances the committed blocks and releases entities of the block.
+func (c *ConcurrentDBWriter) finish(worker int, j job, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		c.errs = append(c.errs, WorkerError{Worker: worker, Block: c.skip + j.seq, Err: err})
+	} else {
+		c.done[j.seq] = true
+		for c.done[c.committed] {
+			delete(c.done, c.committed)
+			c.committed++
+		}
+	}
+
+	for _, k := range j.keys {
+		c.inFlight[k]--
+		if c.inFlight[k] == 0 {
+			delete(c.inFlight, k)
+		}
+	}
+
+	c.cond.Broadcast()
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConcurrentDBWriter_Options(t *testing.T) {
+	c := NewConcurrentDBWriter(nil, 4, &Options{Batch: Batch{Records: 100, Rows: 10}})
+	opt := c.Options()
+
+	if opt.Batch.Records != 0 || opt.Batch.Rows != 10 {
+		t.Error("each block should be committed in its own transaction, multi-row inserts should be kept")
+	}
+}
+
+func TestConcurrentDBWriter_WriteArtists(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewConcurrentDBWriter(db, 4, &Options{PostLoad: []string{"ANALYZE"}})
+	err := Open(w)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		err = w.WriteArtists([]model.Artist{{ID: strconv.Itoa(i % 5), Name: "Artist"}})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	err = Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c := w.(*ConcurrentDBWriter).Committed(); c != 20 {
+		t.Errorf("20 committed blocks expected, got %d", c)
+	}
+
+	statements := strings.Join(f.recorded(), "\n")
+	if strings.Count(statements, "BEGIN") != 20 || strings.Count(statements, "COMMIT") != 20 {
+		t.Error("each block should be written within its own transaction")
+	}
+
+	if !strings.HasSuffix(statements, "ANALYZE") {
+		t.Error("post load commands should be executed at the end")
+	}
+}
+
+func TestConcurrentDBWriter_Errors(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failOn("'broken'")
+
+	w := NewConcurrentDBWriter(db, 1, nil)
+	_ = w.WriteArtist(model.Artist{ID: "1"})
+	_ = w.WriteArtist(model.Artist{ID: "2"})
+	_ = w.WriteArtist(model.Artist{ID: "broken"})
+
+	err := Finish(w, nil)
+	var we WorkerError
+	if !errors.As(err, &we) || we.Worker != 0 || we.Block != 2 {
+		t.Errorf("worker error of the third block expected, got %v", err)
+	}
+
+	if c := w.(*ConcurrentDBWriter).Committed(); c != 2 {
+		t.Errorf("2 committed blocks expected, got %d", c)
+	}
+
+	err = w.WriteArtist(model.Artist{ID: "3"})
+	if err != nil {
+		t.Errorf("a new run should start without errors, got %v", err)
+	}
+
+	_ = Finish(w, nil)
+	if c := w.(*ConcurrentDBWriter).Committed(); c != 1 {
+		t.Errorf("1 committed block of the new run expected, got %d", c)
+	}
+}
+
+func TestConcurrentDBWriter_Resume(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failOn("'broken'")
+
+	w := NewConcurrentDBWriter(db, 2, nil)
+	c := w.(*ConcurrentDBWriter)
+
+	// each run skips committed blocks of previous runs, the same way as the decoder does
+	runs := [][]string{{"1", "2", "broken", "4"}, {"broken", "4"}, {"3", "4", "5"}}
+	expected := []int{2, 2, 5}
+	for i, ids := range runs {
+		Skip(w, c.Committed())
+		for _, id := range ids {
+			_ = w.WriteArtist(model.Artist{ID: id})
+		}
+
+		err := Finish(w, nil)
+		var we WorkerError
+		if ids[0] == "broken" && (!errors.As(err, &we) || we.Block != 2) {
+			t.Errorf("run %d: the block should be numbered from the beginning, got %v", i+1, err)
+		}
+
+		if got := c.Committed(); got != expected[i] {
+			t.Errorf("run %d: %d committed blocks expected, got %d", i+1, expected[i], got)
+		}
+	}
+}
+
+func TestConcurrentDBWriter_Busy(t *testing.T) {
+	c := NewConcurrentDBWriter(nil, 2, nil).(*ConcurrentDBWriter)
+	c.inFlight = map[string]int{"release:1": 1}
+
+	if !c.busy([]string{"release:2", "release:1"}) {
+		t.Error("release 1 is being written")
+	}
+
+	if c.busy([]string{"master:1", "release:2"}) {
+		t.Error("neither master 1 nor release 2 are being written")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strings"
+)
+
+// CopyFromFunc is a hook used by DBWriter for bulk loading of rows. The data reader provides all rows of the table in
+// PostgreSQL COPY text format (tab separated columns, one row per line and \N for NULL values), thus the function
+// should pass it to the driver specific implementation of COPY table (columns) FROM STDIN within the transaction.
+type CopyFromFunc func(tx *sql.Tx, table string, columns []string, data io.Reader) error
+
+// CopyWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// decoded data in the format of PostgreSQL COPY commands. Each written block results in one COPY section per table,
+// which is much faster to load than individual insert commands. The output can be executed by the psql client.
+//
+// The COPY command cannot update already existing rows, therefore the Mode option is not taken into account.
+type CopyWriter struct {
+	o   Options
+	w   io.Writer
+	b   bytes.Buffer
+	err error
+}
+
+// NewCopyWriter creates a new Writer instance based on the provided output writer (for instance a file).
+// Options with ExcludeImages or Exclude fields can be set when we don't want images (or other sub-collections) as
+// part of the final solution.
+// When this is not the case and we want images in the result COPY sections the Option can be omitted.
+func NewCopyWriter(output io.Writer, options *Options) Writer {
+	if options == nil {
+		options = &Options{}
+	}
+
+	return &CopyWriter{
+		o: *options,
+		w: output,
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (c *CopyWriter) Options() Options {
+	return c.o
+}
+
+// WriteArtist function writes an artist as a set of COPY sections into the output.
+func (c *CopyWriter) WriteArtist(artist model.Artist) error {
+	return c.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artists as a set of COPY sections into the output.
+func (c *CopyWriter) WriteArtists(artists []model.Artist) error {
+	var rows []row
+	for _, a := range artists {
+		rows = append(rows, artistRows(a, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteLabel function writes a label as a set of COPY sections into the output.
+func (c *CopyWriter) WriteLabel(label model.Label) error {
+	return c.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of labels as a set of COPY sections into the output.
+func (c *CopyWriter) WriteLabels(labels []model.Label) error {
+	var rows []row
+	for _, l := range labels {
+		rows = append(rows, labelRows(l, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteMaster function writes a master as a set of COPY sections into the output.
+func (c *CopyWriter) WriteMaster(master model.Master) error {
+	return c.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of masters as a set of COPY sections into the output.
+func (c *CopyWriter) WriteMasters(masters []model.Master) error {
+	var rows []row
+	for _, m := range masters {
+		rows = append(rows, masterRows(m, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteRelease function writes a release as a set of COPY sections into the output.
+func (c *CopyWriter) WriteRelease(release model.Release) error {
+	return c.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of releases as a set of COPY sections into the output.
+func (c *CopyWriter) WriteReleases(releases []model.Release) error {
+	var rows []row
+	for _, r := range releases {
+		rows = append(rows, releaseRows(r, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// Open writes PreLoad commands from options as a header of the output.
+func (c *CopyWriter) Open() error {
+	if c.err == nil {
+		_, c.err = io.WriteString(c.w, commands(c.o.PreLoad))
+	}
+
+	return c.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (c *CopyWriter) Flush() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	return flushOutput(c.w)
+}
+
+// Finish writes PostLoad commands from options as a footer of the output. The footer is omitted when the run fails.
+func (c *CopyWriter) Finish(err error) error {
+	if err == nil && c.err == nil {
+		_, c.err = io.WriteString(c.w, commands(c.o.PostLoad))
+	}
+
+	return c.err
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+func (c *CopyWriter) writeRows(rows []row) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	tables, groups := groupRows(rows)
+	for _, t := range tables {
+		c.b.WriteString(fmt.Sprintf("COPY %s (%s) FROM STDIN;\n", c.o.tableName(t), strings.Join(c.o.columns(t), ", ")))
+		writeCopyRows(&c.b, groups[t.name])
+		c.b.WriteString("\\.\n")
+	}
+
+	_, c.err = c.w.Write(c.b.Bytes())
+	c.b.Reset()
+
+	return c.err
+}
+
+// ----------------------------------------------- HELPER FUNCTIONS -----------------------------------------------
+
+var copyReplacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"\t", "\\t",
+	"\n", "\\n",
+	"\r", "\\r",
+)
+
+var arrayElementReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// writeCopyRows writes rows in the COPY text format into the buffer.
+func writeCopyRows(b *bytes.Buffer, rows []row) {
+	for _, r := range rows {
+		for i, v := range r.values {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(copyValue(v))
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// copyValue escapes the value for the COPY text format. Missing values are represented as \N and slices of strings
+// are transformed into PostgreSQL array literals.
+func copyValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return copyReplacer.Replace(val)
+	case []string:
+		return copyReplacer.Replace(arrayLiteral(val))
+	default:
+		return "\\N"
+	}
+}
+
+// arrayLiteral creates the PostgreSQL array literal with all elements quoted, such as {"a","b"}.
+func arrayLiteral(values []string) string {
+	sb := strings.Builder{}
+	sb.WriteString("{")
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(`"`)
+		sb.WriteString(arrayElementReplacer.Replace(v))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+
+	return sb.String()
+}
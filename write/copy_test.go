@@ -0,0 +1,143 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCopyWriter_Options(t *testing.T) {
+	c := NewCopyWriter(nil, nil)
+	opt := c.Options()
+
+	if opt.ExcludeImages {
+		t.Error("exclude images should be false as a default")
+	}
+}
+
+func TestCopyWriter_WriteArtist(t *testing.T) {
+	b := &strings.Builder{}
+	c := NewCopyWriter(b, nil)
+
+	err := c.WriteArtist(artists[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := b.String()
+	if expectedCopyArtist != got {
+		t.Error("copy output differs from what it's expected")
+	}
+}
+
+func TestCopyWriter_WriteMasters_ExcludeImages(t *testing.T) {
+	b := &strings.Builder{}
+	c := NewCopyWriter(b, &Options{ExcludeImages: true})
+
+	err := c.WriteMasters(masters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := b.String()
+	if strings.Contains(got, "COPY images") {
+		t.Error("images should be excluded")
+	}
+
+	if !strings.HasPrefix(got, "COPY masters (master_id, main_release, genres, styles, year, title, data_quality) FROM STDIN;\n"+
+		"18512\t33699\t{\"Electronic\"}\t{\"Tribal\",\"Techno\"}\t2002\tPsyche EP\tCorrect\n\\.\n") {
+		t.Error("copy output differs from what it's expected")
+	}
+}
+
+func TestCopyValue(t *testing.T) {
+	cases := map[string]interface{}{
+		`\N`:                   nil,
+		`a\\b\tc\nd\re`:        "a\\b\tc\nd\re",
+		`{"x\\\\\\"y","z"}`:    []string{`x\"y`, "z"},
+		`{}`:                   []string(nil),
+		`Knockin' Boots Vol 2`: "Knockin' Boots Vol 2",
+	}
+
+	for expected, v := range cases {
+		if got := copyValue(v); got != expected {
+			t.Errorf("copy value of %q should be %q, got %q", v, expected, got)
+		}
+	}
+}
+
+func TestDBWriter_CopyFrom(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	var tables []string
+	data := &strings.Builder{}
+	copyFrom := func(tx *sql.Tx, table string, columns []string, r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		tables = append(tables, table)
+		data.Write(b)
+		return err
+	}
+
+	w := NewDBWriter(db, &Options{CopyFrom: copyFrom})
+	err := w.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if strings.Join(tables, ",") != "artists,artist_aliases,artist_members" {
+		t.Errorf("unexpected copied tables %v", tables)
+	}
+
+	if !strings.HasPrefix(data.String(), "2\tMr. James Barth & A.D.\tCari Lekebusch & Alexi Delano\t") {
+		t.Error("copied data differ from what it's expected")
+	}
+
+	if strings.Join(f.recorded(), ",") != "BEGIN,COMMIT" {
+		t.Errorf("unexpected statements %v", f.recorded())
+	}
+}
+
+func TestDBWriter_CopyFrom_Error(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	copyErr := errors.New("copy failed")
+	w := NewDBWriter(db, &Options{CopyFrom: func(*sql.Tx, string, []string, io.Reader) error {
+		return copyErr
+	}})
+
+	err := w.WriteRelease(releases[0])
+	if err != copyErr {
+		t.Errorf("copy error should be returned, got %v", err)
+	}
+
+	if strings.Join(f.recorded(), ",") != "BEGIN,ROLLBACK" {
+		t.Errorf("unexpected statements %v", f.recorded())
+	}
+}
+
+// ------------------------------------------------------- DATA -------------------------------------------------------
+
+var expectedCopyArtist = `COPY artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) FROM STDIN;
+2	Mr. James Barth & A.D.	Cari Lekebusch & Alexi Delano		Correct	{"Mr Barth & A.D.","MR JAMES BARTH & A. D.","Mr. Barth & A.D.","Mr. James Barth & A. D."}	{}
+\.
+COPY artist_aliases (artist_id, alias_id, name) FROM STDIN;
+2	2470	Puente Latino
+2	19536	Yakari & Delano
+2	103709	Crushed Insect & The Sick Puppy
+2	384581	ADCL
+2	1779857	Alexi Delano & Cari Lekebusch
+\.
+COPY artist_members (artist_id, member_id, name) FROM STDIN;
+2	26	Alexi Delano
+2	27	Cari Lekebusch
+\.
+`
@@ -0,0 +1,269 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArrayEncoder transforms array fields (genres, styles, urls, etc.) into a single CSV value.
+type ArrayEncoder func(values []string) string
+
+// CSV options are used by CSV writer only. TSV switches the output into tab separated values, Array defines the
+// encoding of array fields and when it's not set the JSONArray encoder is used.
+type CSV struct {
+	TSV   bool
+	Array ArrayEncoder
+}
+
+// JSONArray encodes values as JSON array, such as ["Electronic","Techno"].
+func JSONArray(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// PostgresArray encodes values as PostgreSQL array literal, such as {"Electronic","Techno"}.
+func PostgresArray(values []string) string {
+	return arrayLiteral(values)
+}
+
+// JoinArray creates an encoder that joins values by the separator, such as Electronic|Techno.
+func JoinArray(separator string) ArrayEncoder {
+	return func(values []string) string {
+		return strings.Join(values, separator)
+	}
+}
+
+// CSVWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// decoded data in the CSV format. Data are split into the same tables as the SQL writer creates (artists,
+// artist_aliases, images, releases, release_tracks, ...), each table is written into its own output with a header
+// row. Values are quoted according to RFC 4180.
+type CSVWriter struct {
+	o      Options
+	open   func(table string) (io.Writer, error)
+	tables map[string]*csv.Writer
+	order  []io.Writer
+	err    error
+}
+
+// NewCSVWriter creates a new Writer instance that writes each table into its own file in the directory, such as
+// releases.csv or release_tracks.csv (.tsv files in the TSV mode). Files are created when the first row of the table
+// is written, they stay open across runs (the whole dump can be decoded file by file into the same writer) and they
+// have to be closed by the Close function.
+func NewCSVWriter(dir string, options *Options) Writer {
+	if options == nil {
+		options = &Options{}
+	}
+
+	ext := ".csv"
+	if options.CSV.TSV {
+		ext = ".tsv"
+	}
+
+	return NewCSVWriterFunc(func(table string) (io.Writer, error) {
+		return os.Create(filepath.Join(dir, table+ext))
+	}, options)
+}
+
+// NewCSVWriterFunc creates a new Writer instance that writes each table into the output opened by the function.
+// The function is called once per table, when the first row of the table is written. Outputs implementing
+// the io.Closer interface are closed by the Close function.
+func NewCSVWriterFunc(open func(table string) (io.Writer, error), options *Options) Writer {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	if o.CSV.Array == nil {
+		o.CSV.Array = JSONArray
+	}
+
+	return &CSVWriter{
+		o:      o,
+		open:   open,
+		tables: make(map[string]*csv.Writer),
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (c *CSVWriter) Options() Options {
+	return c.o
+}
+
+// WriteArtist function writes an artist as rows into CSV outputs.
+func (c *CSVWriter) WriteArtist(artist model.Artist) error {
+	return c.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artists as rows into CSV outputs.
+func (c *CSVWriter) WriteArtists(artists []model.Artist) error {
+	var rows []row
+	for _, a := range artists {
+		rows = append(rows, artistRows(a, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteLabel function writes a label as rows into CSV outputs.
+func (c *CSVWriter) WriteLabel(label model.Label) error {
+	return c.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of labels as rows into CSV outputs.
+func (c *CSVWriter) WriteLabels(labels []model.Label) error {
+	var rows []row
+	for _, l := range labels {
+		rows = append(rows, labelRows(l, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteMaster function writes a master as rows into CSV outputs.
+func (c *CSVWriter) WriteMaster(master model.Master) error {
+	return c.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of masters as rows into CSV outputs.
+func (c *CSVWriter) WriteMasters(masters []model.Master) error {
+	var rows []row
+	for _, m := range masters {
+		rows = append(rows, masterRows(m, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// WriteRelease function writes a release as rows into CSV outputs.
+func (c *CSVWriter) WriteRelease(release model.Release) error {
+	return c.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of releases as rows into CSV outputs.
+func (c *CSVWriter) WriteReleases(releases []model.Release) error {
+	var rows []row
+	for _, r := range releases {
+		rows = append(rows, releaseRows(r, c.o)...)
+	}
+
+	return c.writeRows(rows)
+}
+
+// Flush flushes outputs of all tables when they implement the Flusher interface, such as compressed outputs.
+func (c *CSVWriter) Flush() error {
+	for _, w := range c.order {
+		if c.err != nil {
+			break
+		}
+
+		c.err = flushOutput(w)
+	}
+
+	return c.err
+}
+
+// Close closes all opened outputs implementing the io.Closer interface. The first occurred error is returned.
+func (c *CSVWriter) Close() error {
+	var err error
+	for _, w := range c.order {
+		if cl, ok := w.(io.Closer); ok {
+			if cErr := cl.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}
+	}
+
+	c.order = nil
+	c.tables = make(map[string]*csv.Writer)
+
+	return err
+}
+
+// Finish flushes outputs at the end of the run. Outputs are kept open, so tables shared by more entity kinds (such as
+// images) are written by following runs into the same outputs, they have to be closed by the Close function.
+func (c *CSVWriter) Finish(error) error {
+	return c.Flush()
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+func (c *CSVWriter) writeRows(rows []row) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	tables, groups := groupRows(rows)
+	for _, t := range tables {
+		cw := c.table(t)
+		if c.err != nil {
+			return c.err
+		}
+
+		for _, r := range groups[t.name] {
+			c.err = cw.Write(c.record(r))
+			if c.err != nil {
+				return c.err
+			}
+		}
+
+		cw.Flush()
+		c.err = cw.Error()
+		if c.err != nil {
+			return c.err
+		}
+	}
+
+	return c.err
+}
+
+// table returns the CSV table output, when the table is used for the first time the output is opened and the header
+// row is written.
+func (c *CSVWriter) table(t table) *csv.Writer {
+	if cw, ok := c.tables[t.name]; ok {
+		return cw
+	}
+
+	var w io.Writer
+	w, c.err = c.open(t.name)
+	if c.err != nil {
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if c.o.CSV.TSV {
+		cw.Comma = '\t'
+	}
+
+	c.tables[t.name] = cw
+	c.order = append(c.order, w)
+	c.err = cw.Write(t.columns)
+
+	return cw
+}
+
+func (c *CSVWriter) record(r row) []string {
+	record := make([]string, len(r.values))
+	for i, v := range r.values {
+		switch val := v.(type) {
+		case string:
+			record[i] = val
+		case []string:
+			record[i] = c.o.CSV.Array(val)
+		}
+	}
+
+	return record
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCSVBuffers() (map[string]*strings.Builder, func(string) (io.Writer, error)) {
+	outputs := make(map[string]*strings.Builder)
+	return outputs, func(table string) (io.Writer, error) {
+		b := &strings.Builder{}
+		outputs[table] = b
+		return b, nil
+	}
+}
+
+func TestCSVWriter_Options(t *testing.T) {
+	c := NewCSVWriterFunc(nil, nil)
+	opt := c.Options()
+
+	if opt.ExcludeImages {
+		t.Error("exclude images should be false as a default")
+	}
+
+	if opt.CSV.TSV {
+		t.Error("TSV should be false as a default")
+	}
+
+	if opt.CSV.Array == nil {
+		t.Error("array encoder should be set as a default")
+	}
+}
+
+func TestCSVWriter_WriteMasters(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	c := NewCSVWriterFunc(open, nil)
+
+	err := c.WriteMasters(masters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(outputs) != 4 {
+		t.Errorf("4 tables should be written, got %d", len(outputs))
+	}
+
+	expected := "master_id,main_release,genres,styles,year,title,data_quality\n" +
+		"18512,33699,\"[\"\"Electronic\"\"]\",\"[\"\"Tribal\"\",\"\"Techno\"\"]\",2002,Psyche EP,Correct\n"
+	if got := outputs["masters"].String(); got != expected {
+		t.Errorf("masters output differs from what it's expected: %s", got)
+	}
+
+	if got := strings.Count(outputs["videos"].String(), "\n"); got != 4 {
+		t.Errorf("videos output should have a header and 3 rows, got %d lines", got)
+	}
+}
+
+func TestCSVWriter_WriteReleases_TSV(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	c := NewCSVWriterFunc(open, &Options{ExcludeImages: true, CSV: CSV{TSV: true, Array: JoinArray("|")}})
+
+	err := c.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = c.WriteRelease(releases[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := outputs["images"]; ok {
+		t.Error("images should be excluded")
+	}
+
+	tracks := "2\tA1\tA Sea Apart\t5:08\n2\tA2\tDutchmaster\t4:21\n2\tB1\tInner City Lullaby\t4:22\n2\tB2\tYeah Kid!\t4:46\n"
+	expected := "release_id\tposition\ttitle\tduration\n" + tracks + tracks
+	if got := outputs["release_tracks"].String(); got != expected {
+		t.Errorf("release tracks output differs from what it's expected: %s", got)
+	}
+
+	expected = "2\tVinyl\t1\t\t\"12\"\"|33 ⅓ RPM\"\n"
+	if got := outputs["release_formats"].String(); !strings.HasSuffix(got, expected) {
+		t.Errorf("release formats output differs from what it's expected: %s", got)
+	}
+}
+
+func TestCSVWriter_Directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discogs-csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewCSVWriter(dir, nil)
+	err = c.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = Close(c)
+	if err != nil {
+		t.Error(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "artist_members.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "artist_id,member_id,name\n2,26,Alexi Delano\n2,27,Cari Lekebusch\n"
+	if string(b) != expected {
+		t.Errorf("artist members file differs from what it's expected: %s", b)
+	}
+}
+
+func TestArrayEncoders(t *testing.T) {
+	values := []string{"Tribal", `Te"chno`}
+
+	if got := JSONArray(values); got != `["Tribal","Te\"chno"]` {
+		t.Errorf("unexpected JSON array %s", got)
+	}
+
+	if got := JSONArray(nil); got != `[]` {
+		t.Errorf("unexpected empty JSON array %s", got)
+	}
+
+	if got := PostgresArray(values); got != `{"Tribal","Te\"chno"}` {
+		t.Errorf("unexpected PostgreSQL array %s", got)
+	}
+
+	if got := JoinArray(";")(values); got != `Tribal;Te"chno` {
+		t.Errorf("unexpected joined array %s", got)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDBWriter_Lifecycle(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{
+		PreLoad:  []string{"TRUNCATE artists"},
+		PostLoad: []string{"CREATE INDEX artists_name ON artists(name)"},
+	})
+
+	_ = Open(w)
+	_ = Finish(w, errors.New("decoding failed"))
+	_ = Finish(w, nil)
+
+	expected := "TRUNCATE artists,CREATE INDEX artists_name ON artists(name)"
+	if got := strings.Join(f.recorded(), ","); got != expected {
+		t.Errorf("unexpected statements %s", got)
+	}
+}
+
+func TestDBWriter_WriteReleases_Error(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failOn("INSERT INTO release_tracks")
+
+	w := NewDBWriter(db, nil)
+	err := w.WriteReleases(releases)
+	if err == nil || err.Error() != "statement failed" {
+		t.Errorf("failed track insert should be returned, got %v", err)
+	}
+
+	statements := f.recorded()
+	if last := statements[len(statements)-1]; last != "ROLLBACK" {
+		t.Errorf("the block should be rolled back, got %s", last)
+	}
+
+	for _, s := range statements {
+		if s == "COMMIT" || strings.HasPrefix(s, "INSERT INTO release_identifiers") {
+			t.Errorf("no statement should follow the failed one, got %s", s)
+		}
+	}
+}
+
+func TestDBWriter_WriteArtists_BeginError(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failOn("BEGIN")
+
+	w := NewDBWriter(db, nil)
+	err := w.WriteArtists(artists)
+	if err == nil {
+		t.Error("failed begin should be returned")
+	}
+
+	if len(f.recorded()) != 1 {
+		t.Errorf("nothing should be executed after the failed begin, got %v", f.recorded())
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"strings"
+)
+
+// Documents options are used by DB writers only and switch them into the document mode.
+//
+// When Enabled is set, each entity is stored as a single JSON document (the same encoding as the JSON writer uses,
+// including excluded fields) in a table per entity: artists, labels, masters and releases, each with id and doc
+// columns. Child tables are not written at all, which makes loading much faster while the nested structure stays
+// queryable by JSON functions of the database. Documents are stored as JSONB in PostgreSQL, JSON in MySQL and TEXT
+// in SQLite.
+//
+// Columns are top level keys of documents (such as name, title or year) created as generated columns of tables whose
+// entity has the key. Generated columns are part of tables created by the TableCommands function and they are
+// indexed by commands of the IndexCommands function.
+type Documents struct {
+	Enabled bool
+	Columns []string
+}
+
+var (
+	artistDocumentsTable = table{
+		name:    "artists",
+		key:     "id",
+		columns: []string{"id", "doc"},
+		types:   []string{"VARCHAR(10)", "JSONB"},
+	}
+	labelDocumentsTable = table{
+		name:    "labels",
+		key:     "id",
+		columns: []string{"id", "doc"},
+		types:   []string{"VARCHAR(10)", "JSONB"},
+	}
+	masterDocumentsTable = table{
+		name:    "masters",
+		key:     "id",
+		columns: []string{"id", "doc"},
+		types:   []string{"VARCHAR(10)", "JSONB"},
+	}
+	releaseDocumentsTable = table{
+		name:    "releases",
+		key:     "id",
+		columns: []string{"id", "doc"},
+		types:   []string{"VARCHAR(10)", "JSONB"},
+	}
+
+	// documentKeys are top level keys of documents with text values, which can be generated columns.
+	documentKeys = map[string][]string{
+		"artists":  {"name", "realName", "profile", "data_quality"},
+		"labels":   {"name", "contact_info", "profile", "data_quality"},
+		"masters":  {"main_release", "year", "title", "data_quality"},
+		"releases": {"status", "title", "country", "released", "notes", "data_quality", "master_id", "main_release"},
+	}
+)
+
+// documentTables lists all document tables.
+var documentTables = []table{artistDocumentsTable, labelDocumentsTable, masterDocumentsTable, releaseDocumentsTable}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// tables returns all tables written with options, document tables in the document mode. Generated columns are
+// indexed columns of document tables.
+func (o Options) tables() []table {
+	if !o.Documents.Enabled {
+		return allTables
+	}
+
+	tables := make([]table, 0, len(documentTables))
+	for _, t := range documentTables {
+		t.indexes = o.generated(t)
+		tables = append(tables, t)
+	}
+
+	return tables
+}
+
+// generated returns keys of generated columns of the document table.
+func (o Options) generated(t table) []string {
+	var keys []string
+	for _, c := range o.Documents.Columns {
+		for _, k := range documentKeys[t.name] {
+			if c == k {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+
+	return keys
+}
+
+// columnType returns the SQL type of the column in the dialect. Types are given by PostgreSQL, only types of documents
+// and arrays, which are JSON in MySQL, differ.
+func (o Options) columnType(typ string) string {
+	if o.Dialect == MySQL && strings.HasSuffix(typ, "[]") {
+		return "JSON"
+	}
+
+	if typ != "JSONB" {
+		return typ
+	}
+
+	switch o.Dialect {
+	case MySQL:
+		return "JSON"
+	case SQLite:
+		return "TEXT"
+	default:
+		return typ
+	}
+}
+
+// generatedColumn returns the definition of the generated column with the value of the document key.
+func (o Options) generatedColumn(key string) string {
+	doc := o.column("doc")
+	switch o.Dialect {
+	case MySQL:
+		return fmt.Sprintf("%s VARCHAR(1024) GENERATED ALWAYS AS (%s ->> '$.%s') STORED", o.column(key), doc, key)
+	case SQLite:
+		return fmt.Sprintf("%s TEXT GENERATED ALWAYS AS (json_extract(%s, '$.%s')) STORED", o.column(key), doc, key)
+	default:
+		return fmt.Sprintf("%s TEXT GENERATED ALWAYS AS (%s ->> '%s') STORED", o.column(key), doc, key)
+	}
+}
+
+// documentRow returns the row of the document table with the entity encoded into JSON.
+func (o Options) documentRow(t table, id string, entity interface{}) (row, error) {
+	b, err := o.marshal(entity)
+	if err != nil {
+		return row{}, err
+	}
+
+	return row{table: t, values: []interface{}{id, string(b)}}, nil
+}
+
+// ----------------------------------------------- DB WRITER -----------------------------------------------
+
+// artist returns rows of the artist and commands deleting its child rows, or the document row in the document mode.
+func (db *DBWriter) artist(a model.Artist) ([]row, []string, error) {
+	if !db.o.Documents.Enabled {
+		return artistRows(a, db.o), db.o.deleteCommands(artistChildren, a.ID), nil
+	}
+
+	r, err := db.o.documentRow(artistDocumentsTable, a.ID, db.o.excludeArtist(a))
+	return []row{r}, nil, err
+}
+
+// label returns rows of the label and commands deleting its child rows, or the document row in the document mode.
+func (db *DBWriter) label(l model.Label) ([]row, []string, error) {
+	if !db.o.Documents.Enabled {
+		return labelRows(l, db.o), db.o.deleteCommands(labelChildren, l.ID), nil
+	}
+
+	r, err := db.o.documentRow(labelDocumentsTable, l.ID, db.o.excludeLabel(l))
+	return []row{r}, nil, err
+}
+
+// master returns rows of the master and commands deleting its child rows, or the document row in the document mode.
+func (db *DBWriter) master(m model.Master) ([]row, []string, error) {
+	if !db.o.Documents.Enabled {
+		return masterRows(m, db.o), db.o.deleteCommands(masterChildren, m.ID), nil
+	}
+
+	r, err := db.o.documentRow(masterDocumentsTable, m.ID, db.o.excludeMaster(m))
+	return []row{r}, nil, err
+}
+
+// release returns rows of the release and commands deleting its child rows, or the document row in the document
+// mode.
+func (db *DBWriter) release(r model.Release) ([]row, []string, error) {
+	if !db.o.Documents.Enabled {
+		return releaseRows(r, db.o), db.o.deleteCommands(releaseChildren, r.ID), nil
+	}
+
+	d, err := db.o.documentRow(releaseDocumentsTable, r.ID, db.o.excludeRelease(r))
+	return []row{d}, nil, err
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDBWriter_Documents(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{Mode: Upsert, Exclude: Images, Documents: Documents{Enabled: true}})
+	err := w.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	statements := f.recorded()
+	if len(statements) != 2+len(artists) {
+		t.Fatalf("one insert per artist expected, got %v", statements)
+	}
+
+	insert := statements[1]
+	prefix, suffix := "INSERT INTO artists (id, doc) VALUES ('2', '", "') ON CONFLICT (id) DO UPDATE SET doc = EXCLUDED.doc"
+	if !strings.HasPrefix(insert, prefix) || !strings.HasSuffix(insert, suffix) {
+		t.Fatalf("unexpected statement %s", insert)
+	}
+
+	doc := strings.ReplaceAll(strings.TrimSuffix(strings.TrimPrefix(insert, prefix), suffix), "''", "'")
+	a := model.Artist{}
+	err = json.Unmarshal([]byte(doc), &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.ID != "2" || a.Name != artists[0].Name || len(a.Aliases) != len(artists[0].Aliases) {
+		t.Errorf("document should contain the artist, got %+v", a)
+	}
+
+	if len(a.Images) != 0 {
+		t.Error("excluded images should not be part of the document")
+	}
+}
+
+func TestDBWriter_Documents_CopyFrom(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	var tables []string
+	var data string
+	copyFrom := func(tx *sql.Tx, table string, columns []string, r io.Reader) error {
+		b, err := ioutil.ReadAll(r)
+		tables = append(tables, table+" ("+strings.Join(columns, ", ")+")")
+		data = string(b)
+		return err
+	}
+
+	w := NewDBWriter(db, &Options{CopyFrom: copyFrom, Documents: Documents{Enabled: true}})
+	err := w.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if strings.Join(tables, ", ") != "releases (id, doc)" {
+		t.Errorf("documents should be copied into the releases table, got %v", tables)
+	}
+
+	if strings.Count(data, "\n") != len(releases) || !strings.HasPrefix(data, releases[0].ID+"\t{") {
+		t.Errorf("unexpected data %s", data)
+	}
+}
+
+func TestTableCommands_Documents(t *testing.T) {
+	o := &Options{Documents: Documents{Enabled: true, Columns: []string{"name", "title", "year"}}}
+
+	cmds := TableCommands(o)
+	if strings.Join(cmds, "\n") != strings.Join(createDocuments, "\n") {
+		t.Errorf("unexpected commands %v", cmds)
+	}
+
+	indexes := IndexCommands(o)
+	if len(indexes) != 5 || indexes[4] != "CREATE INDEX releases_title ON releases (title)" {
+		t.Errorf("generated columns should be indexed, got %v", indexes)
+	}
+
+	o.Dialect = MySQL
+	if cmd := TableCommands(o)[2]; cmd != createMasterDocumentsMySQL {
+		t.Errorf("unexpected command %s", cmd)
+	}
+
+	o.Dialect = SQLite
+	if cmd := TableCommands(o)[0]; cmd != createArtistDocumentsSQLite {
+		t.Errorf("unexpected command %s", cmd)
+	}
+}
+
+func TestDBWriter_Documents_Staging(t *testing.T) {
+	o := Options{Documents: Documents{Enabled: true, Columns: []string{"title"}}, Staging: Staging{Suffix: "_staging"}}
+
+	stage := o.stageCommands()
+	if len(stage) != 8 || stage[7] != "CREATE TABLE releases_staging (id VARCHAR(10), doc JSONB, "+
+		"title TEXT GENERATED ALWAYS AS (doc ->> 'title') STORED)" {
+		t.Errorf("unexpected staging commands %v", stage)
+	}
+
+	swap, _ := o.swapCommands()
+	expected := "DROP TABLE masters\nALTER TABLE masters_staging RENAME TO masters\n" +
+		"ALTER INDEX masters_staging_title RENAME TO masters_title"
+	if !strings.Contains(strings.Join(swap, "\n"), expected) {
+		t.Errorf("unexpected swap commands %v", swap)
+	}
+}
+
+// ---------------------------------------------------- DATA ----------------------------------------------------
+
+var createDocuments = []string{
+	"CREATE TABLE artists (id VARCHAR(10), doc JSONB, name TEXT GENERATED ALWAYS AS (doc ->> 'name') STORED)",
+	"CREATE TABLE labels (id VARCHAR(10), doc JSONB, name TEXT GENERATED ALWAYS AS (doc ->> 'name') STORED)",
+	"CREATE TABLE masters (id VARCHAR(10), doc JSONB, title TEXT GENERATED ALWAYS AS (doc ->> 'title') STORED, " +
+		"year TEXT GENERATED ALWAYS AS (doc ->> 'year') STORED)",
+	"CREATE TABLE releases (id VARCHAR(10), doc JSONB, title TEXT GENERATED ALWAYS AS (doc ->> 'title') STORED)",
+}
+
+const createMasterDocumentsMySQL = "CREATE TABLE masters (id VARCHAR(10), doc JSON, " +
+	"title VARCHAR(1024) GENERATED ALWAYS AS (doc ->> '$.title') STORED, " +
+	"year VARCHAR(1024) GENERATED ALWAYS AS (doc ->> '$.year') STORED)"
+
+const createArtistDocumentsSQLite = "CREATE TABLE artists (id VARCHAR(10), doc TEXT, " +
+	"name TEXT GENERATED ALWAYS AS (json_extract(doc, '$.name')) STORED)"
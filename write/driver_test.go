@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// fakeDB is an in memory database/sql driver that records all executed statements. When the fail function is set,
+// it's called with each statement (including BEGIN and COMMIT) and the returned error is injected as a failure.
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []string
+	fail       func(stmt string) error
+}
+
+// failOn returns the fail function failing all statements containing the text.
+func failOn(text string) func(string) error {
+	return func(stmt string) error {
+		if strings.Contains(stmt, text) {
+			return errors.New("statement failed")
+		}
+
+		return nil
+	}
+}
+
+// failTimes returns the fail function failing statements containing the text by the error for the first n times.
+func failTimes(text string, n int, err error) func(string) error {
+	var mu sync.Mutex
+	return func(stmt string) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if n > 0 && strings.Contains(stmt, text) {
+			n--
+			return err
+		}
+
+		return nil
+	}
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{}
+	return f, sql.OpenDB(fakeConnector{f: f})
+}
+
+// record records the statement and returns the injected failure, if there is any.
+func (f *fakeDB) record(stmt string) error {
+	f.mu.Lock()
+	f.statements = append(f.statements, stmt)
+	fail := f.fail
+	f.mu.Unlock()
+
+	if fail == nil {
+		return nil
+	}
+
+	return fail(stmt)
+}
+
+func (f *fakeDB) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.statements...)
+}
+
+type fakeConnector struct {
+	f *fakeDB
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{f: c.f}, nil
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fake driver opens connections by the connector only")
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	err := c.f.record("BEGIN")
+	if err != nil {
+		return nil, err
+	}
+
+	return fakeTx{f: c.f}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	err := c.f.record(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+type fakeTx struct {
+	f *fakeDB
+}
+
+func (t fakeTx) Commit() error {
+	return t.f.record("COMMIT")
+}
+
+func (t fakeTx) Rollback() error {
+	return t.f.record("ROLLBACK")
+}
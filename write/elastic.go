@@ -0,0 +1,421 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+const defaultTemplateName = "discogs"
+
+// Elastic options are used by Elasticsearch writers only.
+//
+// Index returns the name of the index for the entity kind (artists, labels, masters or releases), the kind itself is
+// used when it's not set. Template is the body of the index template (mappings and settings), which is created under
+// the TemplateName (discogs by default) when the writer is opened. Header is added to all requests, such as
+// the Authorization header, and Client is used to send them (http.DefaultClient by default). OnRefused is called
+// with documents refused by the bulk request instead of returning them as BulkError, thus the run continues with
+// the next block.
+type Elastic struct {
+	Index        func(kind string) string
+	Template     string
+	TemplateName string
+	Header       http.Header
+	Client       *http.Client
+	OnRefused    func(err *BulkError)
+}
+
+// HTTPStatusError is returned when the request fails with an unexpected HTTP status.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// BulkItemError describes a document refused by the bulk request.
+type BulkItemError struct {
+	Index  string
+	ID     string
+	Status int
+	Type   string
+	Reason string
+}
+
+// BulkError is returned when some documents of the bulk request are refused, other documents of the request are
+// indexed. As any other error returned by the writer, it stops the run (see the Elastic OnRefused option).
+type BulkError struct {
+	Items []BulkItemError
+}
+
+func (e *BulkError) Error() string {
+	i := e.Items[0]
+	return fmt.Sprintf("%d documents failed, first %s/%s with status %d: %s: %s", len(e.Items), i.Index, i.ID,
+		i.Status, i.Type, i.Reason)
+}
+
+// IsTransientHTTP returns true for timeouts, refused or reset connections, connections closed in the middle
+// of the response and for statuses 429 (too many requests), 502, 503 and 504. Other failures of the HTTP transport,
+// such as malformed URLs, unknown hosts or TLS errors, are not transient.
+func IsTransientHTTP(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var se *HTTPStatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+
+	switch se.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// ElasticWriter is one of few provided writers that implements the Writer interface and provides the ability to index
+// decoded data in Elasticsearch or OpenSearch by the _bulk API. Each written block results in one bulk request
+// of newline delimited JSON, each document is preceded by the index action with the entity ID, thus repeated imports
+// replace documents.
+type ElasticWriter struct {
+	o      Options
+	url    string
+	client *http.Client
+	w      io.Writer
+	retry  RetryPolicy
+	err    error
+}
+
+// NewElasticWriter creates a new Writer instance that sends bulk requests to the endpoint, such as
+// http://localhost:9200. Failed requests and documents refused due to the full queue (429 status) are retried
+// according to the Retry options, transient errors are recognised by the IsTransientHTTP function by default.
+// Documents refused for other reasons are reported by the BulkError, which stops the run, unless the OnRefused
+// function is set in Elastic options.
+func NewElasticWriter(endpoint string, options *Options) Writer {
+	e := newElasticWriter(options)
+	e.url = strings.TrimSuffix(endpoint, "/")
+	e.client = e.o.Elastic.Client
+	if e.client == nil {
+		e.client = http.DefaultClient
+	}
+
+	return e
+}
+
+// NewElasticFileWriter creates a new Writer instance that writes bulk requests into the output (for instance a file)
+// for offline loading, for example by curl with the --data-binary option. The index template is not written.
+func NewElasticFileWriter(output io.Writer, options *Options) Writer {
+	e := newElasticWriter(options)
+	e.w = output
+
+	return e
+}
+
+func newElasticWriter(options *Options) *ElasticWriter {
+	if options == nil {
+		options = &Options{}
+	}
+
+	e := &ElasticWriter{o: *options, retry: options.Retry}
+	if e.retry.Transient == nil {
+		e.retry.Transient = IsTransientHTTP
+	}
+
+	if e.o.Elastic.TemplateName == "" {
+		e.o.Elastic.TemplateName = defaultTemplateName
+	}
+
+	return e
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (e *ElasticWriter) Options() Options {
+	return e.o
+}
+
+// WriteArtist function indexes an artist.
+func (e *ElasticWriter) WriteArtist(artist model.Artist) error {
+	return e.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function indexes a slice of artists by one bulk request.
+func (e *ElasticWriter) WriteArtists(artists []model.Artist) error {
+	var items []bulkItem
+	for _, a := range artists {
+		items = e.appendItem(items, "artists", a.ID, e.o.excludeArtist(a))
+	}
+
+	return e.bulk(items)
+}
+
+// WriteLabel function indexes a label.
+func (e *ElasticWriter) WriteLabel(label model.Label) error {
+	return e.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function indexes a slice of labels by one bulk request.
+func (e *ElasticWriter) WriteLabels(labels []model.Label) error {
+	var items []bulkItem
+	for _, l := range labels {
+		items = e.appendItem(items, "labels", l.ID, e.o.excludeLabel(l))
+	}
+
+	return e.bulk(items)
+}
+
+// WriteMaster function indexes a master.
+func (e *ElasticWriter) WriteMaster(master model.Master) error {
+	return e.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function indexes a slice of masters by one bulk request.
+func (e *ElasticWriter) WriteMasters(masters []model.Master) error {
+	var items []bulkItem
+	for _, m := range masters {
+		items = e.appendItem(items, "masters", m.ID, e.o.excludeMaster(m))
+	}
+
+	return e.bulk(items)
+}
+
+// WriteRelease function indexes a release.
+func (e *ElasticWriter) WriteRelease(release model.Release) error {
+	return e.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function indexes a slice of releases by one bulk request.
+func (e *ElasticWriter) WriteReleases(releases []model.Release) error {
+	var items []bulkItem
+	for _, r := range releases {
+		items = e.appendItem(items, "releases", r.ID, e.o.excludeRelease(r))
+	}
+
+	return e.bulk(items)
+}
+
+// Open creates the index template from options, it does nothing in the file mode.
+func (e *ElasticWriter) Open() error {
+	if e.err != nil || e.url == "" || e.o.Elastic.Template == "" {
+		return e.err
+	}
+
+	e.err = e.retry.do(func() error {
+		return e.request(http.MethodPut, "/_index_template/"+url.PathEscape(e.o.Elastic.TemplateName),
+			"application/json", []byte(e.o.Elastic.Template), nil)
+	})
+
+	return e.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (e *ElasticWriter) Flush() error {
+	if e.err != nil || e.w == nil {
+		return e.err
+	}
+
+	return flushOutput(e.w)
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// bulkItem is the action line and the document of the bulk request.
+type bulkItem struct {
+	index string
+	id    string
+	lines []byte
+}
+
+type bulkResponse struct {
+	Errors bool                       `json:"errors"`
+	Items  []map[string]bulkItemState `json:"items"`
+}
+
+type bulkItemState struct {
+	Index  string `json:"_index"`
+	ID     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+func (e *ElasticWriter) appendItem(items []bulkItem, kind, id string, doc interface{}) []bulkItem {
+	if e.err != nil {
+		return nil
+	}
+
+	index := kind
+	if e.o.Elastic.Index != nil {
+		index = e.o.Elastic.Index(kind)
+	}
+
+	action, err := json.Marshal(map[string]map[string]string{"index": {"_index": index, "_id": id}})
+	if err != nil {
+		e.err = err
+		return nil
+	}
+
+	d, err := e.o.marshal(doc)
+	if err != nil {
+		e.err = err
+		return nil
+	}
+
+	lines := append(append(append(action, '\n'), d...), '\n')
+	return append(items, bulkItem{index: index, id: id, lines: lines})
+}
+
+// bulk writes items into the output in the file mode, otherwise it sends the bulk request. Items refused due to
+// the full queue are sent again until attempts of the retry policy are exhausted.
+func (e *ElasticWriter) bulk(items []bulkItem) error {
+	if e.err != nil || len(items) == 0 {
+		return e.err
+	}
+
+	if e.w != nil {
+		_, e.err = e.w.Write(bulkBody(items))
+		return e.err
+	}
+
+	var failed []BulkItemError
+	for attempt := 1; ; attempt++ {
+		var retry []bulkItem
+		var refused []BulkItemError
+		err := e.retry.do(func() error {
+			var rErr error
+			retry, refused, rErr = e.send(items)
+			return rErr
+		})
+
+		if err != nil {
+			e.err = err
+			return e.err
+		}
+
+		failed = append(failed, refused...)
+		if len(retry) == 0 {
+			break
+		}
+
+		if attempt >= e.retry.MaxAttempts {
+			for _, i := range retry {
+				failed = append(failed, BulkItemError{Index: i.index, ID: i.id, Status: http.StatusTooManyRequests,
+					Type: "too_many_requests", Reason: "attempts exhausted"})
+			}
+
+			break
+		}
+
+		e.retry.wait(attempt)
+		items = retry
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if e.o.Elastic.OnRefused != nil {
+		e.o.Elastic.OnRefused(&BulkError{Items: failed})
+		return nil
+	}
+
+	return &BulkError{Items: failed}
+}
+
+// send sends the bulk request and returns items which should be retried and items which are refused.
+func (e *ElasticWriter) send(items []bulkItem) ([]bulkItem, []BulkItemError, error) {
+	var resp bulkResponse
+	err := e.request(http.MethodPost, "/_bulk", "application/x-ndjson", bulkBody(items), &resp)
+	if err != nil || !resp.Errors {
+		return nil, nil, err
+	}
+
+	if len(resp.Items) != len(items) {
+		return nil, nil, fmt.Errorf("bulk response contains %d items instead of %d", len(resp.Items), len(items))
+	}
+
+	var retry []bulkItem
+	var refused []BulkItemError
+	for i, ri := range resp.Items {
+		for _, state := range ri {
+			switch {
+			case state.Error == nil:
+			case state.Status == http.StatusTooManyRequests:
+				retry = append(retry, items[i])
+			default:
+				refused = append(refused, BulkItemError{Index: items[i].index, ID: items[i].id, Status: state.Status,
+					Type: state.Error.Type, Reason: state.Error.Reason})
+			}
+		}
+	}
+
+	return retry, refused, nil
+}
+
+// request sends the request with the body and decodes the JSON response into the result, when it's not nil.
+func (e *ElasticWriter) request(method, path, contentType string, data []byte, result interface{}) error {
+	req, err := http.NewRequest(method, e.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range e.o.Elastic.Header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(b, result)
+}
+
+// bulkBody joins lines of items into the bulk request body.
+func bulkBody(items []bulkItem) []byte {
+	b := &bytes.Buffer{}
+	for _, i := range items {
+		b.Write(i.lines)
+	}
+
+	return b.Bytes()
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeElastic is the stand-in of the Elasticsearch _bulk API. Documents with IDs in the refuse map are refused with
+// the status, the status is used for the given number of attempts, then documents are indexed.
+type fakeElastic struct {
+	mu       sync.Mutex
+	requests []string
+	headers  []http.Header
+	docs     map[string]string
+	refuse   map[string]int
+	attempts map[string]int
+	down     int
+}
+
+func newFakeElastic() (*fakeElastic, *httptest.Server) {
+	f := &fakeElastic{docs: make(map[string]string), refuse: make(map[string]int), attempts: make(map[string]int)}
+	return f, httptest.NewServer(f)
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, _ := ioutil.ReadAll(r.Body)
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Content-Type")+"\n"+string(b))
+	f.headers = append(f.headers, r.Header)
+
+	if f.down > 0 {
+		f.down--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path != "/_bulk" {
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+		return
+	}
+
+	resp := bulkResponse{}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		var action map[string]map[string]string
+		_ = json.Unmarshal([]byte(lines[i]), &action)
+		index, id := action["index"]["_index"], action["index"]["_id"]
+
+		state := bulkItemState{Index: index, ID: id, Status: http.StatusCreated}
+		key := index + "/" + id
+		if status, ok := f.refuse[id]; ok && f.attempts[id] > 0 {
+			f.attempts[id]--
+			resp.Errors = true
+			state.Status = status
+			state.Error = &struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			}{Type: "refused", Reason: "refused by the test"}
+		} else {
+			f.docs[key] = lines[i+1]
+		}
+
+		resp.Items = append(resp.Items, map[string]bulkItemState{"index": state})
+	}
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func TestElasticWriter_WriteReleases(t *testing.T) {
+	f, server := newFakeElastic()
+	defer server.Close()
+
+	o := &Options{Elastic: Elastic{
+		Index:    func(kind string) string { return "discogs-" + kind },
+		Template: `{"index_patterns":["discogs-*"]}`,
+		Header:   http.Header{"Authorization": []string{"ApiKey secret"}},
+	}}
+
+	w := NewElasticWriter(server.URL+"/", o)
+	err := Open(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(f.requests) != 2 || f.requests[0] != "PUT /_index_template/discogs application/json\n"+o.Elastic.Template {
+		t.Fatalf("the template should be created before the bulk request: %v", f.requests)
+	}
+
+	if !strings.HasPrefix(f.requests[1], "POST /_bulk application/x-ndjson\n") ||
+		f.headers[1].Get("Authorization") != "ApiKey secret" {
+		t.Errorf("unexpected bulk request %s", f.requests[1])
+	}
+
+	for _, r := range releases {
+		expected, _ := json.Marshal(r)
+		if f.docs["discogs-releases/"+r.ID] != string(expected) {
+			t.Errorf("release %s should be indexed", r.ID)
+		}
+	}
+}
+
+func TestElasticWriter_Retry(t *testing.T) {
+	f, server := newFakeElastic()
+	defer server.Close()
+
+	f.down = 1
+	f.refuse[releases[0].ID] = http.StatusTooManyRequests
+	f.attempts[releases[0].ID] = 1
+
+	w := NewElasticWriter(server.URL, &Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	err := w.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the failed request, the request with refused item and the retry of the refused item only
+	if len(f.requests) != 3 || strings.Count(f.requests[2], `{"index":`) != 1 {
+		t.Errorf("refused item should be retried alone: %v", f.requests)
+	}
+
+	if len(f.docs) != len(releases) {
+		t.Errorf("%d releases should be indexed, got %d", len(releases), len(f.docs))
+	}
+}
+
+func TestElasticWriter_BulkError(t *testing.T) {
+	f, server := newFakeElastic()
+	defer server.Close()
+
+	f.refuse[masters[0].ID] = http.StatusBadRequest
+	f.attempts[masters[0].ID] = 1
+
+	w := NewElasticWriter(server.URL, &Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	err := w.WriteMasters(masters)
+
+	var be *BulkError
+	if !errors.As(err, &be) || len(be.Items) != 1 || be.Items[0].ID != masters[0].ID ||
+		be.Items[0].Status != http.StatusBadRequest || be.Items[0].Index != "masters" {
+		t.Fatalf("refused master should be reported, got %v", err)
+	}
+
+	if len(f.requests) != 1 {
+		t.Errorf("refused item should not be retried, got %d requests", len(f.requests))
+	}
+
+	err = w.WriteArtists(artists)
+	if err != nil || len(f.docs) != 1 {
+		t.Errorf("writing should continue with the next block, got %v", err)
+	}
+}
+
+func TestElasticWriter_Failure(t *testing.T) {
+	f, server := newFakeElastic()
+	defer server.Close()
+
+	f.down = 5
+	w := NewElasticWriter(server.URL, &Options{Retry: RetryPolicy{MaxAttempts: 2}})
+	err := w.WriteLabels(labels)
+
+	var se *HTTPStatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable || len(f.requests) != 2 {
+		t.Errorf("request should fail after 2 attempts, got %v", err)
+	}
+}
+
+func TestElasticFileWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewElasticFileWriter(b, nil)
+	err := w.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	doc, _ := json.Marshal(artists[0])
+	expected := `{"index":{"_id":"2","_index":"artists"}}` + "\n" + string(doc) + "\n"
+	if b.String() != expected {
+		t.Errorf("bulk file differs from what it's expected\n%s\n%s", b, expected)
+	}
+}
+
+func TestIsTransientHTTP(t *testing.T) {
+	if !IsTransientHTTP(&HTTPStatusError{StatusCode: http.StatusTooManyRequests}) {
+		t.Error("too many requests should be transient")
+	}
+
+	if IsTransientHTTP(&HTTPStatusError{StatusCode: http.StatusBadRequest}) {
+		t.Error("bad request should not be transient")
+	}
+
+	_, err := http.Get("http://127.0.0.1:1")
+	if !IsTransientHTTP(err) {
+		t.Error("refused connection should be transient")
+	}
+
+	_, err = http.Get("ftp://127.0.0.1:1")
+	if err == nil || IsTransientHTTP(err) {
+		t.Errorf("unsupported protocol should not be transient, got %v", err)
+	}
+
+	hang := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-hang }))
+	defer s.Close()
+	defer close(hang)
+
+	_, err = (&http.Client{Timeout: 10 * time.Millisecond}).Get(s.URL)
+	if !IsTransientHTTP(err) {
+		t.Errorf("timeout should be transient, got %v", err)
+	}
+
+	if !IsTransientHTTP(&url.Error{Op: "Post", URL: s.URL, Err: io.ErrUnexpectedEOF}) {
+		t.Error("connection closed in the middle of the response should be transient")
+	}
+
+	certErr := errors.New("x509: certificate signed by unknown authority")
+	if IsTransientHTTP(&url.Error{Op: "Post", URL: s.URL, Err: certErr}) {
+		t.Error("certificate errors should not be transient")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+)
+
+// Fields is a set of entity sub-collections, which can be excluded from the output by the Exclude option.
+// Fields can be combined by the bitwise OR, for instance Images | Videos.
+type Fields uint
+
+// Fields constants represent sub-collections of entities. Images belong to all entities, videos to masters and
+// releases, urls to artists and labels, name variations to artists and identifiers, companies and extra artists to
+// releases only.
+const (
+	Images Fields = 1 << iota
+	Videos
+	Identifiers
+	Companies
+	ExtraArtists
+	Urls
+	NameVariations
+)
+
+// excludes returns true when any of the fields is excluded from writing. The ExcludeImages option is still
+// respected and means the same as excluding Images.
+func (o Options) excludes(f Fields) bool {
+	exclude := o.Exclude
+	if o.ExcludeImages {
+		exclude |= Images
+	}
+
+	return exclude&f != 0
+}
+
+// excludeArtist returns the artist without excluded fields. Slices are replaced, so the original artist is kept
+// untouched.
+func (o Options) excludeArtist(a model.Artist) model.Artist {
+	if o.excludes(Images) {
+		a.Images = nil
+	}
+
+	if o.excludes(Urls) {
+		a.Urls = nil
+	}
+
+	if o.excludes(NameVariations) {
+		a.NameVariations = nil
+	}
+
+	return a
+}
+
+// excludeLabel returns the label without excluded fields.
+func (o Options) excludeLabel(l model.Label) model.Label {
+	if o.excludes(Images) {
+		l.Images = nil
+	}
+
+	if o.excludes(Urls) {
+		l.Urls = nil
+	}
+
+	return l
+}
+
+// excludeMaster returns the master without excluded fields.
+func (o Options) excludeMaster(m model.Master) model.Master {
+	if o.excludes(Images) {
+		m.Images = nil
+	}
+
+	if o.excludes(Videos) {
+		m.Videos = nil
+	}
+
+	return m
+}
+
+// excludeRelease returns the release without excluded fields.
+func (o Options) excludeRelease(r model.Release) model.Release {
+	if o.excludes(Images) {
+		r.Images = nil
+	}
+
+	if o.excludes(Videos) {
+		r.Videos = nil
+	}
+
+	if o.excludes(Identifiers) {
+		r.Identifiers = nil
+	}
+
+	if o.excludes(Companies) {
+		r.Companies = nil
+	}
+
+	if o.excludes(ExtraArtists) {
+		r.ExtraArtists = nil
+	}
+
+	return r
+}
+
+// excludedTable returns true when all rows of the child table come from excluded fields.
+func (o Options) excludedTable(t table) bool {
+	switch t.name {
+	case imagesTable.name:
+		return o.excludes(Images)
+	case videosTable.name:
+		return o.excludes(Videos)
+	case releaseIdentifiersTable.name:
+		return o.excludes(Identifiers)
+	case releaseCompaniesTable.name:
+		return o.excludes(Companies)
+	default:
+		return false
+	}
+}
+
+// marshal encodes the record without excluded fields into JSON. Name variations and urls are always part of JSON
+// records of artists and labels (even when they are empty), so records with excluded ones are encoded by their
+// shadow types, which leave them out.
+func (o Options) marshal(record interface{}) ([]byte, error) {
+	switch r := record.(type) {
+	case model.Artist:
+		if o.excludes(NameVariations | Urls) {
+			return json.Marshal(o.artistRecord(r))
+		}
+	case model.Label:
+		if o.excludes(Urls) {
+			return json.Marshal(o.labelRecord(r))
+		}
+	}
+
+	return json.Marshal(record)
+}
+
+// artistRecord is the JSON record of model.Artist, name variations and urls are left out when they are nil.
+type artistRecord struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	RealName       string         `json:"realName"`
+	Images         []model.Image  `json:"images,omitempty"`
+	Profile        string         `json:"profile"`
+	DataQuality    string         `json:"data_quality"`
+	NameVariations *[]string      `json:"name_variations,omitempty"`
+	Urls           *[]string      `json:"urls,omitempty"`
+	Aliases        []model.Alias  `json:"aliases,omitempty"`
+	Members        []model.Member `json:"members,omitempty"`
+}
+
+// labelRecord is the JSON record of model.Label, urls are left out when they are nil.
+type labelRecord struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Images      []model.Image      `json:"images,omitempty"`
+	ContactInfo string             `json:"contact_info"`
+	Profile     string             `json:"profile"`
+	DataQuality string             `json:"data_quality"`
+	Urls        *[]string          `json:"urls,omitempty"`
+	ParentLabel *model.LabelLabel  `json:"parent_label,omitempty"`
+	SubLabels   []model.LabelLabel `json:"sub_labels,omitempty"`
+}
+
+// artistRecord returns the JSON record of the artist, excluded name variations and urls are left out.
+func (o Options) artistRecord(a model.Artist) artistRecord {
+	r := artistRecord{ID: a.ID, Name: a.Name, RealName: a.RealName, Images: a.Images, Profile: a.Profile,
+		DataQuality: a.DataQuality, Aliases: a.Aliases, Members: a.Members}
+	if !o.excludes(NameVariations) {
+		r.NameVariations = &a.NameVariations
+	}
+
+	if !o.excludes(Urls) {
+		r.Urls = &a.Urls
+	}
+
+	return r
+}
+
+// labelRecord returns the JSON record of the label, excluded urls are left out.
+func (o Options) labelRecord(l model.Label) labelRecord {
+	r := labelRecord{ID: l.ID, Name: l.Name, Images: l.Images, ContactInfo: l.ContactInfo, Profile: l.Profile,
+		DataQuality: l.DataQuality, ParentLabel: l.ParentLabel, SubLabels: l.SubLabels}
+	if !o.excludes(Urls) {
+		r.Urls = &l.Urls
+	}
+
+	return r
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"strings"
+	"testing"
+)
+
+func TestOptions_Excludes(t *testing.T) {
+	o := Options{ExcludeImages: true, Exclude: Videos | Urls}
+	if !o.excludes(Images) || !o.excludes(Videos) || !o.excludes(Urls) {
+		t.Error("images, videos and urls should be excluded")
+	}
+
+	if o.excludes(Companies) || o.excludes(Identifiers|NameVariations) {
+		t.Error("companies, identifiers and name variations should not be excluded")
+	}
+}
+
+func TestJSONWriter_WriteRelease_Exclude(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, &Options{Exclude: Images | Videos | Identifiers | Companies | ExtraArtists})
+
+	err := j.WriteRelease(releases[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	record := make(map[string]interface{})
+	err = json.Unmarshal([]byte(b.String()), &record)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, p := range []string{"images", "videos", "identifiers", "companies", "extra_artists"} {
+		if _, ok := record[p]; ok {
+			t.Errorf("property %s should be omitted", p)
+		}
+	}
+
+	if _, ok := record["track_list"]; !ok {
+		t.Error("track list should be kept")
+	}
+
+	if len(releases[0].Companies) == 0 {
+		t.Error("release of the caller should not be changed")
+	}
+}
+
+func TestSQLWriter_WriteRelease_Exclude(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert, Exclude: Videos | Identifiers | Companies | ExtraArtists})
+
+	err := s.WriteRelease(releases[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	sql := b.String()
+	for _, tbl := range []string{"videos", "release_identifiers", "release_companies"} {
+		if strings.Contains(sql, "INSERT INTO "+tbl+" ") || strings.Contains(sql, "DELETE FROM "+tbl+" ") {
+			t.Errorf("table %s should not be touched", tbl)
+		}
+	}
+
+	if strings.Count(sql, "INSERT INTO release_artists ") != len(releases[0].Artists) {
+		t.Error("extra artists should not be inserted")
+	}
+
+	if !strings.Contains(sql, "DELETE FROM release_artists WHERE release_id = '2' AND extra = 'false';") ||
+		!strings.Contains(sql, "INSERT INTO images ") {
+		t.Error("main release artists and images should be written, extra artists should be kept untouched")
+	}
+}
+
+func TestJSONWriter_WriteArtist_Exclude(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, &Options{Exclude: Urls | NameVariations})
+
+	err := j.WriteArtist(artists[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	a := artists[0]
+	a.Urls = nil
+	a.NameVariations = nil
+	expected, _ := json.Marshal(a)
+	expected = []byte(strings.Replace(string(expected), `"name_variations":null,"urls":null,`, "", 1))
+	if b.String() != string(expected) {
+		t.Errorf("name variations and urls should be omitted\n%s\n%s", b, expected)
+	}
+
+	b.Reset()
+	j = NewJSONWriter(b, &Options{Exclude: Urls})
+	err = j.WriteArtist(artists[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	a = artists[0]
+	a.Urls = nil
+	expected, _ = json.Marshal(a)
+	expected = []byte(strings.Replace(string(expected), `"urls":null,`, "", 1))
+	if b.String() != string(expected) {
+		t.Errorf("only urls should be omitted\n%s\n%s", b, expected)
+	}
+
+	b.Reset()
+	j = NewJSONWriter(b, nil)
+	err = j.WriteLabel(model.Label{ID: "1"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(b.String(), `"urls":null`) {
+		t.Errorf("urls should be kept when they are not excluded: %s", b)
+	}
+}
+
+func TestCSVWriter_WriteArtists_Exclude(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	c := NewCSVWriterFunc(open, &Options{Exclude: Urls | NameVariations | Images})
+
+	err := c.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := outputs["images"]; ok {
+		t.Error("images table should not be created")
+	}
+
+	if !strings.Contains(outputs["artists"].String(), ",[],[]\n") {
+		t.Errorf("empty name variations and urls expected, got %s", outputs["artists"].String())
+	}
+}
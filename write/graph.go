@@ -0,0 +1,642 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Labels of graph nodes.
+const (
+	ArtistNode  = "Artist"
+	LabelNode   = "Label"
+	MasterNode  = "Master"
+	ReleaseNode = "Release"
+)
+
+// Types of graph edges, each type connects nodes of the same labels.
+const (
+	AliasOf       = "ALIAS_OF"       // Artist -> Artist, from the lower ID to the higher one
+	MemberOf      = "MEMBER_OF"      // Artist (member) -> Artist (group)
+	SubLabelOf    = "SUB_LABEL_OF"   // Label (sub-label) -> Label (parent)
+	MasterArtist  = "MASTER_ARTIST"  // Master -> Artist, with the anv property
+	MainRelease   = "MAIN_RELEASE"   // Master -> Release
+	VersionOf     = "VERSION_OF"     // Release -> Master
+	ReleaseArtist = "RELEASE_ARTIST" // Release -> Artist, with the anv property
+	ExtraArtist   = "EXTRA_ARTIST"   // Release -> Artist, with role and anv properties
+	ReleaseLabel  = "RELEASE_LABEL"  // Release -> Label, with the catno property
+)
+
+// GraphWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// decoded data as a graph. Artists, labels, masters and releases are nodes (see node label constants) connected by
+// typed edges, such as aliases, group members, label parents, release artists, extra artist credits and release
+// labels (see edge type constants). Edges are created by the entity that refers to another one, the referred node
+// doesn't have to be written. Such nodes are written as stub nodes with the ID and empty properties by the Close
+// function, thus the graph of a part of the dump doesn't contain dangling edges.
+//
+// Nodes and edges are deduplicated across blocks and runs, for instance the sub-label edge is created by both
+// the parent label and the sub-label, but it's written only once. Keys of written nodes and edges are kept in memory
+// until the writer is closed. The output is completed by the Close function only, so more runs (such as artists
+// and then releases) are written into one graph.
+type GraphWriter struct {
+	o          Options
+	enc        graphEncoder
+	nodes      map[string]struct{}
+	edges      map[string]struct{}
+	refs       map[string]graphNode
+	properties map[string][]graphProperty
+	err        error
+}
+
+// NewNeo4jWriter creates a new Writer instance that writes CSV files for the neo4j-admin import tool into
+// the directory. Nodes of each label are written into their own file (artists.csv, labels.csv, ...) with the header
+// id:ID(Artist),name,...,:LABEL and edges of each type as well (alias_of.csv, release_artist.csv, ...) with
+// the header :START_ID(Release),:END_ID(Artist),...,:TYPE, array properties are separated by semicolons.
+// Files are created when the first row is written, and they have to be closed by the Close function.
+//
+// Profiles and notes contain new lines, so files have to be imported with the --multiline-fields=true option.
+func NewNeo4jWriter(dir string, options *Options) Writer {
+	return NewNeo4jWriterFunc(func(file string) (io.Writer, error) {
+		return os.Create(filepath.Join(dir, file+".csv"))
+	}, options)
+}
+
+// NewNeo4jWriterFunc creates a new Writer instance that writes each file for the neo4j-admin import tool into
+// the output opened by the function, see NewNeo4jWriter. The function is called once per file, when the first row
+// of the file is written. Outputs implementing the io.Closer interface are closed by the Close function.
+func NewNeo4jWriterFunc(open func(file string) (io.Writer, error), options *Options) Writer {
+	return newGraphWriter(&neo4jEncoder{open: open, files: make(map[string]*csv.Writer)}, options)
+}
+
+// NewGraphMLWriter creates a new Writer instance that writes the graph into the output as one GraphML document with
+// directed edges. Labels of nodes and types of edges are stored as label and type data, node IDs consist of
+// the label and the Discogs ID, such as Artist:2. The document is completed by the Close function.
+func NewGraphMLWriter(output io.Writer, options *Options) Writer {
+	return newGraphWriter(&graphMLEncoder{w: output}, options)
+}
+
+func newGraphWriter(enc graphEncoder, options *Options) *GraphWriter {
+	if options == nil {
+		options = &Options{}
+	}
+
+	g := &GraphWriter{o: *options, enc: enc}
+	g.reset()
+
+	return g
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (g *GraphWriter) Options() Options {
+	return g.o
+}
+
+// WriteArtist function writes an artist node and its edges.
+func (g *GraphWriter) WriteArtist(artist model.Artist) error {
+	return g.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artist nodes and their edges.
+func (g *GraphWriter) WriteArtists(artists []model.Artist) error {
+	for _, a := range artists {
+		g.writeArtist(a)
+	}
+
+	return g.flush()
+}
+
+// WriteLabel function writes a label node and its edges.
+func (g *GraphWriter) WriteLabel(label model.Label) error {
+	return g.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of label nodes and their edges.
+func (g *GraphWriter) WriteLabels(labels []model.Label) error {
+	for _, l := range labels {
+		g.writeLabel(l)
+	}
+
+	return g.flush()
+}
+
+// WriteMaster function writes a master node and its edges.
+func (g *GraphWriter) WriteMaster(master model.Master) error {
+	return g.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of master nodes and their edges.
+func (g *GraphWriter) WriteMasters(masters []model.Master) error {
+	for _, m := range masters {
+		g.writeMaster(m)
+	}
+
+	return g.flush()
+}
+
+// WriteRelease function writes a release node and its edges.
+func (g *GraphWriter) WriteRelease(release model.Release) error {
+	return g.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of release nodes and their edges.
+func (g *GraphWriter) WriteReleases(releases []model.Release) error {
+	for _, r := range releases {
+		g.writeRelease(r)
+	}
+
+	return g.flush()
+}
+
+// Flush flushes outputs when they implement the Flusher interface.
+func (g *GraphWriter) Flush() error {
+	if g.err != nil {
+		return g.err
+	}
+
+	g.err = g.enc.flushOutputs()
+	return g.err
+}
+
+// Close writes stub nodes of referred nodes that haven't been written, then it completes the GraphML document or
+// closes all opened outputs of Neo4j files implementing the io.Closer interface. The first occurred error is
+// returned. The writer can be used again after that, it starts a new graph.
+func (g *GraphWriter) Close() error {
+	g.writeStubs()
+	err := g.flush()
+	if cErr := g.enc.close(); err == nil {
+		err = cErr
+	}
+
+	g.reset()
+	g.err = err
+	return err
+}
+
+// Finish flushes outputs at the end of the run. The graph is kept open, so following runs can add nodes and edges
+// referring to nodes of previous runs, it has to be completed by the Close function.
+func (g *GraphWriter) Finish(error) error {
+	return g.Flush()
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// graphProperty is the property of the node or the edge, the value is either string or slice of strings.
+type graphProperty struct {
+	name  string
+	value interface{}
+}
+
+type graphNode struct {
+	label      string
+	id         string
+	properties []graphProperty
+}
+
+type graphEdge struct {
+	kind       string
+	start      graphNode
+	end        graphNode
+	properties []graphProperty
+}
+
+// graphEncoder writes nodes and edges in the particular format.
+type graphEncoder interface {
+	node(n graphNode) error
+	edge(e graphEdge) error
+	flush() error
+	flushOutputs() error
+	close() error
+}
+
+func (g *GraphWriter) writeArtist(a model.Artist) {
+	artist := g.node(ArtistNode, a.ID,
+		graphProperty{"name", a.Name},
+		graphProperty{"realName", a.RealName},
+		graphProperty{"profile", a.Profile},
+		graphProperty{"data_quality", a.DataQuality},
+	)
+
+	for _, alias := range a.Aliases {
+		start, end := artist, ref(ArtistNode, alias.ID)
+		if lessID(end.id, start.id) {
+			start, end = end, start
+		}
+
+		g.edge(AliasOf, start, end)
+	}
+
+	for _, m := range a.Members {
+		g.edge(MemberOf, ref(ArtistNode, m.ID), artist)
+	}
+}
+
+func (g *GraphWriter) writeLabel(l model.Label) {
+	label := g.node(LabelNode, l.ID,
+		graphProperty{"name", l.Name},
+		graphProperty{"contact_info", l.ContactInfo},
+		graphProperty{"profile", l.Profile},
+		graphProperty{"data_quality", l.DataQuality},
+	)
+
+	if l.ParentLabel != nil {
+		g.edge(SubLabelOf, label, ref(LabelNode, l.ParentLabel.ID))
+	}
+
+	for _, s := range l.SubLabels {
+		g.edge(SubLabelOf, ref(LabelNode, s.ID), label)
+	}
+}
+
+func (g *GraphWriter) writeMaster(m model.Master) {
+	master := g.node(MasterNode, m.ID,
+		graphProperty{"title", m.Title},
+		graphProperty{"year", m.Year},
+		graphProperty{"genres", m.Genres},
+		graphProperty{"styles", m.Styles},
+		graphProperty{"data_quality", m.DataQuality},
+	)
+
+	for _, a := range m.Artists {
+		g.edge(MasterArtist, master, ref(ArtistNode, a.ID), graphProperty{"anv", a.Anv})
+	}
+
+	g.edge(MainRelease, master, ref(ReleaseNode, m.MainRelease))
+}
+
+func (g *GraphWriter) writeRelease(r model.Release) {
+	release := g.node(ReleaseNode, r.ID,
+		graphProperty{"title", r.Title},
+		graphProperty{"status", r.Status},
+		graphProperty{"country", r.Country},
+		graphProperty{"released", r.Released},
+		graphProperty{"genres", r.Genres},
+		graphProperty{"styles", r.Styles},
+		graphProperty{"data_quality", r.DataQuality},
+	)
+
+	if r.MasterID != "0" {
+		g.edge(VersionOf, release, ref(MasterNode, r.MasterID))
+	}
+
+	for _, a := range r.Artists {
+		g.edge(ReleaseArtist, release, ref(ArtistNode, a.ID), graphProperty{"anv", a.Anv})
+	}
+
+	if !g.o.excludes(ExtraArtists) {
+		for _, a := range r.ExtraArtists {
+			g.edge(ExtraArtist, release, ref(ArtistNode, a.ID), graphProperty{"role", a.Role},
+				graphProperty{"anv", a.Anv})
+		}
+	}
+
+	for _, l := range r.Labels {
+		g.edge(ReleaseLabel, release, ref(LabelNode, l.ID), graphProperty{"catno", l.Category})
+	}
+}
+
+// node writes the node unless it's been written before and returns the node.
+func (g *GraphWriter) node(label, id string, properties ...graphProperty) graphNode {
+	n := graphNode{label: label, id: id, properties: properties}
+	key := label + ":" + id
+	if _, ok := g.nodes[key]; ok || g.err != nil {
+		return n
+	}
+
+	if _, ok := g.properties[label]; !ok {
+		g.properties[label] = properties
+	}
+
+	g.nodes[key] = struct{}{}
+	delete(g.refs, key)
+	g.err = g.enc.node(n)
+
+	return n
+}
+
+// refer remembers the node referred by the edge, unless it's been written.
+func (g *GraphWriter) refer(n graphNode) {
+	key := n.label + ":" + n.id
+	if _, ok := g.nodes[key]; !ok {
+		g.refs[key] = n
+	}
+}
+
+// writeStubs writes referred nodes that haven't been written, ordered by labels and IDs. Stub nodes have the same
+// properties as other nodes of the label, all of them empty.
+func (g *GraphWriter) writeStubs() {
+	stubs := make([]graphNode, 0, len(g.refs))
+	for _, n := range g.refs {
+		stubs = append(stubs, n)
+	}
+
+	sort.Slice(stubs, func(i, j int) bool {
+		if stubs[i].label != stubs[j].label {
+			return stubs[i].label < stubs[j].label
+		}
+
+		return lessID(stubs[i].id, stubs[j].id)
+	})
+
+	for _, n := range stubs {
+		for _, p := range g.properties[n.label] {
+			var empty interface{} = ""
+			if _, ok := p.value.([]string); ok {
+				empty = []string(nil)
+			}
+
+			n.properties = append(n.properties, graphProperty{name: p.name, value: empty})
+		}
+
+		g.node(n.label, n.id, n.properties...)
+	}
+}
+
+func (g *GraphWriter) reset() {
+	g.nodes = make(map[string]struct{})
+	g.edges = make(map[string]struct{})
+	g.refs = make(map[string]graphNode)
+	g.properties = make(map[string][]graphProperty)
+}
+
+// edge writes the edge unless it's been written before or one of nodes has no ID. The key of the edge contains
+// properties, so different credits of the same artist are different edges.
+func (g *GraphWriter) edge(kind string, start, end graphNode, properties ...graphProperty) {
+	if g.err != nil || start.id == "" || end.id == "" {
+		return
+	}
+
+	key := kind + "\x00" + start.id + "\x00" + end.id
+	for _, p := range properties {
+		key += "\x00" + p.value.(string)
+	}
+
+	if _, ok := g.edges[key]; ok {
+		return
+	}
+
+	g.edges[key] = struct{}{}
+	g.refer(start)
+	g.refer(end)
+	g.err = g.enc.edge(graphEdge{kind: kind, start: start, end: end, properties: properties})
+}
+
+func (g *GraphWriter) flush() error {
+	if g.err == nil {
+		g.err = g.enc.flush()
+	}
+
+	return g.err
+}
+
+// ref returns the node referred by the ID without properties.
+func ref(label, id string) graphNode {
+	return graphNode{label: label, id: id}
+}
+
+// lessID compares numeric IDs.
+func lessID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
+
+// ----------------------------------------------- NEO4J -----------------------------------------------
+
+type neo4jEncoder struct {
+	open  func(file string) (io.Writer, error)
+	files map[string]*csv.Writer
+	order []io.Writer
+}
+
+func (n *neo4jEncoder) node(node graphNode) error {
+	cw, err := n.file(strings.ToLower(node.label)+"s", func() []string {
+		header := []string{"id:ID(" + node.label + ")"}
+		return append(append(header, neo4jColumns(node.properties)...), ":LABEL")
+	})
+
+	if err != nil {
+		return err
+	}
+
+	record := append([]string{node.id}, neo4jValues(node.properties)...)
+	return cw.Write(append(record, node.label))
+}
+
+func (n *neo4jEncoder) edge(e graphEdge) error {
+	cw, err := n.file(strings.ToLower(e.kind), func() []string {
+		header := []string{":START_ID(" + e.start.label + ")", ":END_ID(" + e.end.label + ")"}
+		return append(append(header, neo4jColumns(e.properties)...), ":TYPE")
+	})
+
+	if err != nil {
+		return err
+	}
+
+	record := append([]string{e.start.id, e.end.id}, neo4jValues(e.properties)...)
+	return cw.Write(append(record, e.kind))
+}
+
+func (n *neo4jEncoder) flush() error {
+	for _, cw := range n.files {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *neo4jEncoder) flushOutputs() error {
+	for _, w := range n.order {
+		if err := flushOutput(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *neo4jEncoder) close() error {
+	var err error
+	for _, w := range n.order {
+		if cl, ok := w.(io.Closer); ok {
+			if cErr := cl.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}
+	}
+
+	n.order = nil
+	n.files = make(map[string]*csv.Writer)
+
+	return err
+}
+
+// file returns the CSV writer of the file, when the file is used for the first time the output is opened and
+// the header row is written.
+func (n *neo4jEncoder) file(name string, header func() []string) (*csv.Writer, error) {
+	if cw, ok := n.files[name]; ok {
+		return cw, nil
+	}
+
+	w, err := n.open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := csv.NewWriter(w)
+	n.files[name] = cw
+	n.order = append(n.order, w)
+
+	return cw, cw.Write(header())
+}
+
+func neo4jColumns(properties []graphProperty) []string {
+	columns := make([]string, len(properties))
+	for i, p := range properties {
+		columns[i] = p.name
+		if _, ok := p.value.([]string); ok {
+			columns[i] += ":string[]"
+		}
+	}
+
+	return columns
+}
+
+func neo4jValues(properties []graphProperty) []string {
+	values := make([]string, len(properties))
+	for i, p := range properties {
+		switch v := p.value.(type) {
+		case string:
+			values[i] = v
+		case []string:
+			values[i] = strings.Join(v, ";")
+		}
+	}
+
+	return values
+}
+
+// ----------------------------------------------- GRAPHML -----------------------------------------------
+
+// graphMLKeys are all properties of nodes and edges declared in the GraphML header.
+var graphMLKeys = []struct{ domain, name string }{
+	{"node", "label"},
+	{"node", "name"},
+	{"node", "realName"},
+	{"node", "contact_info"},
+	{"node", "profile"},
+	{"node", "title"},
+	{"node", "year"},
+	{"node", "status"},
+	{"node", "country"},
+	{"node", "released"},
+	{"node", "genres"},
+	{"node", "styles"},
+	{"node", "data_quality"},
+	{"edge", "type"},
+	{"edge", "role"},
+	{"edge", "anv"},
+	{"edge", "catno"},
+}
+
+type graphMLEncoder struct {
+	w       io.Writer
+	b       bytes.Buffer
+	started bool
+	closed  bool
+}
+
+func (g *graphMLEncoder) node(n graphNode) error {
+	g.start()
+	g.b.WriteString("    <node id=\"" + xmlAttrReplacer.Replace(n.label+":"+n.id) + "\">\n")
+	g.data("label", n.label)
+	g.properties(n.properties)
+	g.b.WriteString("    </node>\n")
+
+	return nil
+}
+
+func (g *graphMLEncoder) edge(e graphEdge) error {
+	g.start()
+	g.b.WriteString("    <edge source=\"" + xmlAttrReplacer.Replace(e.start.label+":"+e.start.id) +
+		"\" target=\"" + xmlAttrReplacer.Replace(e.end.label+":"+e.end.id) + "\">\n")
+	g.data("type", e.kind)
+	g.properties(e.properties)
+	g.b.WriteString("    </edge>\n")
+
+	return nil
+}
+
+func (g *graphMLEncoder) flush() error {
+	_, err := g.w.Write(g.b.Bytes())
+	g.b.Reset()
+
+	return err
+}
+
+func (g *graphMLEncoder) flushOutputs() error {
+	return flushOutput(g.w)
+}
+
+// close writes the end of the document, the empty graph is written when there are no nodes.
+func (g *graphMLEncoder) close() error {
+	if g.closed {
+		return nil
+	}
+
+	g.start()
+	g.closed = true
+	g.b.WriteString("  </graph>\n</graphml>\n")
+	g.started = false
+
+	return g.flush()
+}
+
+// start writes the header of the document with declarations of all keys.
+func (g *graphMLEncoder) start() {
+	if g.started {
+		return
+	}
+
+	g.started = true
+	g.closed = false
+	g.b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	g.b.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	for _, k := range graphMLKeys {
+		g.b.WriteString("  <key id=\"" + k.name + "\" for=\"" + k.domain + "\" attr.name=\"" + k.name +
+			"\" attr.type=\"string\"/>\n")
+	}
+
+	g.b.WriteString("  <graph id=\"discogs\" edgedefault=\"directed\">\n")
+}
+
+// properties writes non empty properties, array values are separated by semicolons.
+func (g *graphMLEncoder) properties(properties []graphProperty) {
+	for _, p := range properties {
+		switch v := p.value.(type) {
+		case string:
+			g.data(p.name, v)
+		case []string:
+			g.data(p.name, strings.Join(v, ";"))
+		}
+	}
+}
+
+func (g *graphMLEncoder) data(key, value string) {
+	if value != "" {
+		g.b.WriteString("      <data key=\"" + key + "\">" + xmlTextReplacer.Replace(value) + "</data>\n")
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"github.com/lukasaron/data-discogs/model"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNeo4jWriter_WriteReleases(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	g := NewNeo4jWriterFunc(open, nil)
+
+	err := g.WriteReleases(releases[:1])
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "id:ID(Release),title,status,country,released,genres:string[],styles:string[],data_quality,:LABEL\n" +
+		"2,Knockin' Boots Vol 2 Of 2,Accepted,Sweden,1998-06-00,Electronic,Broken Beat;Techno;Tech House,Correct," +
+		"Release\n"
+	if got := outputs["releases"].String(); got != expected {
+		t.Errorf("releases output differs from what it's expected: %s", got)
+	}
+
+	expected = ":START_ID(Release),:END_ID(Artist),role,anv,:TYPE\n" +
+		"2,26,\"Producer, Recorded By\",,EXTRA_ARTIST\n" +
+		"2,27,\"Producer, Recorded By\",,EXTRA_ARTIST\n" +
+		"2,26,Written-By,A. Delano,EXTRA_ARTIST\n" +
+		"2,27,Written-By,C. Lekebusch,EXTRA_ARTIST\n"
+	if got := outputs["extra_artist"].String(); got != expected {
+		t.Errorf("extra artists output differs from what it's expected: %s", got)
+	}
+
+	expected = ":START_ID(Release),:END_ID(Label),catno,:TYPE\n2,5,SK 026,RELEASE_LABEL\n2,5,SK026,RELEASE_LABEL\n"
+	if got := outputs["release_label"].String(); got != expected {
+		t.Errorf("release labels output differs from what it's expected: %s", got)
+	}
+}
+
+func TestNeo4jWriter_Deduplication(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	g := NewNeo4jWriterFunc(open, nil)
+
+	sub := model.Label{ID: "86537", Name: "Antidote", ParentLabel: &model.LabelLabel{ID: labels[0].ID}}
+	alias := model.Artist{ID: "2470", Name: "Puente Latino", Aliases: []model.Alias{{ID: artists[0].ID}}}
+
+	for _, err := range []error{
+		g.WriteLabels(labels),
+		g.WriteLabel(sub),
+		g.WriteLabels(labels),
+		g.WriteArtists(artists),
+		g.WriteArtist(alias),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := strings.Count(outputs["labels"].String(), "\n"); n != 3 {
+		t.Errorf("header and 2 labels should be written, got %d lines", n)
+	}
+
+	if strings.Count(outputs["sub_label_of"].String(), "86537,1,SUB_LABEL_OF\n") != 1 {
+		t.Errorf("sub-label edge should be written once: %s", outputs["sub_label_of"])
+	}
+
+	if strings.Count(outputs["alias_of"].String(), "2,2470,ALIAS_OF\n") != 1 {
+		t.Errorf("alias edge should be written once: %s", outputs["alias_of"])
+	}
+
+	if !strings.HasPrefix(outputs["member_of"].String(), ":START_ID(Artist),:END_ID(Artist),:TYPE\n26,2,MEMBER_OF\n") {
+		t.Errorf("members output differs from what it's expected: %s", outputs["member_of"])
+	}
+}
+
+func TestNeo4jWriter_Stubs(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	g := NewNeo4jWriterFunc(open, nil)
+
+	for _, err := range []error{
+		g.WriteArtists(artists),
+		Finish(g, nil),
+		g.WriteReleases(releases[:1]),
+		Finish(g, nil),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := strings.Count(outputs["artists"].String(), "\n"); n != 2 {
+		t.Errorf("only the written artist should be there before the writer is closed, got %d lines", n)
+	}
+
+	err := Close(g)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(outputs["artists"].String(), "Correct,Artist\n26,,,,,Artist\n27,,,,,Artist\n2470,,,,,Artist\n") {
+		t.Errorf("stub nodes of members should be written: %s", outputs["artists"])
+	}
+
+	expected := "id:ID(Label),:LABEL\n5,Label\n"
+	if got := outputs["labels"].String(); got != expected {
+		t.Errorf("stub node of the release label should be written: %s", got)
+	}
+
+	if got := outputs["masters"].String(); got != "id:ID(Master),:LABEL\n713738,Master\n" {
+		t.Errorf("stub node of the master should be written: %s", got)
+	}
+}
+
+func TestNeo4jWriter_ExcludeExtraArtists(t *testing.T) {
+	outputs, open := newCSVBuffers()
+	g := NewNeo4jWriterFunc(open, &Options{Exclude: ExtraArtists})
+
+	err := g.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := outputs["extra_artist"]; ok {
+		t.Error("extra artists should be excluded")
+	}
+}
+
+func TestGraphMLWriter(t *testing.T) {
+	b := &bytes.Buffer{}
+	g := NewGraphMLWriter(b, nil)
+
+	for _, err := range []error{
+		Open(g),
+		g.WriteMasters(masters),
+		Finish(g, nil),
+		Open(g),
+		g.WriteReleases(releases),
+		g.WriteReleases(releases),
+		Finish(g, nil),
+		Close(g),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	doc := b.String()
+	checkXML(t, doc)
+
+	if strings.Count(doc, "<?xml") != 1 || strings.Count(doc, "</graphml>") != 1 {
+		t.Error("runs should be written into one document, which is closed once")
+	}
+
+	if !strings.Contains(doc, "<node id=\"Master:18512\">") || !strings.Contains(doc, "<node id=\"Release:2\">") {
+		t.Error("master and release nodes should be written")
+	}
+
+	for _, m := range regexp.MustCompile(`(?:source|target)="([^"]+)"`).FindAllStringSubmatch(doc, -1) {
+		if strings.Count(doc, "<node id=\""+m[1]+"\">") != 1 {
+			t.Errorf("node %s of the edge should be written once", m[1])
+		}
+	}
+
+	edge := "    <edge source=\"Master:18512\" target=\"Artist:212070\">\n" +
+		"      <data key=\"type\">MASTER_ARTIST</data>\n" +
+		"    </edge>\n"
+	if !strings.Contains(doc, edge) {
+		t.Errorf("master artist edge should be written:\n%s", doc)
+	}
+
+	if !strings.Contains(doc, "<data key=\"title\">Knockin' Boots Vol 2 Of 2</data>") {
+		t.Error("release title should be written")
+	}
+}
+
+func TestGraphMLWriter_Empty(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := Close(NewGraphMLWriter(b, nil))
+	if err != nil {
+		t.Error(err)
+	}
+
+	checkXML(t, b.String())
+	if !strings.Contains(b.String(), "<graph id=\"discogs\" edgedefault=\"directed\">\n  </graph>") {
+		t.Errorf("empty graph should be written: %s", b)
+	}
+}
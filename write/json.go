@@ -11,18 +11,33 @@ import (
 	"io"
 )
 
+// JSON options are used by JSON writer only. Lines switches the output into newline delimited JSON (JSON Lines),
+// TypeField is the name of the property added to each record with the entity kind (artist, label, master, release),
+// which is useful when several entity kinds are mixed in one stream. The type field is omitted when it's empty.
+type JSON struct {
+	Lines     bool
+	TypeField string
+}
+
 // JSONWriter is one of few provided writers that implements the Writer interface and provides the ability to save
 // decoded data directly into JSON output.
 type JSONWriter struct {
-	o   Options
-	w   io.Writer
-	b   bytes.Buffer
-	err error
+	o       Options
+	w       io.Writer
+	b       bytes.Buffer
+	stream  bool
+	records int
+	err     error
 }
 
 // NewJSONWriter creates a new Writer instance based on the provided output writer (for instance a file).
-// Options with ExcludeImages can be set when we don't want images as part of the final solution.
+// Options with ExcludeImages or Exclude fields can be set when we don't want images (or other sub-collections) as
+// part of the final solution.
 // When this is not the case and we want images in the result JSON the Option can be omitted.
+//
+// By default each written slice results in one JSON array. When the writer is opened (see Opener interface), all
+// records written until the writer is finished are part of one global JSON array. When the JSON Lines option is set,
+// the output is newline delimited JSON (NDJSON) with exactly one record per line.
 func NewJSONWriter(output io.Writer, options *Options) Writer {
 
 	if options == nil {
@@ -43,11 +58,10 @@ func (j JSONWriter) Options() Options {
 
 // WriteArtist function writes an artist to the JSON output.
 func (j *JSONWriter) WriteArtist(a model.Artist) error {
-	if j.o.ExcludeImages {
-		a.Images = nil
-	}
+	a = j.o.excludeArtist(a)
 
-	j.marshalAndWrite(a)
+	j.writeDelimiter()
+	j.marshalAndWrite("artist", a)
 	j.flush()
 	j.clean()
 
@@ -61,11 +75,9 @@ func (j *JSONWriter) WriteArtists(artists []model.Artist) error {
 	for _, a := range artists {
 		j.writeDelimiter()
 
-		if j.o.ExcludeImages {
-			a.Images = nil
-		}
+		a = j.o.excludeArtist(a)
 
-		j.marshalAndWrite(a)
+		j.marshalAndWrite("artist", a)
 		if j.err != nil {
 			return j.err
 		}
@@ -80,11 +92,10 @@ func (j *JSONWriter) WriteArtists(artists []model.Artist) error {
 
 // WriteLabel function writes a label to the JSON output.
 func (j *JSONWriter) WriteLabel(label model.Label) error {
-	if j.o.ExcludeImages {
-		label.Images = nil
-	}
+	label = j.o.excludeLabel(label)
 
-	j.marshalAndWrite(label)
+	j.writeDelimiter()
+	j.marshalAndWrite("label", label)
 	j.flush()
 	j.clean()
 
@@ -98,11 +109,9 @@ func (j *JSONWriter) WriteLabels(labels []model.Label) error {
 	for _, l := range labels {
 		j.writeDelimiter()
 
-		if j.o.ExcludeImages {
-			l.Images = nil
-		}
+		l = j.o.excludeLabel(l)
 
-		j.marshalAndWrite(l)
+		j.marshalAndWrite("label", l)
 		if j.err != nil {
 			return j.err
 		}
@@ -117,11 +126,10 @@ func (j *JSONWriter) WriteLabels(labels []model.Label) error {
 
 // WriteMaster function writes a master to the JSON output.
 func (j *JSONWriter) WriteMaster(master model.Master) error {
-	if j.o.ExcludeImages {
-		master.Images = nil
-	}
+	master = j.o.excludeMaster(master)
 
-	j.marshalAndWrite(master)
+	j.writeDelimiter()
+	j.marshalAndWrite("master", master)
 	j.flush()
 	j.clean()
 
@@ -134,11 +142,9 @@ func (j *JSONWriter) WriteMasters(masters []model.Master) error {
 	for _, m := range masters {
 		j.writeDelimiter()
 
-		if j.o.ExcludeImages {
-			m.Images = nil
-		}
+		m = j.o.excludeMaster(m)
 
-		j.marshalAndWrite(m)
+		j.marshalAndWrite("master", m)
 		if j.err != nil {
 			return j.err
 		}
@@ -153,11 +159,10 @@ func (j *JSONWriter) WriteMasters(masters []model.Master) error {
 
 // WriteRelease function writes a release to the JSON output.
 func (j *JSONWriter) WriteRelease(release model.Release) error {
-	if j.o.ExcludeImages {
-		release.Images = nil
-	}
+	release = j.o.excludeRelease(release)
 
-	j.marshalAndWrite(release)
+	j.writeDelimiter()
+	j.marshalAndWrite("release", release)
 	j.flush()
 	j.clean()
 	return j.err
@@ -170,11 +175,9 @@ func (j *JSONWriter) WriteReleases(releases []model.Release) error {
 	for _, r := range releases {
 		j.writeDelimiter()
 
-		if j.o.ExcludeImages {
-			r.Images = nil
-		}
+		r = j.o.excludeRelease(r)
 
-		j.marshalAndWrite(r)
+		j.marshalAndWrite("release", r)
 		if j.err != nil {
 			return j.err
 		}
@@ -187,37 +190,116 @@ func (j *JSONWriter) WriteReleases(releases []model.Release) error {
 	return j.err
 }
 
+// Open starts the global JSON array, all records written until the writer is finished are its items.
+// Nothing is written in the JSON Lines mode.
+func (j *JSONWriter) Open() error {
+	j.stream = true
+	j.records = 0
+
+	if j.err == nil && !j.o.JSON.Lines {
+		_, j.err = j.w.Write([]byte("["))
+	}
+
+	return j.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (j *JSONWriter) Flush() error {
+	if j.err != nil {
+		return j.err
+	}
+
+	return flushOutput(j.w)
+}
+
+// Finish closes the global JSON array started by the Open function. The array is closed even when the run fails to
+// keep the output valid.
+func (j *JSONWriter) Finish(error) error {
+	if !j.stream {
+		return nil
+	}
+
+	j.stream = false
+	if j.err == nil && !j.o.JSON.Lines {
+		_, j.err = j.w.Write([]byte("]"))
+	}
+
+	return j.err
+}
+
 // ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
 
-func (j *JSONWriter) marshalAndWrite(d interface{}) {
+func (j *JSONWriter) marshalAndWrite(kind string, d interface{}) {
 	if j.err != nil {
 		return
 	}
 
-	b, err := json.Marshal(d)
+	b, err := j.o.marshal(d)
 	if err != nil {
 		j.err = err
 		return
 	}
 
+	if j.o.JSON.TypeField != "" {
+		b, j.err = j.withType(kind, b)
+		if j.err != nil {
+			return
+		}
+	}
+
 	_, j.err = j.b.Write(b)
+	if j.err == nil && j.o.JSON.Lines {
+		_, j.err = j.b.WriteString("\n")
+	}
+
+	if j.err == nil {
+		j.records++
+	}
+}
+
+// withType adds the type field as the first property of the marshalled JSON object.
+func (j *JSONWriter) withType(kind string, object []byte) ([]byte, error) {
+	field, err := json.Marshal(j.o.JSON.TypeField)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(object)+len(field)+len(value)+2)
+	b = append(b, '{')
+	b = append(b, field...)
+	b = append(b, ':')
+	b = append(b, value...)
+	if len(object) > 2 {
+		b = append(b, ',')
+	}
+
+	return append(b, object[1:]...), nil
 }
 
 func (j *JSONWriter) writeDelimiter() {
-	if j.err == nil && j.b.Len() > 1 {
+	if j.err != nil || j.o.JSON.Lines {
+		return
+	}
+
+	if (j.stream && j.records > 0) || (!j.stream && j.b.Len() > 1) {
 		_, j.err = j.b.WriteString(",")
 	}
 }
 
 func (j *JSONWriter) writeInitial() {
-	if j.err != nil {
+	if j.err != nil || j.stream || j.o.JSON.Lines {
 		return
 	}
 	_, j.err = j.b.WriteString("[")
 }
 
 func (j *JSONWriter) writeClosing() {
-	if j.err != nil {
+	if j.err != nil || j.stream || j.o.JSON.Lines {
 		return
 	}
 
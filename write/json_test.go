@@ -146,3 +146,110 @@ func TestJSONWriter_WriteReleases(t *testing.T) {
 		t.Error("json releases differ from json marshal expected solution")
 	}
 }
+
+func TestJSONWriter_Lines(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, &Options{JSON: JSON{Lines: true}})
+
+	err := j.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = j.WriteRelease(releases[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	ma, _ := json.Marshal(releases[0])
+	expected := string(ma) + "\n" + string(ma) + "\n"
+	get := b.String()
+	if expected != get {
+		t.Error("json lines differ from json marshal expected solution")
+	}
+}
+
+func TestJSONWriter_Lines_TypeField(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, &Options{JSON: JSON{Lines: true, TypeField: "type"}})
+
+	_ = j.WriteArtists(artists)
+	_ = j.WriteLabels(labels)
+	_ = j.WriteMaster(masters[0])
+	err := j.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	expected := []string{"artist", "label", "master", "release"}
+	if len(lines) != len(expected) {
+		t.Fatalf("%d lines expected, got %d", len(expected), len(lines))
+	}
+
+	for i, l := range lines {
+		record := make(map[string]interface{})
+		err = json.Unmarshal([]byte(l), &record)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if record["type"] != expected[i] {
+			t.Errorf("line %d should have type %s, got %v", i, expected[i], record["type"])
+		}
+
+		if record["id"] == nil {
+			t.Errorf("line %d should contain the record id", i)
+		}
+	}
+}
+
+func TestJSONWriter_TypeField_Array(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, &Options{JSON: JSON{TypeField: "kind"}})
+
+	err := j.WriteMasters(masters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var records []map[string]interface{}
+	err = json.Unmarshal([]byte(b.String()), &records)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(records) != 1 || records[0]["kind"] != "master" || records[0]["title"] != "Psyche EP" {
+		t.Errorf("unexpected records %v", records)
+	}
+}
+
+func TestJSONWriter_Lifecycle(t *testing.T) {
+	b := &strings.Builder{}
+	j := NewJSONWriter(b, nil)
+
+	err := Open(j)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_ = j.WriteArtists(artists)
+	_ = j.WriteLabel(labels[0])
+	_ = j.WriteArtists(nil)
+	_ = j.WriteMasters(masters)
+
+	err = Finish(j, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var records []map[string]interface{}
+	err = json.Unmarshal([]byte(b.String()), &records)
+	if err != nil {
+		t.Errorf("output should be one valid JSON array: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Errorf("3 records expected, got %d", len(records))
+	}
+}
@@ -0,0 +1,544 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBaseURI = "https://www.discogs.com/"
+	schemaContext  = "https://schema.org/"
+	schemaVocab    = "http://schema.org/"
+	rdfType        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+)
+
+// LinkedDataFormat is the serialization of the linked data output.
+type LinkedDataFormat int
+
+// LinkedDataFormat constants, JSON-LD is the default value.
+const (
+	JSONLD LinkedDataFormat = iota
+	NTriples
+	Turtle
+)
+
+// LinkedData options are used by the linked data writer only. Format is the serialization of the output and BaseURI
+// is the prefix of IRIs of entities (https://www.discogs.com/ by default), such as https://www.discogs.com/artist/2.
+type LinkedData struct {
+	Format  LinkedDataFormat
+	BaseURI string
+}
+
+// LinkedDataWriter is one of few provided writers that implements the Writer interface and provides the ability to
+// publish decoded data as linked data with the schema.org vocabulary. Artists are written as schema:MusicGroup when
+// they have members, otherwise as schema:Person, labels as schema:Organization, masters as schema:MusicAlbum and
+// releases as schema:MusicRelease with tracks as schema:MusicRecording. References between entities, such as
+// members, sub-labels, release artists and labels, are IRIs built from Discogs IDs and the base URI, so they are
+// stable across runs. Tracks are identified by their order within the release, such as release/2#track-1. Catalog
+// numbers are written only for releases of one label, otherwise it's not known which label they belong to.
+type LinkedDataWriter struct {
+	o       Options
+	w       io.Writer
+	b       bytes.Buffer
+	stream  bool
+	started bool
+	records int
+	err     error
+}
+
+// NewLinkedDataWriter creates a new Writer instance that writes linked data into the output in the format given by
+// LinkedData options.
+//
+// In the JSON-LD format each written slice results in one document with the @graph array, when the writer is opened
+// (see Opener interface), all records written until the writer is finished are part of one document. N-Triples and
+// Turtle outputs are streams of triples, the Turtle output starts with the schema prefix.
+func NewLinkedDataWriter(output io.Writer, options *Options) Writer {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	if o.LinkedData.BaseURI == "" {
+		o.LinkedData.BaseURI = defaultBaseURI
+	}
+
+	if !strings.HasSuffix(o.LinkedData.BaseURI, "/") && !strings.HasSuffix(o.LinkedData.BaseURI, "#") {
+		o.LinkedData.BaseURI += "/"
+	}
+
+	return &LinkedDataWriter{o: o, w: output}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (l *LinkedDataWriter) Options() Options {
+	return l.o
+}
+
+// WriteArtist function writes an artist as schema:MusicGroup or schema:Person.
+func (l *LinkedDataWriter) WriteArtist(artist model.Artist) error {
+	return l.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artists as schema:MusicGroup or schema:Person resources.
+func (l *LinkedDataWriter) WriteArtists(artists []model.Artist) error {
+	resources := make([]ldResource, 0, len(artists))
+	for _, a := range artists {
+		resources = append(resources, l.artist(l.o.excludeArtist(a)))
+	}
+
+	return l.write(resources)
+}
+
+// WriteLabel function writes a label as schema:Organization.
+func (l *LinkedDataWriter) WriteLabel(label model.Label) error {
+	return l.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of labels as schema:Organization resources.
+func (l *LinkedDataWriter) WriteLabels(labels []model.Label) error {
+	resources := make([]ldResource, 0, len(labels))
+	for _, lb := range labels {
+		resources = append(resources, l.label(l.o.excludeLabel(lb)))
+	}
+
+	return l.write(resources)
+}
+
+// WriteMaster function writes a master as schema:MusicAlbum.
+func (l *LinkedDataWriter) WriteMaster(master model.Master) error {
+	return l.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of masters as schema:MusicAlbum resources.
+func (l *LinkedDataWriter) WriteMasters(masters []model.Master) error {
+	resources := make([]ldResource, 0, len(masters))
+	for _, m := range masters {
+		resources = append(resources, l.master(l.o.excludeMaster(m)))
+	}
+
+	return l.write(resources)
+}
+
+// WriteRelease function writes a release as schema:MusicRelease.
+func (l *LinkedDataWriter) WriteRelease(release model.Release) error {
+	return l.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of releases as schema:MusicRelease resources.
+func (l *LinkedDataWriter) WriteReleases(releases []model.Release) error {
+	resources := make([]ldResource, 0, len(releases))
+	for _, r := range releases {
+		resources = append(resources, l.release(l.o.excludeRelease(r)))
+	}
+
+	return l.write(resources)
+}
+
+// Open starts the JSON-LD document, all records written until the writer is finished are part of its graph.
+// Nothing is written in N-Triples and Turtle formats.
+func (l *LinkedDataWriter) Open() error {
+	if l.o.LinkedData.Format != JSONLD {
+		return l.err
+	}
+
+	l.stream = true
+	l.records = 0
+	l.b.WriteString(`{"@context":"` + schemaContext + `","@graph":[`)
+	l.flush()
+
+	return l.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (l *LinkedDataWriter) Flush() error {
+	if l.err != nil {
+		return l.err
+	}
+
+	return flushOutput(l.w)
+}
+
+// Finish closes the JSON-LD document started by the Open function. The document is closed even when the run fails to
+// keep the output valid.
+func (l *LinkedDataWriter) Finish(error) error {
+	if !l.stream {
+		return nil
+	}
+
+	l.stream = false
+	l.b.WriteString("]}\n")
+	l.flush()
+
+	return l.err
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// ldResource is the resource identified by the IRI with the schema.org type and properties.
+type ldResource struct {
+	iri        string
+	kind       string
+	properties []ldProperty
+}
+
+// ldProperty is the schema.org property, the value is a string literal, an IRI or a nested resource.
+type ldProperty struct {
+	name  string
+	value interface{}
+}
+
+// ldIRI is the reference to another resource.
+type ldIRI string
+
+func (r *ldResource) literal(name, value string) {
+	if value != "" {
+		r.properties = append(r.properties, ldProperty{name: name, value: value})
+	}
+}
+
+func (r *ldResource) literals(name string, values []string) {
+	for _, v := range values {
+		r.literal(name, v)
+	}
+}
+
+func (r *ldResource) ref(name, iri string) {
+	if iri != "" {
+		r.properties = append(r.properties, ldProperty{name: name, value: ldIRI(iri)})
+	}
+}
+
+func (r *ldResource) images(images []model.Image) {
+	for _, i := range images {
+		r.ref("image", i.URI)
+	}
+}
+
+// iri returns the IRI of the entity, it's empty when the ID is empty or zero.
+func (l *LinkedDataWriter) iri(kind, id string) string {
+	if id == "" || id == "0" {
+		return ""
+	}
+
+	return l.o.LinkedData.BaseURI + kind + "/" + id
+}
+
+func (l *LinkedDataWriter) artist(a model.Artist) ldResource {
+	r := ldResource{iri: l.iri("artist", a.ID), kind: "Person"}
+	if len(a.Members) > 0 {
+		r.kind = "MusicGroup"
+	}
+
+	r.literal("name", a.Name)
+	if a.RealName != a.Name {
+		r.literal("alternateName", a.RealName)
+	}
+
+	r.literals("alternateName", a.NameVariations)
+	r.literal("description", a.Profile)
+	for _, m := range a.Members {
+		r.ref("member", l.iri("artist", m.ID))
+	}
+
+	for _, u := range a.Urls {
+		r.ref("sameAs", u)
+	}
+
+	r.images(a.Images)
+	return r
+}
+
+func (l *LinkedDataWriter) label(lb model.Label) ldResource {
+	r := ldResource{iri: l.iri("label", lb.ID), kind: "Organization"}
+	r.literal("name", lb.Name)
+	r.literal("description", lb.Profile)
+	if lb.ParentLabel != nil {
+		r.ref("parentOrganization", l.iri("label", lb.ParentLabel.ID))
+	}
+
+	for _, s := range lb.SubLabels {
+		r.ref("subOrganization", l.iri("label", s.ID))
+	}
+
+	for _, u := range lb.Urls {
+		r.ref("sameAs", u)
+	}
+
+	r.images(lb.Images)
+	return r
+}
+
+func (l *LinkedDataWriter) master(m model.Master) ldResource {
+	r := ldResource{iri: l.iri("master", m.ID), kind: "MusicAlbum"}
+	r.literal("name", m.Title)
+	if m.Year != "0" {
+		r.literal("datePublished", m.Year)
+	}
+
+	r.literals("genre", m.Genres)
+	r.literals("genre", m.Styles)
+	for _, a := range m.Artists {
+		r.ref("byArtist", l.iri("artist", a.ID))
+	}
+
+	r.ref("albumRelease", l.iri("release", m.MainRelease))
+	r.images(m.Images)
+	return r
+}
+
+func (l *LinkedDataWriter) release(rl model.Release) ldResource {
+	r := ldResource{iri: l.iri("release", rl.ID), kind: "MusicRelease"}
+	r.literal("name", rl.Title)
+	r.literal("datePublished", rl.Released)
+	r.literal("countryOfOrigin", rl.Country)
+	r.literals("genre", rl.Genres)
+	r.literals("genre", rl.Styles)
+	r.literal("description", rl.Notes)
+	r.ref("releaseOf", l.iri("master", rl.MasterID))
+	for _, a := range rl.Artists {
+		r.ref("creditedTo", l.iri("artist", a.ID))
+	}
+
+	for _, a := range rl.ExtraArtists {
+		r.ref("contributor", l.iri("artist", a.ID))
+	}
+
+	labels := make(map[string]bool)
+	for _, lb := range rl.Labels {
+		r.ref("recordLabel", l.iri("label", lb.ID))
+		labels[lb.ID] = true
+	}
+
+	// schema:catalogNumber belongs to the release, so it's bound to the label only when there is just one
+	if len(labels) == 1 {
+		for _, lb := range rl.Labels {
+			r.literal("catalogNumber", lb.Category)
+		}
+	}
+
+	for i, t := range rl.TrackList {
+		track := &ldResource{iri: fmt.Sprintf("%s#track-%d", r.iri, i+1), kind: "MusicRecording"}
+		track.literal("name", t.Title)
+		track.literal("position", t.Position)
+		track.literal("duration", isoDuration(t.Duration))
+		r.properties = append(r.properties, ldProperty{name: "track", value: track})
+	}
+
+	r.images(rl.Images)
+	return r
+}
+
+func (l *LinkedDataWriter) write(resources []ldResource) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	switch l.o.LinkedData.Format {
+	case NTriples:
+		for _, r := range resources {
+			writeNTriples(&l.b, r)
+		}
+	case Turtle:
+		if !l.started {
+			l.started = true
+			l.b.WriteString("@prefix schema: <" + schemaVocab + "> .\n\n")
+		}
+
+		for _, r := range resources {
+			writeTurtle(&l.b, r)
+		}
+	default:
+		l.writeJSONLD(resources)
+	}
+
+	l.flush()
+	return l.err
+}
+
+// writeJSONLD writes resources as items of the @graph array, the document is started and closed by the slice unless
+// the writer is opened.
+func (l *LinkedDataWriter) writeJSONLD(resources []ldResource) {
+	if !l.stream {
+		l.b.WriteString(`{"@context":"` + schemaContext + `","@graph":[`)
+	}
+
+	for i, r := range resources {
+		b, err := json.Marshal(jsonLD(r))
+		if err != nil {
+			l.err = err
+			return
+		}
+
+		if (l.stream && l.records > 0) || (!l.stream && i > 0) {
+			l.b.WriteByte(',')
+		}
+
+		l.b.Write(b)
+		l.records++
+	}
+
+	if !l.stream {
+		l.b.WriteString("]}\n")
+	}
+}
+
+func (l *LinkedDataWriter) flush() {
+	if l.err == nil {
+		_, l.err = l.w.Write(l.b.Bytes())
+	}
+
+	l.b.Reset()
+}
+
+// jsonLD returns the JSON-LD object of the resource, repeated properties are arrays.
+func jsonLD(r ldResource) map[string]interface{} {
+	obj := map[string]interface{}{"@id": r.iri, "@type": r.kind}
+	names, values := groupProperties(r.properties)
+	for _, n := range names {
+		items := make([]interface{}, len(values[n]))
+		for i, v := range values[n] {
+			switch val := v.(type) {
+			case ldIRI:
+				items[i] = map[string]string{"@id": string(val)}
+			case *ldResource:
+				items[i] = jsonLD(*val)
+			default:
+				items[i] = val
+			}
+		}
+
+		if len(items) == 1 {
+			obj[n] = items[0]
+		} else {
+			obj[n] = items
+		}
+	}
+
+	return obj
+}
+
+// writeNTriples writes triples of the resource and its nested resources.
+func writeNTriples(b *bytes.Buffer, r ldResource) {
+	subject := "<" + escapeIRI(r.iri) + "> "
+	b.WriteString(subject + "<" + rdfType + "> <" + schemaVocab + r.kind + "> .\n")
+
+	var nested []*ldResource
+	for _, p := range r.properties {
+		b.WriteString(subject + "<" + schemaVocab + p.name + "> " + rdfTerm(p.value) + " .\n")
+		if n, ok := p.value.(*ldResource); ok {
+			nested = append(nested, n)
+		}
+	}
+
+	for _, n := range nested {
+		writeNTriples(b, *n)
+	}
+}
+
+// writeTurtle writes the resource as one statement with predicate and object lists, nested resources follow.
+func writeTurtle(b *bytes.Buffer, r ldResource) {
+	b.WriteString("<" + escapeIRI(r.iri) + "> a schema:" + r.kind)
+
+	var nested []*ldResource
+	names, values := groupProperties(r.properties)
+	for _, n := range names {
+		b.WriteString(" ;\n    schema:" + n + " ")
+		for i, v := range values[n] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+
+			b.WriteString(rdfTerm(v))
+			if res, ok := v.(*ldResource); ok {
+				nested = append(nested, res)
+			}
+		}
+	}
+
+	b.WriteString(" .\n\n")
+	for _, n := range nested {
+		writeTurtle(b, *n)
+	}
+}
+
+// groupProperties groups values of repeated properties, names are in order of their first occurrence.
+func groupProperties(properties []ldProperty) ([]string, map[string][]interface{}) {
+	var names []string
+	values := make(map[string][]interface{})
+	for _, p := range properties {
+		if _, ok := values[p.name]; !ok {
+			names = append(names, p.name)
+		}
+
+		values[p.name] = append(values[p.name], p.value)
+	}
+
+	return names, values
+}
+
+// rdfTerm returns the N-Triples (and Turtle) term of the value.
+func rdfTerm(value interface{}) string {
+	switch v := value.(type) {
+	case ldIRI:
+		return "<" + escapeIRI(string(v)) + ">"
+	case *ldResource:
+		return "<" + escapeIRI(v.iri) + ">"
+	default:
+		return `"` + literalReplacer.Replace(fmt.Sprint(v)) + `"`
+	}
+}
+
+var literalReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escapeIRI percent-encodes characters which are not allowed in IRIs of N-Triples and Turtle, such as spaces.
+func escapeIRI(iri string) string {
+	var b strings.Builder
+	for i := 0; i < len(iri); i++ {
+		c := iri[i]
+		if c <= ' ' || strings.IndexByte(`<>"{}|^`+"`"+`\`, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// isoDuration converts the track duration, such as 4:05 or 1:02:03, into the ISO 8601 duration (PT4M5S, PT1H2M3S).
+// The empty string is returned when the duration is not valid.
+func isoDuration(duration string) string {
+	parts := strings.Split(duration, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return ""
+	}
+
+	units := []string{"S", "M", "H"}
+	var d string
+	for i := range parts {
+		n, err := strconv.Atoi(parts[len(parts)-1-i])
+		if err != nil || n < 0 {
+			return ""
+		}
+
+		if n > 0 {
+			d = strconv.Itoa(n) + units[i] + d
+		}
+	}
+
+	if d == "" {
+		return ""
+	}
+
+	return "PT" + d
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"strings"
+	"testing"
+)
+
+var linkedRelease = model.Release{
+	ID:        "7",
+	Title:     "Say \"Hi\"\nAgain",
+	MasterID:  "0",
+	Artists:   []model.ReleaseArtist{{ID: "2"}},
+	Labels:    []model.ReleaseLabel{{ID: "5", Category: "SK 026"}},
+	TrackList: []model.Track{{Position: "A", Title: "Hi", Duration: "4:05"}},
+}
+
+func TestLinkedDataWriter_Options(t *testing.T) {
+	opt := NewLinkedDataWriter(nil, &Options{LinkedData: LinkedData{BaseURI: "https://example.org/discogs"}}).Options()
+	if opt.LinkedData.BaseURI != "https://example.org/discogs/" {
+		t.Errorf("base URI should end with slash, got %s", opt.LinkedData.BaseURI)
+	}
+
+	opt = NewLinkedDataWriter(nil, nil).Options()
+	if opt.LinkedData.BaseURI != defaultBaseURI || opt.LinkedData.Format != JSONLD {
+		t.Error("JSON-LD with the Discogs base URI should be the default")
+	}
+}
+
+func TestLinkedDataWriter_NTriples(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewLinkedDataWriter(b, &Options{LinkedData: LinkedData{Format: NTriples, BaseURI: "http://ex.org/"}})
+
+	err := w.WriteRelease(linkedRelease)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "<http://ex.org/release/7> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> " +
+		"<http://schema.org/MusicRelease> .\n" +
+		"<http://ex.org/release/7> <http://schema.org/name> \"Say \\\"Hi\\\"\\nAgain\" .\n" +
+		"<http://ex.org/release/7> <http://schema.org/creditedTo> <http://ex.org/artist/2> .\n" +
+		"<http://ex.org/release/7> <http://schema.org/recordLabel> <http://ex.org/label/5> .\n" +
+		"<http://ex.org/release/7> <http://schema.org/catalogNumber> \"SK 026\" .\n" +
+		"<http://ex.org/release/7> <http://schema.org/track> <http://ex.org/release/7#track-1> .\n" +
+		"<http://ex.org/release/7#track-1> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> " +
+		"<http://schema.org/MusicRecording> .\n" +
+		"<http://ex.org/release/7#track-1> <http://schema.org/name> \"Hi\" .\n" +
+		"<http://ex.org/release/7#track-1> <http://schema.org/position> \"A\" .\n" +
+		"<http://ex.org/release/7#track-1> <http://schema.org/duration> \"PT4M5S\" .\n"
+	if b.String() != expected {
+		t.Errorf("N-Triples differ from what it's expected\n%s\n%s", b, expected)
+	}
+}
+
+func TestLinkedDataWriter_CatalogNumbers(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewLinkedDataWriter(b, &Options{LinkedData: LinkedData{Format: NTriples, BaseURI: "http://ex.org/"}})
+
+	rl := linkedRelease
+	rl.Labels = []model.ReleaseLabel{{ID: "5", Category: "SK 026"}, {ID: "5", Category: "SK026"}}
+	err := w.WriteRelease(rl)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !strings.Contains(b.String(), "<http://schema.org/catalogNumber> \"SK026\" .\n") {
+		t.Errorf("catalog numbers of one label should be written: %s", b)
+	}
+
+	b.Reset()
+	rl.Labels = []model.ReleaseLabel{{ID: "5", Category: "SK 026"}, {ID: "6", Category: "NE 10"}}
+	err = w.WriteRelease(rl)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if strings.Contains(b.String(), "catalogNumber") || !strings.Contains(b.String(), "<http://ex.org/label/6> .\n") {
+		t.Errorf("catalog numbers of more labels should be dropped, labels should be kept: %s", b)
+	}
+}
+
+func TestLinkedDataWriter_Turtle(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewLinkedDataWriter(b, &Options{LinkedData: LinkedData{Format: Turtle}})
+
+	err := w.WriteArtists(artists)
+	if err == nil {
+		err = w.WriteLabels(labels)
+	}
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	out := b.String()
+	if strings.Count(out, "@prefix schema: <http://schema.org/> .") != 1 {
+		t.Error("prefix should be written once")
+	}
+
+	if !strings.Contains(out, "<https://www.discogs.com/artist/2> a schema:MusicGroup ;\n") ||
+		!strings.Contains(out, "    schema:member <https://www.discogs.com/artist/26>, "+
+			"<https://www.discogs.com/artist/27>") {
+		t.Errorf("artist with members should be a music group:\n%s", out)
+	}
+
+	if !strings.Contains(out, "<https://www.discogs.com/label/1> a schema:Organization ;\n") ||
+		!strings.Contains(out, "schema:subOrganization <https://www.discogs.com/label/86537>, ") {
+		t.Errorf("label should be an organization with sub-organizations:\n%s", out)
+	}
+}
+
+func TestLinkedDataWriter_JSONLD(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewLinkedDataWriter(b, nil)
+
+	for _, err := range []error{
+		Open(w),
+		w.WriteMasters(masters),
+		w.WriteReleases(append(releases, linkedRelease)),
+		Finish(w, nil),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var doc struct {
+		Context string                   `json:"@context"`
+		Graph   []map[string]interface{} `json:"@graph"`
+	}
+
+	err := json.Unmarshal(b.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Context != schemaContext || len(doc.Graph) != len(masters)+len(releases)+1 {
+		t.Fatalf("one document with all records should be written: %s", b)
+	}
+
+	if doc.Graph[0]["@id"] != "https://www.discogs.com/master/18512" || doc.Graph[0]["@type"] != "MusicAlbum" {
+		t.Errorf("master should be a music album: %v", doc.Graph[0])
+	}
+
+	track := doc.Graph[len(doc.Graph)-1]["track"].(map[string]interface{})
+	if track["@type"] != "MusicRecording" || track["duration"] != "PT4M5S" {
+		t.Errorf("track should be nested music recording: %v", track)
+	}
+}
+
+func TestLinkedDataWriter_JSONLDSlices(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewLinkedDataWriter(b, nil)
+
+	_ = w.WriteArtists(artists)
+	_ = w.WriteRelease(linkedRelease)
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("each slice should be one document, got %d", len(lines))
+	}
+
+	for _, l := range lines {
+		if !json.Valid([]byte(l)) {
+			t.Errorf("document should be valid JSON: %s", l)
+		}
+	}
+}
+
+func TestIsoDuration(t *testing.T) {
+	for duration, expected := range map[string]string{
+		"4:05":    "PT4M5S",
+		"1:02:03": "PT1H2M3S",
+		"10:00":   "PT10M",
+		"0:00":    "",
+		"":        "",
+		"4'05":    "",
+		"a:05":    "",
+	} {
+		if got := isoDuration(duration); got != expected {
+			t.Errorf("duration %q should be %q, got %q", duration, expected, got)
+		}
+	}
+}
+
+func TestEscapeIRI(t *testing.T) {
+	if got := escapeIRI("http://ex.org/a b<c>"); got != "http://ex.org/a%20b%3Cc%3E" {
+		t.Errorf("unexpected IRI %s", got)
+	}
+}
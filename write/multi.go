@@ -0,0 +1,379 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
+	"reflect"
+	"strings"
+)
+
+// MultiError combines errors returned by more writers.
+type MultiError []error
+
+// Error joins messages of all combined errors.
+func (m MultiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of combined errors matches the target, so errors.Is function checks each of them.
+func (m MultiError) Is(target error) bool {
+	for _, err := range m {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of combined errors that matches the target and sets the target to that error value, so
+// errors.As function checks each of them.
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// combine returns nil when there is no error, the error itself when there is only one and MultiError otherwise.
+func combine(errs []error) error {
+	var me MultiError
+	for _, err := range errs {
+		if err != nil {
+			me = append(me, err)
+		}
+	}
+
+	switch len(me) {
+	case 0:
+		return nil
+	case 1:
+		return me[0]
+	default:
+		return me
+	}
+}
+
+// ----------------------------------------------- MULTI WRITER -----------------------------------------------
+
+type multiWriter struct {
+	ws []Writer
+}
+
+// MultiWriter creates a writer that duplicates all writes to all provided writers, similar to the io.MultiWriter.
+// Each write is sent to all writers even when some of them fail, errors are combined into MultiError (a single
+// error is returned as it is). Each writer applies its own Options, so the Options function of the multi writer
+// returns empty options. The writer lifecycle (Skipper, Opener, Flusher, Finisher and io.Closer) is forwarded to all
+// writers as well.
+func MultiWriter(ws ...Writer) Writer {
+	return &multiWriter{ws: ws}
+}
+
+// Options returns empty options, each of the underlying writers uses its own options.
+func (m *multiWriter) Options() Options {
+	return Options{}
+}
+
+// WriteArtist writes an artist to all writers.
+func (m *multiWriter) WriteArtist(artist model.Artist) error {
+	return m.each(func(w Writer) error { return w.WriteArtist(artist) })
+}
+
+// WriteArtists writes a slice of artists to all writers.
+func (m *multiWriter) WriteArtists(artists []model.Artist) error {
+	return m.each(func(w Writer) error { return w.WriteArtists(artists) })
+}
+
+// WriteLabel writes a label to all writers.
+func (m *multiWriter) WriteLabel(label model.Label) error {
+	return m.each(func(w Writer) error { return w.WriteLabel(label) })
+}
+
+// WriteLabels writes a slice of labels to all writers.
+func (m *multiWriter) WriteLabels(labels []model.Label) error {
+	return m.each(func(w Writer) error { return w.WriteLabels(labels) })
+}
+
+// WriteMaster writes a master to all writers.
+func (m *multiWriter) WriteMaster(master model.Master) error {
+	return m.each(func(w Writer) error { return w.WriteMaster(master) })
+}
+
+// WriteMasters writes a slice of masters to all writers.
+func (m *multiWriter) WriteMasters(masters []model.Master) error {
+	return m.each(func(w Writer) error { return w.WriteMasters(masters) })
+}
+
+// WriteRelease writes a release to all writers.
+func (m *multiWriter) WriteRelease(release model.Release) error {
+	return m.each(func(w Writer) error { return w.WriteRelease(release) })
+}
+
+// WriteReleases writes a slice of releases to all writers.
+func (m *multiWriter) WriteReleases(releases []model.Release) error {
+	return m.each(func(w Writer) error { return w.WriteReleases(releases) })
+}
+
+// Skip passes the number of skipped blocks to all writers.
+func (m *multiWriter) Skip(blocks int) {
+	for _, w := range m.ws {
+		Skip(w, blocks)
+	}
+}
+
+// Open opens all writers.
+func (m *multiWriter) Open() error {
+	return m.each(Open)
+}
+
+// Flush flushes all writers.
+func (m *multiWriter) Flush() error {
+	return m.each(Flush)
+}
+
+// Finish finishes all writers.
+func (m *multiWriter) Finish(err error) error {
+	return m.each(func(w Writer) error { return Finish(w, err) })
+}
+
+// Close closes all writers.
+func (m *multiWriter) Close() error {
+	return m.each(Close)
+}
+
+func (m *multiWriter) each(fn func(Writer) error) error {
+	errs := make([]error, 0, len(m.ws))
+	for _, w := range m.ws {
+		errs = append(errs, fn(w))
+	}
+
+	return combine(errs)
+}
+
+// ----------------------------------------------- ROUTE WRITER -----------------------------------------------
+
+// Route pairs a predicate with the writer. Match function is called with model.Artist, model.Label, model.Master
+// or model.Release values, the route without the Match function matches all records.
+type Route struct {
+	Match  func(record interface{}) bool
+	Writer Writer
+}
+
+// HasGenre creates a predicate matching masters and releases with the genre, such as Electronic.
+func HasGenre(genre string) func(record interface{}) bool {
+	return func(record interface{}) bool {
+		var genres []string
+		switch r := record.(type) {
+		case model.Master:
+			genres = r.Genres
+		case model.Release:
+			genres = r.Genres
+		}
+
+		for _, g := range genres {
+			if g == genre {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+type routeWriter struct {
+	routes  []Route
+	targets []int
+	writers []Writer
+}
+
+// RouteWriter creates a writer that sends each record to the writer of the first matching route. Records without
+// any matching route are sent to the fallback writer, when the fallback is nil such records are dropped.
+// Blocks are split per writer keeping the order of records and each writer gets at most one call per block, even
+// when it's used by more routes or as the fallback as well. Errors of all writers are combined the same way as
+// the MultiWriter does, and the writer lifecycle is forwarded to each of route writers and the fallback once.
+func RouteWriter(fallback Writer, routes ...Route) Writer {
+	rs := append([]Route(nil), routes...)
+	rs = append(rs, Route{Writer: fallback})
+
+	r := &routeWriter{routes: rs, targets: make([]int, len(rs))}
+	for i, rt := range rs {
+		r.targets[i] = r.target(rt.Writer)
+	}
+
+	return r
+}
+
+// Options returns empty options, each of the route writers uses its own options.
+func (r *routeWriter) Options() Options {
+	return Options{}
+}
+
+// WriteArtist writes an artist to the writer of the first matching route.
+func (r *routeWriter) WriteArtist(artist model.Artist) error {
+	return r.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists splits the slice of artists by routes and writes them to route writers.
+func (r *routeWriter) WriteArtists(artists []model.Artist) error {
+	parts := make([][]model.Artist, len(r.writers))
+	for _, a := range artists {
+		if i := r.route(a); i >= 0 {
+			parts[i] = append(parts[i], a)
+		}
+	}
+
+	return r.each(func(i int, w Writer) error {
+		if len(parts[i]) == 0 {
+			return nil
+		}
+
+		return w.WriteArtists(parts[i])
+	})
+}
+
+// WriteLabel writes a label to the writer of the first matching route.
+func (r *routeWriter) WriteLabel(label model.Label) error {
+	return r.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels splits the slice of labels by routes and writes them to route writers.
+func (r *routeWriter) WriteLabels(labels []model.Label) error {
+	parts := make([][]model.Label, len(r.writers))
+	for _, l := range labels {
+		if i := r.route(l); i >= 0 {
+			parts[i] = append(parts[i], l)
+		}
+	}
+
+	return r.each(func(i int, w Writer) error {
+		if len(parts[i]) == 0 {
+			return nil
+		}
+
+		return w.WriteLabels(parts[i])
+	})
+}
+
+// WriteMaster writes a master to the writer of the first matching route.
+func (r *routeWriter) WriteMaster(master model.Master) error {
+	return r.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters splits the slice of masters by routes and writes them to route writers.
+func (r *routeWriter) WriteMasters(masters []model.Master) error {
+	parts := make([][]model.Master, len(r.writers))
+	for _, m := range masters {
+		if i := r.route(m); i >= 0 {
+			parts[i] = append(parts[i], m)
+		}
+	}
+
+	return r.each(func(i int, w Writer) error {
+		if len(parts[i]) == 0 {
+			return nil
+		}
+
+		return w.WriteMasters(parts[i])
+	})
+}
+
+// WriteRelease writes a release to the writer of the first matching route.
+func (r *routeWriter) WriteRelease(release model.Release) error {
+	return r.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases splits the slice of releases by routes and writes them to route writers.
+func (r *routeWriter) WriteReleases(releases []model.Release) error {
+	parts := make([][]model.Release, len(r.writers))
+	for _, rls := range releases {
+		if i := r.route(rls); i >= 0 {
+			parts[i] = append(parts[i], rls)
+		}
+	}
+
+	return r.each(func(i int, w Writer) error {
+		if len(parts[i]) == 0 {
+			return nil
+		}
+
+		return w.WriteReleases(parts[i])
+	})
+}
+
+// Skip passes the number of skipped blocks to all route writers.
+func (r *routeWriter) Skip(blocks int) {
+	for _, w := range r.writers {
+		Skip(w, blocks)
+	}
+}
+
+// Open opens all route writers.
+func (r *routeWriter) Open() error {
+	return r.each(func(_ int, w Writer) error { return Open(w) })
+}
+
+// Flush flushes all route writers.
+func (r *routeWriter) Flush() error {
+	return r.each(func(_ int, w Writer) error { return Flush(w) })
+}
+
+// Finish finishes all route writers.
+func (r *routeWriter) Finish(err error) error {
+	return r.each(func(_ int, w Writer) error { return Finish(w, err) })
+}
+
+// Close closes all route writers.
+func (r *routeWriter) Close() error {
+	return r.each(func(_ int, w Writer) error { return Close(w) })
+}
+
+// route returns index of the writer of the first matching route, it's -1 when the record is dropped.
+func (r *routeWriter) route(record interface{}) int {
+	for i, rt := range r.routes {
+		if rt.Match == nil || rt.Match(record) {
+			return r.targets[i]
+		}
+	}
+
+	return -1
+}
+
+// target returns index of the writer in the list of distinct writers, the writer is added when it's not there yet.
+// Writers are compared by identity, writers of types that are not comparable are always considered distinct.
+func (r *routeWriter) target(w Writer) int {
+	if w == nil {
+		return -1
+	}
+
+	if reflect.TypeOf(w).Comparable() {
+		for i, rw := range r.writers {
+			if reflect.TypeOf(rw).Comparable() && rw == w {
+				return i
+			}
+		}
+	}
+
+	r.writers = append(r.writers, w)
+	return len(r.writers) - 1
+}
+
+func (r *routeWriter) each(fn func(int, Writer) error) error {
+	errs := make([]error, 0, len(r.writers))
+	for i, w := range r.writers {
+		errs = append(errs, fn(i, w))
+	}
+
+	return combine(errs)
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strings"
+	"testing"
+)
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct {
+	err error
+}
+
+func (f failingWriter) Write([]byte) (int, error) {
+	return 0, f.err
+}
+
+func TestMultiWriter_WriteArtists(t *testing.T) {
+	jb := &strings.Builder{}
+	sb := &strings.Builder{}
+	m := MultiWriter(NewJSONWriter(jb, nil), NewSQLWriter(sb, nil))
+
+	err := m.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ma, _ := json.Marshal(artists)
+	if jb.String() != string(ma) {
+		t.Error("json output differs from json marshal expected solution")
+	}
+
+	if sb.String() != expectedArtist {
+		t.Error("sql output differs from what it's expected")
+	}
+}
+
+func TestMultiWriter_Options(t *testing.T) {
+	sb := &strings.Builder{}
+	m := MultiWriter(NewSQLWriter(sb, &Options{ExcludeImages: true}), NewSQLWriter(sb, nil))
+
+	if m.Options().ExcludeImages {
+		t.Error("multi writer should have empty options")
+	}
+
+	_ = m.WriteLabel(labels[0])
+	if got := strings.Count(sb.String(), "INSERT INTO images"); got != 7 {
+		t.Errorf("images should be written by the second writer only, got %d images", got)
+	}
+}
+
+func TestMultiWriter_Errors(t *testing.T) {
+	err1 := errors.New("first failed")
+	err2 := errors.New("second failed")
+	b := &strings.Builder{}
+	m := MultiWriter(NewJSONWriter(failingWriter{err: err1}, nil), NewJSONWriter(b, nil))
+
+	err := m.WriteMaster(masters[0])
+	if err != err1 {
+		t.Errorf("single error should be returned as it is, got %v", err)
+	}
+
+	if b.Len() == 0 {
+		t.Error("the second writer should be written even when the first one fails")
+	}
+
+	m = MultiWriter(NewJSONWriter(failingWriter{err: err1}, nil), NewJSONWriter(failingWriter{err: err2}, nil))
+	err = m.WriteMaster(masters[0])
+	me, ok := err.(MultiError)
+	if !ok || len(me) != 2 || me[0] != err1 || me[1] != err2 {
+		t.Errorf("errors should be combined, got %v", err)
+	}
+
+	if err.Error() != "first failed; second failed" {
+		t.Errorf("unexpected error message %s", err.Error())
+	}
+}
+
+func TestRouteWriter_WriteReleases(t *testing.T) {
+	electronic := &strings.Builder{}
+	rest := &strings.Builder{}
+
+	rock := releases[0]
+	rock.ID = "3"
+	rock.Genres = []string{"Rock"}
+
+	r := RouteWriter(
+		NewJSONWriter(rest, &Options{JSON: JSON{Lines: true}}),
+		Route{Match: HasGenre("Electronic"), Writer: NewJSONWriter(electronic, &Options{JSON: JSON{Lines: true}})},
+	)
+
+	err := r.WriteReleases([]model.Release{releases[0], rock, releases[0]})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := strings.Count(electronic.String(), "\n"); got != 2 {
+		t.Errorf("2 electronic releases expected, got %d", got)
+	}
+
+	if got := strings.Count(rest.String(), "\n"); got != 1 || !strings.HasPrefix(rest.String(), `{"id":"3"`) {
+		t.Errorf("1 rock release expected in fallback writer, got %s", rest.String())
+	}
+}
+
+func TestRouteWriter_NoFallback(t *testing.T) {
+	b := &strings.Builder{}
+	r := RouteWriter(nil, Route{
+		Match: func(record interface{}) bool {
+			l, ok := record.(model.Label)
+			return ok && l.ID == "2"
+		},
+		Writer: NewJSONWriter(b, nil),
+	})
+
+	err := r.WriteLabel(labels[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	if b.Len() != 0 {
+		t.Error("not matching label should be dropped")
+	}
+
+	err = Open(r)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_ = r.WriteArtists(artists)
+	err = Finish(r, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if b.String() != "[]" {
+		t.Errorf("route writer should forward the lifecycle, got %s", b.String())
+	}
+}
+
+type testError struct {
+	msg string
+}
+
+func (e *testError) Error() string {
+	return e.msg
+}
+
+func TestMultiError_IsAs(t *testing.T) {
+	err1 := errors.New("first failed")
+	err2 := &testError{msg: "second failed"}
+
+	err := combine([]error{err1, nil, err2})
+	if !errors.Is(err, err1) || errors.Is(err, io.EOF) {
+		t.Error("only the first error should be found by errors.Is")
+	}
+
+	var te *testError
+	if !errors.As(err, &te) || te != err2 {
+		t.Error("second error should be found by errors.As")
+	}
+}
+
+func TestRouteWriter_SharedWriter(t *testing.T) {
+	b := &strings.Builder{}
+	w := NewJSONWriter(b, nil)
+	r := RouteWriter(w,
+		Route{Match: HasGenre("Electronic"), Writer: w},
+		Route{Match: HasGenre("Rock"), Writer: w},
+	)
+
+	rock := releases[0]
+	rock.ID = "3"
+	rock.Genres = []string{"Rock"}
+
+	for _, err := range []error{
+		Open(r),
+		r.WriteReleases([]model.Release{releases[0], rock}),
+		r.WriteMasters(masters),
+		Flush(r),
+		Finish(r, nil),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var records []map[string]interface{}
+	err := json.Unmarshal([]byte(b.String()), &records)
+	if err != nil || len(records) != 3 {
+		t.Errorf("shared writer should produce one JSON array with 3 records, got %s", b)
+	}
+}
+
+func TestRouteWriter_NilMatch(t *testing.T) {
+	matched := &strings.Builder{}
+	fallback := &strings.Builder{}
+	r := RouteWriter(NewJSONWriter(fallback, nil), Route{Writer: NewJSONWriter(matched, nil)})
+
+	err := r.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if matched.Len() == 0 || fallback.Len() != 0 {
+		t.Error("route without the Match function should match all records")
+	}
+}
+
+func TestMultiWriter_Close(t *testing.T) {
+	outputs, open := newParquetBuffers()
+	m := MultiWriter(Transform(NewParquetWriterFunc(open, nil)), NewJSONWriter(&strings.Builder{}, nil))
+
+	err := m.WriteArtists(artists)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Close(m)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if got := outputs["artists"].String(); !strings.HasPrefix(got, "PAR1") || !strings.HasSuffix(got[4:], "PAR1") {
+		t.Error("parquet file should be completed by closing the multi writer")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names options are used by SQL based writers and define names of tables and columns, so several dump versions or
+// other tables with the same names can live in one database side by side.
+//
+// Schema qualifies all tables, such as discogs_2020_01.releases. Prefix is prepended to all table names. Table and
+// Column functions map the original names (see the tables.sql script in the sql_scripts folder) to the names used in
+// the database, names are kept when they are not set. The schema and tables have to exist, the TableCommands function
+// returns commands creating them and the IndexCommands function returns commands creating their indexes.
+type Names struct {
+	Schema string
+	Prefix string
+	Table  func(name string) string
+	Column func(name string) string
+}
+
+// TableCommands returns commands creating the schema (for PostgreSQL and MySQL, when it's set) and all tables with
+// names according to Names options, document tables in the document mode (see Documents options). The result could
+// be used as PreLoad commands or executed before the first load.
+func TableCommands(options *Options) []string {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	var cmds []string
+	if o.Names.Schema != "" && o.Dialect != SQLite {
+		cmds = append(cmds, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", o.Names.Schema))
+	}
+
+	for _, t := range o.tables() {
+		cmds = append(cmds, o.createCommand(t, false))
+	}
+
+	return cmds
+}
+
+// IndexCommands returns commands creating indexes of all tables with names according to Names options, the same as
+// the indexes.sql script in the sql_scripts folder does. Unique indexes of main entity tables (see unique_indexes.sql
+// script) are included in the Upsert mode. Generated columns of document tables are indexed in the document mode.
+// The result could be used as PostLoad commands.
+func IndexCommands(options *Options) []string {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	var cmds []string
+	for _, t := range o.tables() {
+		if o.Mode == Upsert && t.key != "" {
+			cmds = append(cmds, o.indexCommand(t, t.key, false, true))
+		}
+
+		for _, c := range t.indexes {
+			cmds = append(cmds, o.indexCommand(t, c, false, false))
+		}
+	}
+
+	return cmds
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// localName returns the mapped name of the table without the schema. The staging suffix is added for staging tables.
+func (o Options) localName(t table, staging bool) string {
+	name := t.name
+	if o.Names.Table != nil {
+		name = o.Names.Table(name)
+	}
+
+	name = o.Names.Prefix + name
+	if staging {
+		name += o.Staging.Suffix
+	}
+
+	return name
+}
+
+// qualify prepends the schema to the name of the table or the index, when it's set.
+func (o Options) qualify(name string) string {
+	if o.Names.Schema == "" {
+		return name
+	}
+
+	return o.Names.Schema + "." + name
+}
+
+// tableName returns the full name of the table the rows are written into, which is the staging table when it's
+// enabled.
+func (o Options) tableName(t table) string {
+	return o.qualify(o.localName(t, true))
+}
+
+// liveName returns the full name of the live table.
+func (o Options) liveName(t table) string {
+	return o.qualify(o.localName(t, false))
+}
+
+// column returns the mapped name of the column.
+func (o Options) column(name string) string {
+	if o.Names.Column == nil {
+		return name
+	}
+
+	return o.Names.Column(name)
+}
+
+// columns returns mapped names of the table columns.
+func (o Options) columns(t table) []string {
+	cols := make([]string, 0, len(t.columns))
+	for _, c := range t.columns {
+		cols = append(cols, o.column(c))
+	}
+
+	return cols
+}
+
+// createCommand returns the command creating the table, or the staging table, with generated columns of documents.
+func (o Options) createCommand(t table, staging bool) string {
+	cols := make([]string, 0, len(t.columns))
+	for i, c := range t.columns {
+		cols = append(cols, fmt.Sprintf("%s %s", o.column(c), o.columnType(t.types[i])))
+	}
+
+	if o.Documents.Enabled {
+		for _, k := range o.generated(t) {
+			cols = append(cols, o.generatedColumn(k))
+		}
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (%s)", o.qualify(o.localName(t, staging)), strings.Join(cols, ", "))
+}
+
+// indexName returns the name of the index on the table column, without the schema. MySQL index names are unique per
+// table, so staging indexes get their final names, other dialects use names derived from the staging table.
+func (o Options) indexName(t table, column string, staging, unique bool) string {
+	name := fmt.Sprintf("%s_%s", o.localName(t, staging && o.Dialect != MySQL), o.column(column))
+	if unique {
+		name += "_unique"
+	}
+
+	return name
+}
+
+// indexCommand returns the command creating the index on the table column. SQLite qualifies the index name by the
+// schema, other dialects the table name.
+func (o Options) indexCommand(t table, column string, staging, unique bool) string {
+	kind := "INDEX"
+	if unique {
+		kind = "UNIQUE INDEX"
+	}
+
+	name, on := o.indexName(t, column, staging, unique), o.qualify(o.localName(t, staging))
+	if o.Dialect == SQLite && o.Names.Schema != "" {
+		name, on = o.qualify(name), o.localName(t, staging)
+	}
+
+	return fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, name, on, o.column(column))
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTables_Types(t *testing.T) {
+	for _, tbl := range allTables {
+		if len(tbl.types) != len(tbl.columns) {
+			t.Errorf("table %s has %d columns and %d types", tbl.name, len(tbl.columns), len(tbl.types))
+		}
+	}
+}
+
+func TestSQLWriter_Names(t *testing.T) {
+	b := &bytes.Buffer{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert, Names: Names{
+		Schema: "discogs_2020_01",
+		Prefix: "dg_",
+		Column: strings.ToUpper,
+	}})
+
+	err := s.WriteMaster(masters[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	out := b.String()
+	expected := []string{
+		"DELETE FROM discogs_2020_01.dg_images WHERE MASTER_ID = '18512';",
+		"INSERT INTO discogs_2020_01.dg_masters (MASTER_ID, MAIN_RELEASE, GENRES, STYLES, YEAR, TITLE, DATA_QUALITY)",
+		" ON CONFLICT (MASTER_ID) DO UPDATE SET MAIN_RELEASE = EXCLUDED.MAIN_RELEASE, ",
+		"INSERT INTO discogs_2020_01.dg_videos (MASTER_ID, RELEASE_ID, ",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Errorf("output should contain %q", e)
+		}
+	}
+}
+
+func TestDBWriter_Names_Staging(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{
+		Staging: Staging{Suffix: "_staging"},
+		Names: Names{
+			Schema: "discogs",
+			Table:  func(name string) string { return strings.TrimSuffix(name, "s") },
+		},
+	})
+
+	_ = Open(w)
+	_ = w.WriteArtists(artists)
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	statements := strings.Join(f.recorded(), "\n")
+	expected := []string{
+		"CREATE TABLE discogs.artist_staging AS SELECT * FROM discogs.artist WHERE 1 = 0\n",
+		"INSERT INTO discogs.artist_staging (artist_id",
+		"CREATE INDEX artist_staging_name ON discogs.artist_staging (name)\n",
+		"DROP TABLE discogs.artist\nALTER TABLE discogs.artist_staging RENAME TO artist\n",
+		"ALTER INDEX discogs.artist_staging_artist_id RENAME TO artist_artist_id\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(statements, e) {
+			t.Errorf("statements should contain %q", e)
+		}
+	}
+}
+
+func TestTableCommands(t *testing.T) {
+	cmds := TableCommands(&Options{Names: Names{Schema: "discogs_2020_01"}})
+	if len(cmds) != len(allTables)+1 {
+		t.Fatalf("unexpected number of commands %d", len(cmds))
+	}
+
+	if cmds[0] != "CREATE SCHEMA IF NOT EXISTS discogs_2020_01" {
+		t.Errorf("schema should be created, got %s", cmds[0])
+	}
+
+	if cmds[1] != createArtists {
+		t.Errorf("unexpected command %s", cmds[1])
+	}
+
+	cmds = TableCommands(&Options{Dialect: SQLite, Names: Names{Schema: "discogs"}})
+	if len(cmds) != len(allTables) {
+		t.Error("SQLite schema should not be created")
+	}
+}
+
+func TestIndexCommands(t *testing.T) {
+	cmds := IndexCommands(&Options{Mode: Upsert, Names: Names{Prefix: "v2_"}})
+	if cmds[0] != "CREATE UNIQUE INDEX v2_artists_artist_id_unique ON v2_artists (artist_id)" {
+		t.Errorf("unexpected command %s", cmds[0])
+	}
+
+	if cmds[1] != "CREATE INDEX v2_artists_artist_id ON v2_artists (artist_id)" {
+		t.Errorf("unexpected command %s", cmds[1])
+	}
+
+	cmds = IndexCommands(&Options{Dialect: SQLite, Names: Names{Schema: "discogs"}})
+	if cmds[0] != "CREATE INDEX discogs.artists_artist_id ON artists (artist_id)" {
+		t.Errorf("SQLite index should be qualified by the schema, got %s", cmds[0])
+	}
+}
+
+// ---------------------------------------------------- DATA ----------------------------------------------------
+
+const createArtists = "CREATE TABLE discogs_2020_01.artists (artist_id VARCHAR(10), name VARCHAR(1024), " +
+	"real_name VARCHAR(1024), profile TEXT, data_quality VARCHAR(20), name_variations VARCHAR(1024)[], " +
+	"urls VARCHAR(1024)[])"
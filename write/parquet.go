@@ -0,0 +1,530 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	parquetMagic          = "PAR1"
+	parquetCreatedBy      = "data-discogs"
+	defaultRowGroupSize   = 100000
+	parquetByteArray      = int32(6)
+	parquetRequired       = int32(0)
+	parquetOptional       = int32(1)
+	parquetRepeated       = int32(2)
+	parquetUTF8           = int32(0)
+	parquetList           = int32(3)
+	parquetPlain          = int32(0)
+	parquetRLE            = int32(3)
+	parquetDataPage       = int32(0)
+	parquetMaxDefinitions = 2
+)
+
+// ParquetCompression specifies the codec used for pages of Parquet files.
+type ParquetCompression int
+
+// ParquetCompression constants are the codecs supported by the Parquet writer, values match the codec IDs of the
+// Parquet format. Uncompressed pages are the default value.
+const (
+	ParquetUncompressed ParquetCompression = iota
+	ParquetSnappy
+	ParquetGzip
+)
+
+// Parquet options are used by Parquet writer only. RowGroupSize is the maximum number of rows of one row group,
+// 100 000 rows are used when it's not set. Compression defines the codec of pages.
+type Parquet struct {
+	RowGroupSize int
+	Compression  ParquetCompression
+}
+
+// ParquetWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// decoded data as Apache Parquet files for analytics. Data are split into the same tables as the SQL writer creates
+// (artists, artist_aliases, images, releases, release_tracks, ...), each table is written into its own Parquet file.
+//
+// All columns are strings (BYTE_ARRAY with UTF8 annotation), array columns (genres, styles, urls, etc.) are lists of
+// strings. Rows are buffered in memory until the row group is complete, each column chunk of the row group consists
+// of one data page and has statistics (minimum and maximum value and the number of nulls). Files stay open across runs
+// and the file footer is written by the Close function, files are not valid before that.
+type ParquetWriter struct {
+	o     Options
+	open  func(table string) (io.Writer, error)
+	files map[string]*parquetFile
+	order []*parquetFile
+	err   error
+}
+
+// NewParquetWriter creates a new Writer instance that writes each table into its own file in the directory, such as
+// releases.parquet or release_tracks.parquet. Files are created when the first row of the table is written, and they
+// have to be closed by the Close function.
+func NewParquetWriter(dir string, options *Options) Writer {
+	return NewParquetWriterFunc(func(table string) (io.Writer, error) {
+		return os.Create(filepath.Join(dir, table+".parquet"))
+	}, options)
+}
+
+// NewParquetWriterFunc creates a new Writer instance that writes each table into the output opened by the function.
+// The function is called once per table, when the first row of the table is written. Outputs implementing
+// the io.Closer interface are closed by the Close function.
+func NewParquetWriterFunc(open func(table string) (io.Writer, error), options *Options) Writer {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	if o.Parquet.RowGroupSize <= 0 {
+		o.Parquet.RowGroupSize = defaultRowGroupSize
+	}
+
+	return &ParquetWriter{
+		o:     o,
+		open:  open,
+		files: make(map[string]*parquetFile),
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (p *ParquetWriter) Options() Options {
+	return p.o
+}
+
+// WriteArtist function writes an artist as rows into Parquet files.
+func (p *ParquetWriter) WriteArtist(artist model.Artist) error {
+	return p.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artists as rows into Parquet files.
+func (p *ParquetWriter) WriteArtists(artists []model.Artist) error {
+	var rows []row
+	for _, a := range artists {
+		rows = append(rows, artistRows(a, p.o)...)
+	}
+
+	return p.writeRows(rows)
+}
+
+// WriteLabel function writes a label as rows into Parquet files.
+func (p *ParquetWriter) WriteLabel(label model.Label) error {
+	return p.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of labels as rows into Parquet files.
+func (p *ParquetWriter) WriteLabels(labels []model.Label) error {
+	var rows []row
+	for _, l := range labels {
+		rows = append(rows, labelRows(l, p.o)...)
+	}
+
+	return p.writeRows(rows)
+}
+
+// WriteMaster function writes a master as rows into Parquet files.
+func (p *ParquetWriter) WriteMaster(master model.Master) error {
+	return p.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of masters as rows into Parquet files.
+func (p *ParquetWriter) WriteMasters(masters []model.Master) error {
+	var rows []row
+	for _, m := range masters {
+		rows = append(rows, masterRows(m, p.o)...)
+	}
+
+	return p.writeRows(rows)
+}
+
+// WriteRelease function writes a release as rows into Parquet files.
+func (p *ParquetWriter) WriteRelease(release model.Release) error {
+	return p.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of releases as rows into Parquet files.
+func (p *ParquetWriter) WriteReleases(releases []model.Release) error {
+	var rows []row
+	for _, r := range releases {
+		rows = append(rows, releaseRows(r, p.o)...)
+	}
+
+	return p.writeRows(rows)
+}
+
+// Close writes remaining rows and footers of all files and closes outputs implementing the io.Closer interface.
+// The first occurred error is returned.
+func (p *ParquetWriter) Close() error {
+	err := p.err
+	for _, f := range p.order {
+		if p.err == nil {
+			if f.rows > 0 {
+				f.writeRowGroup(p.o.Parquet.Compression)
+			}
+
+			f.writeFooter()
+			if f.err != nil && err == nil {
+				err = f.err
+			}
+		}
+
+		if cl, ok := f.w.(io.Closer); ok {
+			if cErr := cl.Close(); cErr != nil && err == nil {
+				err = cErr
+			}
+		}
+	}
+
+	p.order = nil
+	p.files = make(map[string]*parquetFile)
+
+	return err
+}
+
+// Finish writes remaining rows as row groups at the end of the run and flushes outputs. Files are kept open, so
+// tables shared by more entity kinds (such as images) are written by following runs into the same files, footers are
+// written by the Close function.
+func (p *ParquetWriter) Finish(error) error {
+	for _, f := range p.order {
+		if p.err != nil {
+			break
+		}
+
+		if f.rows > 0 {
+			f.writeRowGroup(p.o.Parquet.Compression)
+		}
+
+		p.err = f.err
+		if p.err == nil {
+			p.err = flushOutput(f.w)
+		}
+	}
+
+	return p.err
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+func (p *ParquetWriter) writeRows(rows []row) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	tables, groups := groupRows(rows)
+	for _, t := range tables {
+		f := p.file(t)
+		if p.err != nil {
+			return p.err
+		}
+
+		for _, r := range groups[t.name] {
+			f.add(r)
+			if f.rows >= p.o.Parquet.RowGroupSize {
+				f.writeRowGroup(p.o.Parquet.Compression)
+			}
+		}
+
+		if f.err != nil {
+			p.err = f.err
+			return p.err
+		}
+	}
+
+	return p.err
+}
+
+// file returns the Parquet file of the table, when the table is used for the first time the output is opened
+// and the magic number is written.
+func (p *ParquetWriter) file(t table) *parquetFile {
+	if f, ok := p.files[t.name]; ok {
+		return f
+	}
+
+	var w io.Writer
+	w, p.err = p.open(t.name)
+	if p.err != nil {
+		return nil
+	}
+
+	f := newParquetFile(t, w)
+	p.files[t.name] = f
+	p.order = append(p.order, f)
+	f.write([]byte(parquetMagic))
+	p.err = f.err
+
+	return f
+}
+
+// parquetFile holds the metadata of already written row groups and values of the current row group.
+type parquetFile struct {
+	t       table
+	w       io.Writer
+	offset  int64
+	columns []*parquetColumn
+	rows    int
+	numRows int64
+	groups  []interface{}
+	err     error
+}
+
+// parquetColumn holds values of the current row group. Levels are used by array columns only, which are stored as
+// three-level lists: optional group (LIST), repeated group list and required element.
+type parquetColumn struct {
+	name        string
+	array       bool
+	values      []string
+	repetitions []byte
+	definitions []byte
+	nulls       int64
+	min, max    string
+}
+
+func newParquetFile(t table, w io.Writer) *parquetFile {
+	f := &parquetFile{t: t, w: w}
+	for i, c := range t.columns {
+		f.columns = append(f.columns, &parquetColumn{name: c, array: strings.HasSuffix(t.types[i], "[]")})
+	}
+
+	return f
+}
+
+func (f *parquetFile) write(b []byte) {
+	if f.err != nil {
+		return
+	}
+
+	var n int
+	n, f.err = f.w.Write(b)
+	f.offset += int64(n)
+}
+
+func (f *parquetFile) add(r row) {
+	for i, v := range r.values {
+		switch val := v.(type) {
+		case string:
+			f.columns[i].add(val)
+		case []string:
+			f.columns[i].addArray(val)
+		}
+	}
+
+	f.rows++
+}
+
+func (f *parquetFile) writeRowGroup(c ParquetCompression) {
+	var chunks []interface{}
+	var size int64
+	for _, col := range f.columns {
+		chunk, n := f.writeColumn(col, c)
+		chunks = append(chunks, chunk)
+		size += n
+	}
+
+	f.groups = append(f.groups, thriftStruct{
+		{id: 1, value: thriftList{elem: thriftTypeStruct, values: chunks}},
+		{id: 2, value: size},
+		{id: 3, value: int64(f.rows)},
+	})
+
+	f.numRows += int64(f.rows)
+	f.rows = 0
+}
+
+// writeColumn writes the column chunk as a single data page and returns its metadata and uncompressed size.
+func (f *parquetFile) writeColumn(col *parquetColumn, c ParquetCompression) (thriftStruct, int64) {
+	page := &bytes.Buffer{}
+	count := len(col.values)
+	path := []interface{}{col.name}
+	encodings := []interface{}{parquetPlain}
+	if col.array {
+		count = len(col.definitions)
+		path = append(path, "list", "element")
+		encodings = append(encodings, parquetRLE)
+		writeLevels(page, col.repetitions)
+		writeLevels(page, col.definitions)
+	}
+
+	for _, v := range col.values {
+		_ = binary.Write(page, binary.LittleEndian, uint32(len(v)))
+		page.WriteString(v)
+	}
+
+	data := compressPage(page.Bytes(), c)
+	header := encodeThrift(thriftStruct{
+		{id: 1, value: parquetDataPage},
+		{id: 2, value: int32(page.Len())},
+		{id: 3, value: int32(len(data))},
+		{id: 5, value: thriftStruct{
+			{id: 1, value: int32(count)},
+			{id: 2, value: parquetPlain},
+			{id: 3, value: parquetRLE},
+			{id: 4, value: parquetRLE},
+		}},
+	})
+
+	offset := f.offset
+	f.write(header)
+	f.write(data)
+
+	statistics := thriftStruct{{id: 3, value: col.nulls}}
+	if len(col.values) > 0 {
+		statistics = append(statistics, thriftField{id: 5, value: []byte(col.max)}, thriftField{id: 6, value: []byte(col.min)})
+	}
+
+	size := int64(len(header) + page.Len())
+	meta := thriftStruct{
+		{id: 1, value: parquetByteArray},
+		{id: 2, value: thriftList{elem: thriftTypeI32, values: encodings}},
+		{id: 3, value: thriftList{elem: thriftTypeBinary, values: path}},
+		{id: 4, value: int32(c)},
+		{id: 5, value: int64(count)},
+		{id: 6, value: size},
+		{id: 7, value: int64(len(header) + len(data))},
+		{id: 9, value: offset},
+		{id: 12, value: statistics},
+	}
+
+	col.reset()
+	return thriftStruct{{id: 2, value: offset}, {id: 3, value: meta}}, size
+}
+
+func (f *parquetFile) writeFooter() {
+	schema := []interface{}{thriftStruct{
+		{id: 4, value: "schema"},
+		{id: 5, value: int32(len(f.columns))},
+	}}
+
+	for _, col := range f.columns {
+		if !col.array {
+			schema = append(schema, stringElement(col.name, parquetRequired))
+			continue
+		}
+
+		schema = append(schema,
+			thriftStruct{
+				{id: 3, value: parquetOptional},
+				{id: 4, value: col.name},
+				{id: 5, value: int32(1)},
+				{id: 6, value: parquetList},
+			},
+			thriftStruct{
+				{id: 3, value: parquetRepeated},
+				{id: 4, value: "list"},
+				{id: 5, value: int32(1)},
+			},
+			stringElement("element", parquetRequired))
+	}
+
+	meta := encodeThrift(thriftStruct{
+		{id: 1, value: int32(1)},
+		{id: 2, value: thriftList{elem: thriftTypeStruct, values: schema}},
+		{id: 3, value: f.numRows},
+		{id: 4, value: thriftList{elem: thriftTypeStruct, values: f.groups}},
+		{id: 6, value: parquetCreatedBy},
+	})
+
+	length := make([]byte, 4)
+	binary.LittleEndian.PutUint32(length, uint32(len(meta)))
+
+	f.write(meta)
+	f.write(length)
+	f.write([]byte(parquetMagic))
+}
+
+func stringElement(name string, repetition int32) thriftStruct {
+	return thriftStruct{
+		{id: 1, value: parquetByteArray},
+		{id: 3, value: repetition},
+		{id: 4, value: name},
+		{id: 6, value: parquetUTF8},
+	}
+}
+
+func (c *parquetColumn) add(v string) {
+	c.values = append(c.values, v)
+	c.stats(v)
+}
+
+// addArray adds the list, the nil slice is the null list (definition level 0), the empty slice is the empty list
+// (definition level 1) and each item has definition level 2. The first item of the list starts a new row, which is
+// repetition level 0. Only null lists are counted as nulls, the empty list is a value.
+func (c *parquetColumn) addArray(values []string) {
+	switch {
+	case values == nil:
+		c.repetitions = append(c.repetitions, 0)
+		c.definitions = append(c.definitions, 0)
+		c.nulls++
+	case len(values) == 0:
+		c.repetitions = append(c.repetitions, 0)
+		c.definitions = append(c.definitions, 1)
+	}
+
+	for i, v := range values {
+		level := byte(1)
+		if i == 0 {
+			level = 0
+		}
+
+		c.repetitions = append(c.repetitions, level)
+		c.definitions = append(c.definitions, parquetMaxDefinitions)
+		c.add(v)
+	}
+}
+
+func (c *parquetColumn) stats(v string) {
+	if len(c.values) == 1 || v < c.min {
+		c.min = v
+	}
+
+	if len(c.values) == 1 || v > c.max {
+		c.max = v
+	}
+}
+
+func (c *parquetColumn) reset() {
+	c.values = c.values[:0]
+	c.repetitions = c.repetitions[:0]
+	c.definitions = c.definitions[:0]
+	c.nulls = 0
+}
+
+// writeLevels writes levels by the RLE/bit-packing hybrid encoding (as RLE runs only) prefixed by the length. Levels
+// are at most 2, thus each value of the run takes one byte.
+func writeLevels(b *bytes.Buffer, levels []byte) {
+	runs := &bytes.Buffer{}
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+
+		writeUvarint(runs, uint64(j-i)<<1)
+		runs.WriteByte(levels[i])
+		i = j
+	}
+
+	_ = binary.Write(b, binary.LittleEndian, uint32(runs.Len()))
+	b.Write(runs.Bytes())
+}
+
+func compressPage(data []byte, c ParquetCompression) []byte {
+	switch c {
+	case ParquetSnappy:
+		return snappyEncode(data)
+	case ParquetGzip:
+		b := &bytes.Buffer{}
+		gw := gzip.NewWriter(b)
+		_, _ = gw.Write(data)
+		_ = gw.Close()
+		return b.Bytes()
+	default:
+		return data
+	}
+}
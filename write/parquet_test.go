@@ -0,0 +1,395 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newParquetBuffers() (map[string]*bytes.Buffer, func(string) (io.Writer, error)) {
+	outputs := make(map[string]*bytes.Buffer)
+	return outputs, func(table string) (io.Writer, error) {
+		b := &bytes.Buffer{}
+		outputs[table] = b
+		return b, nil
+	}
+}
+
+func TestParquetWriter_Options(t *testing.T) {
+	p := NewParquetWriterFunc(nil, nil)
+	opt := p.Options()
+
+	if opt.Parquet.RowGroupSize != defaultRowGroupSize {
+		t.Error("row group size should be set as a default")
+	}
+
+	if opt.Parquet.Compression != ParquetUncompressed {
+		t.Error("pages should be uncompressed as a default")
+	}
+}
+
+func TestParquetWriter_WriteReleases(t *testing.T) {
+	var rows []row
+	for _, r := range releases {
+		rows = append(rows, releaseRows(r, Options{})...)
+	}
+
+	tables, groups := groupRows(rows)
+	for _, c := range []ParquetCompression{ParquetUncompressed, ParquetSnappy, ParquetGzip} {
+		outputs, open := newParquetBuffers()
+		p := NewParquetWriterFunc(open, &Options{Parquet: Parquet{RowGroupSize: 2, Compression: c}})
+		for _, r := range releases {
+			_ = p.WriteRelease(r)
+		}
+
+		err := Close(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(outputs) != len(tables) {
+			t.Errorf("%d tables should be written, got %d", len(tables), len(outputs))
+		}
+
+		for _, tab := range tables {
+			f := readParquet(t, outputs[tab.name].Bytes())
+			if !reflect.DeepEqual(f.columns, tab.columns) {
+				t.Errorf("columns of %s differ: %v", tab.name, f.columns)
+			}
+
+			expected := make([][]interface{}, 0, len(groups[tab.name]))
+			for _, r := range groups[tab.name] {
+				expected = append(expected, r.values)
+			}
+
+			if !reflect.DeepEqual(f.rows, expected) {
+				t.Errorf("rows of %s read by compression %d differ\n%v\n%v", tab.name, c, f.rows, expected)
+			}
+
+			if f.numRows != int64(len(expected)) || len(f.groups) != (len(expected)+1)/2 {
+				t.Errorf("%s should have %d rows in row groups of 2 rows, got %d rows in %d row groups", tab.name,
+					len(expected), f.numRows, len(f.groups))
+			}
+		}
+	}
+}
+
+func TestParquetWriter_Statistics(t *testing.T) {
+	outputs, open := newParquetBuffers()
+	p := NewParquetWriterFunc(open, nil)
+	_ = p.WriteLabels(labels)
+	_ = p.WriteMasters(masters)
+	_ = Close(p)
+
+	f := readParquet(t, outputs["label_labels"].Bytes())
+	stats := f.statistics(0, 1)
+	if string(stats[6].([]byte)) != "153760" || string(stats[5].([]byte)) != "86537" || stats[3].(int64) != 0 {
+		t.Errorf("unexpected statistics of sub label IDs %v", stats)
+	}
+
+	f = readParquet(t, outputs["masters"].Bytes())
+	stats = f.statistics(0, 2)
+	if string(stats[6].([]byte)) != "Electronic" || string(stats[5].([]byte)) != "Electronic" {
+		t.Errorf("unexpected statistics of genres %v", stats)
+	}
+}
+
+func TestParquetWriter_Statistics_Nulls(t *testing.T) {
+	outputs, open := newParquetBuffers()
+	p := NewParquetWriterFunc(open, nil)
+	_ = p.WriteArtists([]model.Artist{
+		{ID: "1", NameVariations: nil},
+		{ID: "2", NameVariations: []string{}},
+		{ID: "3", NameVariations: []string{"Persuader"}},
+	})
+	_ = Close(p)
+
+	f := readParquet(t, outputs["artists"].Bytes())
+	if stats := f.statistics(0, 5); stats[3].(int64) != 1 {
+		t.Errorf("only the null list should be counted as null, not the empty one %v", stats)
+	}
+
+	if got := f.rows[1][5]; !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("the empty list should be read as the empty list, got %#v", got)
+	}
+}
+
+func TestParquetWriter_Directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discogs-parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewParquetWriter(dir, &Options{Parquet: Parquet{Compression: ParquetSnappy}})
+	err = p.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = Close(p)
+	if err != nil {
+		t.Error(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "artist_members.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := readParquet(t, b)
+	expected := [][]interface{}{{"2", "26", "Alexi Delano"}, {"2", "27", "Cari Lekebusch"}}
+	if !reflect.DeepEqual(f.rows, expected) {
+		t.Errorf("artist members differ from what it's expected: %v", f.rows)
+	}
+}
+
+// parquetData is the content of the Parquet file read back by the test reader.
+type parquetData struct {
+	columns []string
+	rows    [][]interface{}
+	numRows int64
+	groups  []map[int16]interface{}
+}
+
+// statistics returns statistics of the column chunk.
+func (f parquetData) statistics(group, column int) map[int16]interface{} {
+	chunk := f.groups[group][1].([]interface{})[column].(map[int16]interface{})
+	return chunk[3].(map[int16]interface{})[12].(map[int16]interface{})
+}
+
+// readParquet reads the Parquet file created by the Parquet writer, it supports single data page column chunks
+// of string and list of strings columns.
+func readParquet(t *testing.T, data []byte) parquetData {
+	if !bytes.HasPrefix(data, []byte(parquetMagic)) || !bytes.HasSuffix(data, []byte(parquetMagic)) {
+		t.Fatal("file should start and end with the magic number")
+	}
+
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	tr := &thriftReader{b: data[len(data)-8-length : len(data)-8]}
+	meta := tr.readStruct()
+	if tr.err != nil {
+		t.Fatal(tr.err)
+	}
+
+	f := parquetData{numRows: meta[3].(int64)}
+	var arrays []bool
+	schema := meta[2].([]interface{})
+	for i := 1; i < len(schema); i++ {
+		e := schema[i].(map[int16]interface{})
+		f.columns = append(f.columns, string(e[4].([]byte)))
+		_, group := e[5]
+		arrays = append(arrays, group)
+		if group {
+			i += 2
+		}
+	}
+
+	for _, g := range meta[4].([]interface{}) {
+		group := g.(map[int16]interface{})
+		f.groups = append(f.groups, group)
+
+		rows := make([][]interface{}, group[3].(int64))
+		for i, c := range group[1].([]interface{}) {
+			cm := c.(map[int16]interface{})[3].(map[int16]interface{})
+			values := readColumn(t, data, cm, arrays[i])
+			if len(values) != len(rows) {
+				t.Fatalf("column %s has %d values for %d rows", f.columns[i], len(values), len(rows))
+			}
+
+			for r, v := range values {
+				rows[r] = append(rows[r], v)
+			}
+		}
+
+		f.rows = append(f.rows, rows...)
+	}
+
+	return f
+}
+
+func readColumn(t *testing.T, data []byte, meta map[int16]interface{}, array bool) []interface{} {
+	offset := meta[9].(int64)
+	r := &thriftReader{b: data[offset:]}
+	header := r.readStruct()
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	start := int(offset) + len(data[offset:]) - len(r.b)
+	page := data[start : start+int(header[3].(int64))]
+	switch ParquetCompression(meta[4].(int64)) {
+	case ParquetSnappy:
+		var err error
+		page, err = snappyDecode(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case ParquetGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(page))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, _ = ioutil.ReadAll(gr)
+	}
+
+	if len(page) != int(header[2].(int64)) {
+		t.Fatalf("uncompressed page size %d differs from %d", len(page), header[2])
+	}
+
+	count := int(header[5].(map[int16]interface{})[1].(int64))
+	var repetitions, definitions []byte
+	if array {
+		repetitions, page = readLevels(t, page, count)
+		definitions, page = readLevels(t, page, count)
+	}
+
+	var strs []string
+	for len(page) > 0 {
+		l := int(binary.LittleEndian.Uint32(page))
+		strs = append(strs, string(page[4:4+l]))
+		page = page[4+l:]
+	}
+
+	var values []interface{}
+	if !array {
+		for _, s := range strs {
+			values = append(values, s)
+		}
+
+		return values
+	}
+
+	for i := range definitions {
+		if repetitions[i] == 0 {
+			var list []string
+			if definitions[i] > 0 {
+				list = []string{}
+			}
+
+			values = append(values, list)
+		}
+
+		if definitions[i] == parquetMaxDefinitions {
+			values[len(values)-1] = append(values[len(values)-1].([]string), strs[0])
+			strs = strs[1:]
+		}
+	}
+
+	return values
+}
+
+// readLevels reads levels encoded by RLE runs, bit-packed runs are not supported.
+func readLevels(t *testing.T, page []byte, count int) ([]byte, []byte) {
+	length := int(binary.LittleEndian.Uint32(page))
+	runs := page[4 : 4+length]
+
+	var levels []byte
+	for len(runs) > 0 {
+		h, n := binary.Uvarint(runs)
+		if h&1 == 1 {
+			t.Fatal("bit-packed runs are not supported")
+		}
+
+		levels = append(levels, bytes.Repeat([]byte{runs[n]}, int(h>>1))...)
+		runs = runs[n+1:]
+	}
+
+	if len(levels) != count {
+		t.Fatalf("%d levels expected, got %d", count, len(levels))
+	}
+
+	return levels, page[4+length:]
+}
+
+// thriftReader decodes the Thrift compact protocol, structures are read as maps of field IDs, integers as int64
+// values, binaries as byte slices and lists as slices.
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	s := make(map[int16]interface{})
+	var last int16
+	for r.err == nil && len(r.b) > 0 {
+		h := r.b[0]
+		r.b = r.b[1:]
+		if h == 0 {
+			return s
+		}
+
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.varint())
+		}
+
+		s[id] = r.readValue(h & 0x0f)
+		last = id
+	}
+
+	if r.err == nil {
+		r.err = io.ErrUnexpectedEOF
+	}
+
+	return s
+}
+
+func (r *thriftReader) readValue(typ byte) interface{} {
+	switch typ {
+	case thriftTypeI32, thriftTypeI64:
+		return r.varint()
+	case thriftTypeBinary:
+		l := int(r.uvarint())
+		v := r.b[:l]
+		r.b = r.b[l:]
+		return v
+	case thriftTypeList:
+		h := r.b[0]
+		r.b = r.b[1:]
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+
+		values := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			values = append(values, r.readValue(h&0x0f))
+		}
+
+		return values
+	case thriftTypeStruct:
+		return r.readStruct()
+	default:
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	x, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+
+	r.b = r.b[n:]
+	return x
+}
+
+func (r *thriftReader) varint() int64 {
+	x := r.uvarint()
+	return int64(x>>1) ^ -int64(x&1)
+}
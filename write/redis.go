@@ -0,0 +1,367 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Redis options are used by Redis writers only and define key patterns, where %s is replaced by the entity ID.
+// ArtistKey, LabelKey, MasterKey and ReleaseKey are keys of entity hashes (artist:%s, label:%s, master:%s and
+// release:%s by default), ArtistReleasesKey is the key of the set of release IDs of the artist (artist:%s:releases
+// by default) and ReleaseArtistsKey is the key of the set of artist IDs of the release (release:%s:artists
+// by default).
+type Redis struct {
+	ArtistKey         string
+	LabelKey          string
+	MasterKey         string
+	ReleaseKey        string
+	ArtistReleasesKey string
+	ReleaseArtistsKey string
+}
+
+// RedisError is returned when the Redis server replies to a command by an error.
+type RedisError struct {
+	Message string
+}
+
+func (e *RedisError) Error() string {
+	return "redis: " + e.Message
+}
+
+var errNotCorrectRedisReply = errors.New("redis reply is not correct")
+
+// unlinkReleaseScript removes the release (ARGV[2]) from sets of artists stored in the set of release artists
+// (KEYS[1]) and deletes that set. ARGV[1] is the key pattern of artist release sets.
+const unlinkReleaseScript = `for _, a in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+  redis.call('SREM', string.format(ARGV[1], a), ARGV[2])
+end
+return redis.call('DEL', KEYS[1])`
+
+// RedisWriter is one of few provided writers that implements the Writer interface and provides the ability to load
+// decoded data into Redis. Each entity is stored as a hash by the HSET command, fields of the hash are properties
+// of the JSON record (see JSONWriter), values which are not strings, such as aliases or track lists, are stored
+// as JSON and null values are left out. The hash is deleted first, so fields of the previous import don't remain.
+// Release IDs are added into sets of release artists by the SADD command, thus releases of the artist can be looked
+// up. Artists of the release are kept in the set of release artists as well, so the rewritten release is removed
+// from sets of its former artists by the EVAL script first. Commands are encoded in the RESP protocol.
+type RedisWriter struct {
+	o   Options
+	w   io.Writer
+	r   *bufio.Reader
+	b   bytes.Buffer
+	n   int
+	err error
+}
+
+// NewRedisWriter creates a new Writer instance that writes RESP commands into the output (for instance a file) for
+// the mass insertion by redis-cli --pipe.
+func NewRedisWriter(output io.Writer, options *Options) Writer {
+	return newRedisWriter(output, nil, options)
+}
+
+// NewRedisConnWriter creates a new Writer instance that sends RESP commands directly over the connection to the Redis
+// server, such as the one created by net.Dial("tcp", "localhost:6379"). Commands of each written block are pipelined
+// and then all replies are read, the first error reply is returned as RedisError. Commands of each entity are wrapped
+// in the MULTI/EXEC transaction, so other clients don't see the entity half written. The connection is not closed
+// by the writer.
+func NewRedisConnWriter(conn net.Conn, options *Options) Writer {
+	return newRedisWriter(conn, bufio.NewReader(conn), options)
+}
+
+func newRedisWriter(w io.Writer, r *bufio.Reader, options *Options) *RedisWriter {
+	if options == nil {
+		options = &Options{}
+	}
+
+	o := *options
+	defaultPattern(&o.Redis.ArtistKey, "artist:%s")
+	defaultPattern(&o.Redis.LabelKey, "label:%s")
+	defaultPattern(&o.Redis.MasterKey, "master:%s")
+	defaultPattern(&o.Redis.ReleaseKey, "release:%s")
+	defaultPattern(&o.Redis.ArtistReleasesKey, "artist:%s:releases")
+	defaultPattern(&o.Redis.ReleaseArtistsKey, "release:%s:artists")
+
+	return &RedisWriter{o: o, w: w, r: r}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (r *RedisWriter) Options() Options {
+	return r.o
+}
+
+// WriteArtist function writes an artist hash.
+func (r *RedisWriter) WriteArtist(artist model.Artist) error {
+	return r.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists function writes a slice of artist hashes.
+func (r *RedisWriter) WriteArtists(artists []model.Artist) error {
+	for _, a := range artists {
+		r.multi()
+		r.hset(r.o.Redis.ArtistKey, a.ID, r.o.excludeArtist(a))
+		r.exec()
+	}
+
+	return r.send()
+}
+
+// WriteLabel function writes a label hash.
+func (r *RedisWriter) WriteLabel(label model.Label) error {
+	return r.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels function writes a slice of label hashes.
+func (r *RedisWriter) WriteLabels(labels []model.Label) error {
+	for _, l := range labels {
+		r.multi()
+		r.hset(r.o.Redis.LabelKey, l.ID, r.o.excludeLabel(l))
+		r.exec()
+	}
+
+	return r.send()
+}
+
+// WriteMaster function writes a master hash.
+func (r *RedisWriter) WriteMaster(master model.Master) error {
+	return r.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters function writes a slice of master hashes.
+func (r *RedisWriter) WriteMasters(masters []model.Master) error {
+	for _, m := range masters {
+		r.multi()
+		r.hset(r.o.Redis.MasterKey, m.ID, r.o.excludeMaster(m))
+		r.exec()
+	}
+
+	return r.send()
+}
+
+// WriteRelease function writes a release hash and replaces the release in sets of its artists.
+func (r *RedisWriter) WriteRelease(release model.Release) error {
+	return r.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases function writes a slice of release hashes and replaces releases in sets of their artists.
+func (r *RedisWriter) WriteReleases(releases []model.Release) error {
+	for _, rl := range releases {
+		r.multi()
+		r.hset(r.o.Redis.ReleaseKey, rl.ID, r.o.excludeRelease(rl))
+
+		artistsKey := fmt.Sprintf(r.o.Redis.ReleaseArtistsKey, rl.ID)
+		r.command("EVAL", unlinkReleaseScript, "1", artistsKey, r.o.Redis.ArtistReleasesKey, rl.ID)
+
+		ids := []string{"SADD", artistsKey}
+		added := make(map[string]bool)
+		for _, a := range rl.Artists {
+			if a.ID == "" || added[a.ID] {
+				continue
+			}
+
+			added[a.ID] = true
+			ids = append(ids, a.ID)
+			r.command("SADD", fmt.Sprintf(r.o.Redis.ArtistReleasesKey, a.ID), rl.ID)
+		}
+
+		if len(ids) > 2 {
+			r.command(ids...)
+		}
+
+		r.exec()
+	}
+
+	return r.send()
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (r *RedisWriter) Flush() error {
+	if r.err != nil {
+		return r.err
+	}
+
+	return flushOutput(r.w)
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+func defaultPattern(pattern *string, value string) {
+	if *pattern == "" {
+		*pattern = value
+	}
+}
+
+// multi starts the transaction of the entity in the direct mode.
+func (r *RedisWriter) multi() {
+	if r.r != nil {
+		r.command("MULTI")
+	}
+}
+
+// exec executes the transaction of the entity in the direct mode.
+func (r *RedisWriter) exec() {
+	if r.r != nil {
+		r.command("EXEC")
+	}
+}
+
+// hset adds the DEL command of the hash and the HSET command with properties of the JSON record as fields
+// of the hash.
+func (r *RedisWriter) hset(pattern, id string, record interface{}) {
+	if r.err != nil {
+		return
+	}
+
+	b, err := r.o.marshal(record)
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	var properties map[string]json.RawMessage
+	r.err = json.Unmarshal(b, &properties)
+	if r.err != nil {
+		return
+	}
+
+	fields := make([]string, 0, len(properties))
+	for f := range properties {
+		fields = append(fields, f)
+	}
+
+	sort.Strings(fields)
+
+	key := fmt.Sprintf(pattern, id)
+	args := []string{"HSET", key}
+	for _, f := range fields {
+		v := string(properties[f])
+		if v == "null" {
+			continue
+		}
+
+		if strings.HasPrefix(v, `"`) {
+			r.err = json.Unmarshal(properties[f], &v)
+			if r.err != nil {
+				return
+			}
+		}
+
+		args = append(args, f, v)
+	}
+
+	r.command("DEL", key)
+	if len(args) > 2 {
+		r.command(args...)
+	}
+}
+
+// command adds the command encoded as RESP array of bulk strings.
+func (r *RedisWriter) command(args ...string) {
+	r.b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		r.b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+
+	r.n++
+}
+
+// send writes buffered commands and reads their replies in the direct mode.
+func (r *RedisWriter) send() error {
+	n := r.n
+	r.n = 0
+	if r.err == nil {
+		_, r.err = r.w.Write(r.b.Bytes())
+	}
+
+	r.b.Reset()
+	if r.r == nil || r.err != nil {
+		return r.err
+	}
+
+	// all replies are read to keep the connection in sync, the first error reply is returned
+	var replyErr error
+	for i := 0; i < n; i++ {
+		err := readRedisReply(r.r)
+		var re *RedisError
+		if errors.As(err, &re) {
+			if replyErr == nil {
+				replyErr = err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			r.err = err
+			return r.err
+		}
+	}
+
+	return replyErr
+}
+
+// readRedisReply reads one RESP reply, the error reply is returned as RedisError.
+func readRedisReply(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return errNotCorrectRedisReply
+	}
+
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return &RedisError{Message: line[1:]}
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return errNotCorrectRedisReply
+		}
+
+		if n < 0 {
+			return nil
+		}
+
+		_, err = r.Discard(n + 2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return errNotCorrectRedisReply
+		}
+
+		var replyErr error
+		for i := 0; i < n; i++ {
+			err = readRedisReply(r)
+			var re *RedisError
+			if err != nil && !errors.As(err, &re) {
+				return err
+			}
+
+			if err != nil && replyErr == nil {
+				replyErr = err
+			}
+		}
+
+		return replyErr
+	default:
+		return errNotCorrectRedisReply
+	}
+}
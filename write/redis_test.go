@@ -0,0 +1,338 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRedis is the local RESP server storing hashes and sets. Commands with the key starting by "fail:" are replied
+// by the error. Transactions are queued until EXEC, the EVAL command runs the unlink release script only.
+type fakeRedis struct {
+	mu     sync.Mutex
+	ln     net.Listener
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeRedis{ln: ln, hashes: make(map[string]map[string]string), sets: make(map[string]map[string]bool)}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go f.serve(conn)
+		}
+	}()
+
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	var queue [][]string
+	var queued bool
+	for {
+		args, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+
+		reply := "+QUEUED\r\n"
+		switch {
+		case args[0] == "MULTI":
+			queue, queued = nil, true
+			reply = "+OK\r\n"
+		case args[0] == "EXEC":
+			f.mu.Lock()
+			reply = "*" + strconv.Itoa(len(queue)) + "\r\n"
+			for _, q := range queue {
+				reply += f.execute(q)
+			}
+			f.mu.Unlock()
+			queue, queued = nil, false
+		case queued:
+			queue = append(queue, args)
+		default:
+			f.mu.Lock()
+			reply = f.execute(args)
+			f.mu.Unlock()
+		}
+
+		_, _ = conn.Write([]byte(reply))
+	}
+}
+
+func (f *fakeRedis) execute(args []string) string {
+	if len(args) < 2 || strings.HasPrefix(args[1], "fail:") {
+		return "-ERR refused by the test\r\n"
+	}
+
+	switch args[0] {
+	case "DEL":
+		delete(f.hashes, args[1])
+		delete(f.sets, args[1])
+		return ":1\r\n"
+	case "EVAL":
+		// KEYS[1] is the set of release artists, ARGV are the pattern of artist release sets and the release ID
+		for a := range f.sets[args[3]] {
+			delete(f.sets[strings.Replace(args[4], "%s", a, 1)], args[5])
+		}
+
+		delete(f.sets, args[3])
+		return ":1\r\n"
+	case "HSET":
+		if f.hashes[args[1]] == nil {
+			f.hashes[args[1]] = make(map[string]string)
+		}
+
+		for i := 2; i+1 < len(args); i += 2 {
+			f.hashes[args[1]][args[i]] = args[i+1]
+		}
+
+		return ":" + strconv.Itoa((len(args)-2)/2) + "\r\n"
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = make(map[string]bool)
+		}
+
+		for _, m := range args[2:] {
+			f.sets[args[1]][m] = true
+		}
+
+		return ":" + strconv.Itoa(len(args)-2) + "\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// readRedisCommand reads the command encoded as RESP array of bulk strings.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if err != nil || line[0] != '*' {
+		return nil, errNotCorrectRedisReply
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+		if err != nil || line[0] != '$' {
+			return nil, errNotCorrectRedisReply
+		}
+
+		b := make([]byte, size+2)
+		_, err = io.ReadFull(r, b)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = string(b[:size])
+	}
+
+	return args, nil
+}
+
+func TestRedisWriter_Options(t *testing.T) {
+	opt := NewRedisWriter(nil, &Options{Redis: Redis{ReleaseKey: "r:%s"}}).Options()
+	if opt.Redis.ReleaseKey != "r:%s" || opt.Redis.ArtistKey != "artist:%s" ||
+		opt.Redis.ArtistReleasesKey != "artist:%s:releases" {
+		t.Errorf("unexpected key patterns %+v", opt.Redis)
+	}
+}
+
+func TestRedisWriter_WriteArtist(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewRedisWriter(b, &Options{Redis: Redis{ArtistKey: "discogs:artist:%s"}})
+
+	err := w.WriteArtist(artists[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(b)
+	args, err := readRedisCommand(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args[0] != "DEL" || args[1] != "discogs:artist:2" {
+		t.Fatalf("hash should be deleted first, got %v", args)
+	}
+
+	args, err = readRedisCommand(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args[0] != "HSET" || args[1] != "discogs:artist:2" || args[2] != "aliases" || args[4] != "data_quality" {
+		t.Fatalf("unexpected command %v", args)
+	}
+
+	fields := make(map[string]string)
+	for i := 2; i+1 < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+
+	if fields["id"] != "2" || fields["name"] != artists[0].Name ||
+		!strings.HasPrefix(fields["members"], `[{"id":"26",`) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	if r.Buffered() != 0 {
+		t.Errorf("only two commands should be written, remains %d bytes", r.Buffered())
+	}
+}
+
+func TestRedisConnWriter(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.ln.Close()
+
+	conn, err := net.Dial("tcp", f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewRedisConnWriter(conn, nil)
+	err = w.WriteReleases(releases)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.WriteMasters(masters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.hashes) != len(releases)+len(masters) || f.hashes["release:2"]["title"] != releases[0].Title {
+		t.Errorf("all releases and masters should be stored, got %d hashes", len(f.hashes))
+	}
+
+	if !f.sets["artist:2:releases"]["2"] {
+		t.Errorf("release 2 should be in the set of artist 2: %v", f.sets)
+	}
+}
+
+func TestRedisConnWriter_Rewrite(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.ln.Close()
+
+	conn, err := net.Dial("tcp", f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewRedisConnWriter(conn, nil)
+	err = w.WriteRelease(releases[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl := releases[0]
+	rl.Videos = nil
+	rl.Artists = []model.ReleaseArtist{{ID: "194", Name: "Various"}}
+	err = w.WriteRelease(rl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.hashes["release:2"]["videos"]; ok || f.hashes["release:2"]["title"] != rl.Title {
+		t.Errorf("videos of the previous import should be removed: %v", f.hashes["release:2"])
+	}
+
+	if f.sets["artist:2:releases"]["2"] || !f.sets["artist:194:releases"]["2"] {
+		t.Errorf("release 2 should be moved into the set of artist 194: %v", f.sets)
+	}
+
+	if len(f.sets["release:2:artists"]) != 1 || !f.sets["release:2:artists"]["194"] {
+		t.Errorf("artists of release 2 should be replaced: %v", f.sets["release:2:artists"])
+	}
+}
+
+func TestRedisConnWriter_ErrorReply(t *testing.T) {
+	f := newFakeRedis(t)
+	defer f.ln.Close()
+
+	conn, err := net.Dial("tcp", f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w := NewRedisConnWriter(conn, &Options{Redis: Redis{LabelKey: "fail:%s"}})
+	err = w.WriteLabels(labels)
+
+	var re *RedisError
+	if !errors.As(err, &re) || re.Message != "ERR refused by the test" {
+		t.Fatalf("error reply should be returned, got %v", err)
+	}
+
+	// replies of the failed block are consumed, so the connection can be used further
+	err = w.WriteArtists(artists)
+	if err != nil {
+		t.Error(err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.hashes["artist:2"]; !ok {
+		t.Error("artist should be stored after the failed block")
+	}
+}
+
+func TestReadRedisReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("+OK\r\n$5\r\nhello\r\n$-1\r\n*2\r\n:1\r\n-ERR nested\r\n?\r\n"))
+	for i := 0; i < 3; i++ {
+		if err := readRedisReply(r); err != nil {
+			t.Errorf("reply %d should be read, got %v", i, err)
+		}
+	}
+
+	var re *RedisError
+	if err := readRedisReply(r); !errors.As(err, &re) || re.Message != "ERR nested" {
+		t.Errorf("nested error should be returned, got %v", err)
+	}
+
+	if err := readRedisReply(r); err != errNotCorrectRedisReply {
+		t.Errorf("unknown reply should fail, got %v", err)
+	}
+}
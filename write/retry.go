@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+)
+
+// RetryPolicy options are used by DB and Elasticsearch writers and define how failed transactions (or bulk requests)
+// are retried.
+//
+// MaxAttempts is the maximal number of attempts including the first one, transactions are not retried when it's lower
+// than two. Backoff returns the delay before the retry with the number counted from one, there is no delay when it's
+// not set (see ExponentialBackoff). Transient decides which errors are worth retrying, the IsTransient function is
+// used when it's not set.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     func(retry int) time.Duration
+	Transient   func(err error) bool
+}
+
+// ExponentialBackoff creates a backoff function that doubles the delay with each retry, starting with the base delay
+// and never exceeding the max delay.
+func ExponentialBackoff(base, max time.Duration) func(retry int) time.Duration {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+
+		if d > max {
+			return max
+		}
+
+		return d
+	}
+}
+
+// IsTransient returns true for dropped connections (driver.ErrBadConn) and for errors providing the SQLSTATE code
+// (by the SQLState() string function, such as PostgreSQL drivers do) of a serialization failure (40001), a deadlock
+// (40P01) or a connection exception (08xxx).
+func IsTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var s interface{ SQLState() string }
+	if !errors.As(err, &s) {
+		return false
+	}
+
+	code := s.SQLState()
+	return code == "40001" || code == "40P01" || strings.HasPrefix(code, "08")
+}
+
+// retry returns true when the failed attempt with the number counted from one should be retried.
+func (p RetryPolicy) retry(attempt int, err error) bool {
+	if err == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+
+	if p.Transient == nil {
+		return IsTransient(err)
+	}
+
+	return p.Transient(err)
+}
+
+// wait sleeps before the retry.
+func (p RetryPolicy) wait(retry int) {
+	if p.Backoff != nil {
+		time.Sleep(p.Backoff(retry))
+	}
+}
+
+// do calls the function until it succeeds or the error shouldn't be retried.
+func (p RetryPolicy) do(fn func() error) error {
+	err := fn()
+	for attempt := 1; p.retry(attempt, err); attempt++ {
+		p.wait(attempt)
+		err = fn()
+	}
+
+	return err
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sqlStateError imitates errors of PostgreSQL drivers.
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "SQLSTATE " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+var errDeadlock = sqlStateError("40P01")
+
+func TestIsTransient(t *testing.T) {
+	transient := []error{driver.ErrBadConn, errDeadlock, sqlStateError("40001"), sqlStateError("08006"),
+		fmt.Errorf("block failed: %w", errDeadlock)}
+	for _, err := range transient {
+		if !IsTransient(err) {
+			t.Errorf("%v should be transient", err)
+		}
+	}
+
+	permanent := []error{errors.New("syntax error"), sqlStateError("23505"), nil}
+	for _, err := range permanent {
+		if IsTransient(err) {
+			t.Errorf("%v should not be transient", err)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond,
+		50 * time.Millisecond, 50 * time.Millisecond}
+
+	for i, e := range expected {
+		if d := b(i + 1); d != e {
+			t.Errorf("retry %d: %v expected, got %v", i+1, e, d)
+		}
+	}
+}
+
+func TestDBWriter_Retry_Exec(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failTimes("'Lenk'", 2, errDeadlock)
+
+	w := NewDBWriter(db, &Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	err := w.WriteArtist(batchArtist)
+	if err != nil {
+		t.Error(err)
+	}
+
+	statements := f.recorded()
+	if strings.Count(strings.Join(statements, "\n"), "BEGIN") != 3 || statements[len(statements)-1] != "COMMIT" {
+		t.Errorf("the transaction should be retried twice and committed, got %v", statements)
+	}
+
+	if statements[4] != "ROLLBACK" || statements[5] != "BEGIN" || statements[6] != statements[1] {
+		t.Errorf("the transaction should be rolled back and replayed from the start, got %v", statements)
+	}
+}
+
+func TestDBWriter_Retry_Commit(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failTimes("COMMIT", 1, sqlStateError("40001"))
+
+	w := NewDBWriter(db, &Options{Retry: RetryPolicy{MaxAttempts: 2}})
+	err := w.WriteLabel(model.Label{ID: "1", Name: "Planet E"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "BEGIN,INSERT,COMMIT,BEGIN,INSERT,COMMIT"
+	if got := statementKinds(f.recorded()); got != expected {
+		t.Errorf("%s expected, got %s", expected, got)
+	}
+}
+
+func TestDBWriter_Retry_Permanent(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failTimes("'Lenk'", 1, errors.New("syntax error"))
+
+	w := NewDBWriter(db, &Options{Retry: RetryPolicy{MaxAttempts: 3}})
+	err := w.WriteArtist(batchArtist)
+	if err == nil || err.Error() != "syntax error" {
+		t.Errorf("syntax error expected, got %v", err)
+	}
+
+	expected := "BEGIN,INSERT,INSERT,INSERT,ROLLBACK"
+	if got := statementKinds(f.recorded()); got != expected {
+		t.Errorf("%s expected, got %s", expected, got)
+	}
+}
+
+func TestDBWriter_Retry_Exhausted(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+	f.fail = failTimes("BEGIN", 5, errDeadlock)
+
+	retries := 0
+	w := NewDBWriter(db, &Options{Retry: RetryPolicy{
+		MaxAttempts: 3,
+		Backoff: func(retry int) time.Duration {
+			retries = retry
+			return time.Millisecond
+		},
+		Transient: func(err error) bool { return err == errDeadlock },
+	}})
+
+	err := w.WriteArtist(batchArtist)
+	if err != errDeadlock {
+		t.Errorf("deadlock error expected, got %v", err)
+	}
+
+	if retries != 2 || len(f.recorded()) != 3 {
+		t.Errorf("two retries expected, got %d and statements %v", retries, f.recorded())
+	}
+}
+
+func TestDBWriter_Retry_CopyFrom(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	calls := 0
+	w := NewDBWriter(db, &Options{
+		Retry: RetryPolicy{MaxAttempts: 2},
+		CopyFrom: func(_ *sql.Tx, _ string, _ []string, _ io.Reader) error {
+			calls++
+			if calls == 1 {
+				return driver.ErrBadConn
+			}
+
+			return nil
+		},
+	})
+
+	err := w.WriteLabel(model.Label{ID: "1"})
+	if err != nil || calls != 2 {
+		t.Errorf("the block should be copied again, got %v after %d calls", err, calls)
+	}
+}
+
+// statementKinds returns the first words of statements joined by a comma.
+func statementKinds(statements []string) string {
+	kinds := make([]string, 0, len(statements))
+	for _, s := range statements {
+		kinds = append(kinds, strings.Fields(s)[0])
+	}
+
+	return strings.Join(kinds, ",")
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"os"
+)
+
+// Rotation options are used by the rotating writer only and define when the output is split into a new chunk.
+//
+// A new chunk is started as soon as the current one contains the number of records, the number of bytes or
+// the number of blocks, whichever comes first. Zero values are not limited. The records limit is exact as written
+// slices are split, the bytes limit is checked after each write, thus chunks are slightly bigger than the limit.
+type Rotation struct {
+	Records int
+	Bytes   int
+	Blocks  int
+}
+
+// full returns true when the chunk with the number of records, bytes and blocks should be finished.
+func (r Rotation) full(records, bytes, blocks int) bool {
+	return (r.Records > 0 && records >= r.Records) || (r.Bytes > 0 && bytes >= r.Bytes) ||
+		(r.Blocks > 0 && blocks >= r.Blocks)
+}
+
+// FileTemplate returns the function creating files of chunks named by the template with the chunk number,
+// such as releases-%05d.json. Chunks are numbered from zero.
+func FileTemplate(template string) func(chunk int) (io.Writer, error) {
+	return func(chunk int) (io.Writer, error) {
+		return os.Create(fmt.Sprintf(template, chunk))
+	}
+}
+
+type rotatingWriter struct {
+	o         Options
+	open      func(chunk int) (io.Writer, error)
+	newWriter func(output io.Writer, options *Options) Writer
+	chunk     int
+	output    *countingWriter
+	w         Writer
+	records   int
+	blocks    int
+	err       error
+}
+
+// NewRotatingWriter creates a writer that splits the output into chunks according to Rotation options, such as one
+// JSON file per 100 000 records. Each chunk is written into the output opened by the open function (see FileTemplate)
+// by its own writer created by the newWriter function, such as NewJSONWriter or NewSQLWriter.
+//
+// Chunks are self-contained, the chunk writer is opened before the first record and finished after the last one
+// (see Opener and Finisher interfaces), so each JSON chunk is a complete array and each SQL chunk contains complete
+// transactions together with PreLoad and PostLoad commands. Outputs implementing the io.Closer interface are closed
+// when the chunk is finished. The chunk is created when the first record is written, there are no empty chunks.
+func NewRotatingWriter(open func(chunk int) (io.Writer, error), newWriter func(io.Writer, *Options) Writer,
+	options *Options) Writer {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	return &rotatingWriter{
+		o:         *options,
+		open:      open,
+		newWriter: newWriter,
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (r *rotatingWriter) Options() Options {
+	return r.o
+}
+
+// WriteArtist writes an artist into the current chunk.
+func (r *rotatingWriter) WriteArtist(artist model.Artist) error {
+	return r.WriteArtists([]model.Artist{artist})
+}
+
+// WriteArtists writes a slice of artists, the slice is split into more chunks when it exceeds the records limit.
+func (r *rotatingWriter) WriteArtists(artists []model.Artist) error {
+	for len(artists) > 0 && r.next() {
+		n := r.split(len(artists))
+		r.write(n, r.w.WriteArtists(artists[:n]))
+		artists = artists[n:]
+	}
+
+	return r.err
+}
+
+// WriteLabel writes a label into the current chunk.
+func (r *rotatingWriter) WriteLabel(label model.Label) error {
+	return r.WriteLabels([]model.Label{label})
+}
+
+// WriteLabels writes a slice of labels, the slice is split into more chunks when it exceeds the records limit.
+func (r *rotatingWriter) WriteLabels(labels []model.Label) error {
+	for len(labels) > 0 && r.next() {
+		n := r.split(len(labels))
+		r.write(n, r.w.WriteLabels(labels[:n]))
+		labels = labels[n:]
+	}
+
+	return r.err
+}
+
+// WriteMaster writes a master into the current chunk.
+func (r *rotatingWriter) WriteMaster(master model.Master) error {
+	return r.WriteMasters([]model.Master{master})
+}
+
+// WriteMasters writes a slice of masters, the slice is split into more chunks when it exceeds the records limit.
+func (r *rotatingWriter) WriteMasters(masters []model.Master) error {
+	for len(masters) > 0 && r.next() {
+		n := r.split(len(masters))
+		r.write(n, r.w.WriteMasters(masters[:n]))
+		masters = masters[n:]
+	}
+
+	return r.err
+}
+
+// WriteRelease writes a release into the current chunk.
+func (r *rotatingWriter) WriteRelease(release model.Release) error {
+	return r.WriteReleases([]model.Release{release})
+}
+
+// WriteReleases writes a slice of releases, the slice is split into more chunks when it exceeds the records
+// limit.
+func (r *rotatingWriter) WriteReleases(releases []model.Release) error {
+	for len(releases) > 0 && r.next() {
+		n := r.split(len(releases))
+		r.write(n, r.w.WriteReleases(releases[:n]))
+		releases = releases[n:]
+	}
+
+	return r.err
+}
+
+// Flush flushes the chunk writer at the end of the block and finishes the chunk when the blocks limit is reached.
+func (r *rotatingWriter) Flush() error {
+	if r.err != nil || r.w == nil {
+		return r.err
+	}
+
+	r.err = Flush(r.w)
+	r.blocks++
+	if r.err == nil && r.o.Rotation.full(r.records, r.output.n, r.blocks) {
+		r.err = r.finish(nil)
+	}
+
+	return r.err
+}
+
+// Finish finishes the current chunk with the run error.
+func (r *rotatingWriter) Finish(err error) error {
+	if r.w == nil {
+		return r.err
+	}
+
+	fErr := r.finish(err)
+	if r.err == nil {
+		r.err = fErr
+	}
+
+	return r.err
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// next starts the new chunk when there is no current one and returns true when records can be written.
+func (r *rotatingWriter) next() bool {
+	if r.err != nil {
+		return false
+	}
+
+	if r.w != nil {
+		return true
+	}
+
+	var output io.Writer
+	output, r.err = r.open(r.chunk)
+	if r.err != nil {
+		return false
+	}
+
+	r.chunk++
+	r.output = &countingWriter{w: output}
+	r.w = r.newWriter(r.output, &r.o)
+	r.records = 0
+	r.blocks = 0
+	r.err = Open(r.w)
+
+	return r.err == nil
+}
+
+// split returns the number of records written into the current chunk to respect the records limit.
+func (r *rotatingWriter) split(n int) int {
+	if r.o.Rotation.Records > 0 && r.records+n > r.o.Rotation.Records {
+		return r.o.Rotation.Records - r.records
+	}
+
+	return n
+}
+
+// write counts written records and finishes the chunk when the records or bytes limit is reached.
+func (r *rotatingWriter) write(n int, err error) {
+	r.err = err
+	r.records += n
+	if r.err == nil && r.o.Rotation.full(r.records, r.output.n, 0) {
+		r.err = r.finish(nil)
+	}
+}
+
+// finish finishes the chunk writer and closes its output.
+func (r *rotatingWriter) finish(err error) error {
+	err = Finish(r.w, err)
+	if cl, ok := r.output.w.(io.Closer); ok {
+		if cErr := cl.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+
+	r.w = nil
+	r.output = nil
+
+	return err
+}
+
+// countingWriter counts bytes written into the output, flushing is forwarded to the output.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+
+	return n, err
+}
+
+func (c *countingWriter) Flush() error {
+	return flushOutput(c.w)
+}
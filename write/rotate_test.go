@@ -0,0 +1,169 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// closingBuilder records whether the output has been closed.
+type closingBuilder struct {
+	strings.Builder
+	closed bool
+}
+
+func (c *closingBuilder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func newChunkBuffers() (*[]*closingBuilder, func(int) (io.Writer, error)) {
+	var chunks []*closingBuilder
+	return &chunks, func(chunk int) (io.Writer, error) {
+		if chunk != len(chunks) {
+			return nil, fmt.Errorf("chunk %d opened instead of %d", chunk, len(chunks))
+		}
+
+		b := &closingBuilder{}
+		chunks = append(chunks, b)
+		return b, nil
+	}
+}
+
+func numberedReleases(n int) []model.Release {
+	rs := make([]model.Release, 0, n)
+	for i := 0; i < n; i++ {
+		r := releases[0]
+		r.ID = fmt.Sprint(i + 1)
+		rs = append(rs, r)
+	}
+
+	return rs
+}
+
+func TestRotatingWriter_Records(t *testing.T) {
+	chunks, open := newChunkBuffers()
+	w := NewRotatingWriter(open, NewJSONWriter, &Options{Rotation: Rotation{Records: 2}})
+
+	_ = Open(w)
+	_ = w.WriteReleases(numberedReleases(3))
+	_ = Flush(w)
+	_ = w.WriteReleases(numberedReleases(2))
+	_ = Flush(w)
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(*chunks) != 3 {
+		t.Fatalf("3 chunks should be written, got %d", len(*chunks))
+	}
+
+	for i, expected := range []int{2, 2, 1} {
+		c := (*chunks)[i]
+		var rs []model.Release
+		if err := json.Unmarshal([]byte(c.String()), &rs); err != nil {
+			t.Errorf("chunk %d should be a valid JSON array: %v", i, err)
+		}
+
+		if len(rs) != expected || !c.closed {
+			t.Errorf("chunk %d should contain %d releases and it should be closed, got %d", i, expected, len(rs))
+		}
+	}
+}
+
+func TestRotatingWriter_Blocks(t *testing.T) {
+	chunks, open := newChunkBuffers()
+	o := &Options{Rotation: Rotation{Blocks: 2}, PreLoad: []string{"SET search_path TO discogs"}}
+	w := NewRotatingWriter(open, NewSQLWriter, o)
+
+	_ = Open(w)
+	for i := 0; i < 3; i++ {
+		_ = w.WriteMasters(masters)
+		_ = Flush(w)
+	}
+
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(*chunks) != 2 {
+		t.Fatalf("2 chunks should be written, got %d", len(*chunks))
+	}
+
+	for i, expected := range []int{2, 1} {
+		c := (*chunks)[i].String()
+		if !strings.HasPrefix(c, "SET search_path TO discogs;\nBEGIN;\n") || !strings.HasSuffix(c, "COMMIT;\n") {
+			t.Errorf("chunk %d should be self-contained: %s", i, c)
+		}
+
+		if strings.Count(c, "BEGIN;") != expected || strings.Count(c, "COMMIT;") != expected {
+			t.Errorf("chunk %d should contain %d transactions: %s", i, expected, c)
+		}
+	}
+}
+
+func TestRotatingWriter_Bytes(t *testing.T) {
+	chunks, open := newChunkBuffers()
+	w := NewRotatingWriter(open, NewJSONWriter, &Options{Rotation: Rotation{Bytes: 1}})
+
+	for _, r := range numberedReleases(3) {
+		_ = w.WriteRelease(r)
+	}
+
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(*chunks) != 3 {
+		t.Errorf("each release should be in its own chunk, got %d chunks", len(*chunks))
+	}
+}
+
+func TestRotatingWriter_FileTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discogs-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w := NewRotatingWriter(FileTemplate(filepath.Join(dir, "labels-%05d.json")), NewJSONWriter,
+		&Options{Rotation: Rotation{Records: 1}})
+	_ = w.WriteLabels(labels[:1])
+	_ = w.WriteLabels(labels[:1])
+	err = Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 || filepath.Base(files[0]) != "labels-00000.json" ||
+		filepath.Base(files[1]) != "labels-00001.json" {
+		t.Errorf("chunk files should be named by the template, got %v", files)
+	}
+}
+
+func TestRotatingWriter_Error(t *testing.T) {
+	openErr := errors.New("open failed")
+	w := NewRotatingWriter(func(int) (io.Writer, error) {
+		return nil, openErr
+	}, NewJSONWriter, nil)
+
+	err := w.WriteArtists(artists)
+	if err != openErr {
+		t.Errorf("there should be an error %v instead of %v", openErr, err)
+	}
+}
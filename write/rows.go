@@ -0,0 +1,205 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import "github.com/lukasaron/data-discogs/model"
+
+// row is a single table row of flattened Discogs entity. Values are in the order of table columns and each value is
+// either a string or a slice of strings for array columns.
+type row struct {
+	table  table
+	values []interface{}
+}
+
+// keyValue returns the value of the key column of main entity tables, the key is always the first column.
+func (r row) keyValue() string {
+	if r.table.key == "" || len(r.values) == 0 {
+		return ""
+	}
+
+	s, _ := r.values[0].(string)
+	return s
+}
+
+// ----------------------------------------------- ARTIST -----------------------------------------------
+
+func artistRows(a model.Artist, o Options) []row {
+	a = o.excludeArtist(a)
+	rows := []row{{
+		table:  artistsTable,
+		values: []interface{}{a.ID, a.Name, a.RealName, a.Profile, a.DataQuality, a.NameVariations, a.Urls},
+	}}
+
+	rows = append(rows, imageRows(a.ID, "", "", "", a.Images, o)...)
+	for _, al := range a.Aliases {
+		rows = append(rows, row{
+			table:  artistAliasesTable,
+			values: []interface{}{a.ID, al.ID, al.Name},
+		})
+	}
+
+	for _, m := range a.Members {
+		rows = append(rows, row{
+			table:  artistMembersTable,
+			values: []interface{}{a.ID, m.ID, m.Name},
+		})
+	}
+
+	return rows
+}
+
+// ----------------------------------------------- LABEL -----------------------------------------------
+
+func labelRows(l model.Label, o Options) []row {
+	l = o.excludeLabel(l)
+	rows := []row{{
+		table:  labelsTable,
+		values: []interface{}{l.ID, l.Name, l.ContactInfo, l.Profile, l.DataQuality, l.Urls},
+	}}
+
+	for _, sl := range l.SubLabels {
+		rows = append(rows, labelLabelRow(l.ID, "false", sl))
+	}
+
+	rows = append(rows, imageRows("", l.ID, "", "", l.Images, o)...)
+	if l.ParentLabel != nil {
+		rows = append(rows, labelLabelRow(l.ID, "true", *l.ParentLabel))
+	}
+
+	return rows
+}
+
+func labelLabelRow(labelID, parent string, ll model.LabelLabel) row {
+	return row{
+		table:  labelLabelsTable,
+		values: []interface{}{labelID, ll.ID, ll.Name, parent},
+	}
+}
+
+// ----------------------------------------------- MASTER -----------------------------------------------
+
+func masterRows(m model.Master, o Options) []row {
+	m = o.excludeMaster(m)
+	rows := []row{{
+		table:  mastersTable,
+		values: []interface{}{m.ID, m.MainRelease, m.Genres, m.Styles, m.Year, m.Title, m.DataQuality},
+	}}
+
+	rows = append(rows, releaseArtistRows(m.ID, "", "false", m.Artists)...)
+	rows = append(rows, imageRows("", "", m.ID, "", m.Images, o)...)
+	rows = append(rows, videoRows(m.ID, "", m.Videos)...)
+
+	return rows
+}
+
+// ----------------------------------------------- RELEASE -----------------------------------------------
+
+func releaseRows(r model.Release, o Options) []row {
+	r = o.excludeRelease(r)
+	rows := []row{{
+		table: releasesTable,
+		values: []interface{}{r.ID, r.Status, r.Title, r.Genres, r.Styles, r.Country, r.Released, r.Notes,
+			r.DataQuality, r.MasterID, r.MainRelease},
+	}}
+
+	rows = append(rows, imageRows("", "", "", r.ID, r.Images, o)...)
+	rows = append(rows, releaseArtistRows("", r.ID, "false", r.Artists)...)
+	rows = append(rows, releaseArtistRows("", r.ID, "true", r.ExtraArtists)...)
+	for _, f := range r.Formats {
+		rows = append(rows, row{
+			table:  releaseFormatsTable,
+			values: []interface{}{r.ID, f.Name, f.Quantity, f.Text, f.Descriptions},
+		})
+	}
+
+	for _, t := range r.TrackList {
+		rows = append(rows, row{
+			table:  releaseTracksTable,
+			values: []interface{}{r.ID, t.Position, t.Title, t.Duration},
+		})
+	}
+
+	for _, i := range r.Identifiers {
+		rows = append(rows, row{
+			table:  releaseIdentifiersTable,
+			values: []interface{}{r.ID, i.Description, i.Type, i.Value},
+		})
+	}
+
+	for _, l := range r.Labels {
+		rows = append(rows, row{
+			table:  releaseLabelsTable,
+			values: []interface{}{r.ID, l.ID, l.Name, l.Category},
+		})
+	}
+
+	for _, c := range r.Companies {
+		rows = append(rows, row{
+			table:  releaseCompaniesTable,
+			values: []interface{}{r.ID, c.ID, c.Name, c.Category, c.EntityType, c.EntityTypeName, c.ResourceURL},
+		})
+	}
+
+	rows = append(rows, videoRows("", r.ID, r.Videos)...)
+
+	return rows
+}
+
+func releaseArtistRows(masterID, releaseID, extra string, ras []model.ReleaseArtist) []row {
+	rows := make([]row, 0, len(ras))
+	for _, ra := range ras {
+		rows = append(rows, row{
+			table:  releaseArtistsTable,
+			values: []interface{}{masterID, releaseID, ra.ID, ra.Name, extra, ra.Join, ra.Anv, ra.Role, ra.Tracks},
+		})
+	}
+
+	return rows
+}
+
+// ----------------------------------------------- SHARED -----------------------------------------------
+
+func imageRows(artistID, labelID, masterID, releaseID string, imgs []model.Image, o Options) []row {
+	if o.excludes(Images) {
+		return nil
+	}
+
+	rows := make([]row, 0, len(imgs))
+	for _, img := range imgs {
+		rows = append(rows, row{
+			table: imagesTable,
+			values: []interface{}{artistID, labelID, masterID, releaseID, img.Height, img.Width, img.Type, img.URI,
+				img.URI150},
+		})
+	}
+
+	return rows
+}
+
+func videoRows(masterID, releaseID string, vs []model.Video) []row {
+	rows := make([]row, 0, len(vs))
+	for _, v := range vs {
+		rows = append(rows, row{
+			table:  videosTable,
+			values: []interface{}{masterID, releaseID, v.Duration, v.Embed, v.Src, v.Title, v.Description},
+		})
+	}
+
+	return rows
+}
+
+// groupRows splits rows by tables, the order of tables is given by their first occurrence.
+func groupRows(rows []row) (tables []table, groups map[string][]row) {
+	groups = make(map[string][]row)
+	for _, r := range rows {
+		if _, ok := groups[r.table.name]; !ok {
+			tables = append(tables, r.table)
+		}
+
+		groups[r.table.name] = append(groups[r.table.name], r)
+	}
+
+	return tables, groups
+}
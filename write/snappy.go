@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	snappyHashBits  = 14
+	snappyMaxOffset = 1 << 16
+	snappyMinMatch  = 4
+)
+
+// snappyEncode compresses the data into the Snappy block format (without the framing), as Parquet pages require.
+// Matches are searched by a hash table of 4 byte sequences, which is the same approach the reference implementation
+// uses, the compression ratio is slightly lower as the matching is simpler.
+func snappyEncode(src []byte) []byte {
+	b := &bytes.Buffer{}
+	writeUvarint(b, uint64(len(src)))
+
+	var table [1 << snappyHashBits]int
+	literal := 0
+	for i := 0; i+snappyMinMatch <= len(src); {
+		h := snappyHash(binary.LittleEndian.Uint32(src[i:]))
+		candidate := table[h] - 1
+		table[h] = i + 1
+
+		if candidate < 0 || i-candidate >= snappyMaxOffset ||
+			binary.LittleEndian.Uint32(src[candidate:]) != binary.LittleEndian.Uint32(src[i:]) {
+			i++
+			continue
+		}
+
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+
+		snappyLiteral(b, src[literal:i])
+		snappyCopy(b, i-candidate, length)
+		i += length
+		literal = i
+	}
+
+	snappyLiteral(b, src[literal:])
+	return b.Bytes()
+}
+
+func snappyHash(x uint32) uint32 {
+	return (x * 0x1e35a7bd) >> (32 - snappyHashBits)
+}
+
+func snappyLiteral(b *bytes.Buffer, lit []byte) {
+	if len(lit) == 0 {
+		return
+	}
+
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		b.WriteByte(byte(n) << 2)
+	case n < 1<<8:
+		b.WriteByte(60 << 2)
+		b.WriteByte(byte(n))
+	case n < 1<<16:
+		b.WriteByte(61 << 2)
+		b.WriteByte(byte(n))
+		b.WriteByte(byte(n >> 8))
+	case n < 1<<24:
+		b.WriteByte(62 << 2)
+		b.WriteByte(byte(n))
+		b.WriteByte(byte(n >> 8))
+		b.WriteByte(byte(n >> 16))
+	default:
+		b.WriteByte(63 << 2)
+		b.WriteByte(byte(n))
+		b.WriteByte(byte(n >> 8))
+		b.WriteByte(byte(n >> 16))
+		b.WriteByte(byte(n >> 24))
+	}
+
+	b.Write(lit)
+}
+
+// snappyCopy writes the copy with 2 byte offset, longer matches are split into more copies of at most 64 bytes.
+func snappyCopy(b *bytes.Buffer, offset, length int) {
+	for length > 0 {
+		n := length
+		if n > 64 {
+			n = 64
+		}
+
+		b.WriteByte(byte(n-1)<<2 | 2)
+		b.WriteByte(byte(offset))
+		b.WriteByte(byte(offset >> 8))
+		length -= n
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSnappyEncode(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := map[string][]byte{
+		"empty":      nil,
+		"short":      []byte("abc"),
+		"repetitive": []byte(strings.Repeat("Electronic Techno House ", 5000)),
+		"runs":       bytes.Repeat([]byte{'a'}, 70000),
+		"random":     random,
+	}
+
+	for name, data := range tests {
+		encoded := snappyEncode(data)
+		decoded, err := snappyDecode(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(decoded, data) {
+			t.Errorf("%s: decoded data differ from the original", name)
+		}
+	}
+
+	if encoded := snappyEncode(tests["repetitive"]); len(encoded) > len(tests["repetitive"])/10 {
+		t.Errorf("repetitive data should be compressed, got %d bytes", len(encoded))
+	}
+}
+
+// snappyDecode decodes the Snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 {
+		return nil, errors.New("snappy: wrong length")
+	}
+
+	dst := make([]byte, 0, length)
+	for i := n; i < len(src); {
+		tag := src[i]
+		switch tag & 3 {
+		case 0:
+			l := int(tag >> 2)
+			i++
+			if l >= 60 {
+				extra := l - 59
+				l = 0
+				for j := 0; j < extra; j++ {
+					l |= int(src[i+j]) << (8 * j)
+				}
+				i += extra
+			}
+
+			l++
+			dst = append(dst, src[i:i+l]...)
+			i += l
+		case 1:
+			l := int(tag>>2&7) + 4
+			offset := int(tag>>5)<<8 | int(src[i+1])
+			dst = snappyAppendCopy(dst, offset, l)
+			i += 2
+		case 2:
+			l := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint16(src[i+1:]))
+			dst = snappyAppendCopy(dst, offset, l)
+			i += 3
+		default:
+			l := int(tag>>2) + 1
+			offset := int(binary.LittleEndian.Uint32(src[i+1:]))
+			dst = snappyAppendCopy(dst, offset, l)
+			i += 5
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, errors.New("snappy: wrong decoded length")
+	}
+
+	return dst, nil
+}
+
+func snappyAppendCopy(dst []byte, offset, length int) []byte {
+	start := len(dst) - offset
+	for i := 0; i < length; i++ {
+		dst = append(dst, dst[start+i])
+	}
+
+	return dst
+}
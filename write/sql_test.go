@@ -5,6 +5,8 @@
 package write
 
 import (
+	"errors"
+	"github.com/lukasaron/data-discogs/model"
 	"strings"
 	"testing"
 )
@@ -138,6 +140,110 @@ func TestSQLWriter_WriteReleases(t *testing.T) {
 	}
 }
 
+func TestSQLWriter_WriteArtist_Upsert(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert})
+
+	err := s.WriteArtist(artists[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := b.String()
+	if expectedUpsertArtist != got {
+		t.Error("sql output differs from what it's expected")
+	}
+}
+
+func TestSQLWriter_WriteMasters_Upsert_MySQL(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert, Dialect: MySQL, ExcludeImages: true})
+
+	err := s.WriteMasters(masters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	got := b.String()
+	if expectedUpsertMaster != got {
+		t.Error("sql output differs from what it's expected")
+	}
+}
+
+func TestSQLWriter_WriteArtist_Upsert_MySQL(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{Mode: Upsert, Dialect: MySQL, Exclude: Images | Urls})
+
+	err := s.WriteArtist(model.Artist{ID: "2", Name: "Mr. James Barth & A.D.", NameVariations: []string{"Mr Barth's"}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "BEGIN;\nDELETE FROM artist_aliases WHERE artist_id = '2';\n" +
+		"DELETE FROM artist_members WHERE artist_id = '2';\n" +
+		"INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES " +
+		"('2', 'Mr. James Barth & A.D.', '', '', '', '[\"Mr Barth''s\"]', '[]') ON DUPLICATE KEY UPDATE " +
+		"name = VALUES(name), real_name = VALUES(real_name), profile = VALUES(profile), " +
+		"data_quality = VALUES(data_quality), name_variations = VALUES(name_variations), urls = VALUES(urls);\n" +
+		"COMMIT;\n"
+	if got := b.String(); got != expected {
+		t.Errorf("sql output differs from what it's expected: %s", got)
+	}
+
+	if cmd := TableCommands(&Options{Dialect: MySQL})[0]; !strings.Contains(cmd, "name_variations JSON, urls JSON") {
+		t.Errorf("arrays should be stored into JSON columns: %s", cmd)
+	}
+}
+
+func TestSQLWriter_Lifecycle(t *testing.T) {
+	b := &strings.Builder{}
+	s := NewSQLWriter(b, &Options{
+		PreLoad:  []string{"SET synchronous_commit TO OFF;"},
+		PostLoad: []string{"CREATE INDEX artists_name ON artists(name)"},
+	})
+
+	_ = Open(s)
+	_ = s.WriteArtists(artists)
+	err := Finish(s, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "SET synchronous_commit TO OFF;\n" + expectedArtist + "CREATE INDEX artists_name ON artists(name);\n"
+	if got := b.String(); got != expected {
+		t.Error("sql output differs from what it's expected")
+	}
+
+	b.Reset()
+	err = Finish(s, errors.New("decoding failed"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if b.Len() != 0 {
+		t.Error("post load commands should be omitted when the run fails")
+	}
+}
+
+func TestSQLWriter_WriteReleases_OutputError(t *testing.T) {
+	outputErr := errors.New("disk full")
+	s := NewSQLWriter(failingWriter{err: outputErr}, &Options{PostLoad: []string{"ANALYZE"}})
+
+	err := s.WriteReleases(releases)
+	if err != outputErr {
+		t.Errorf("output error expected, got %v", err)
+	}
+
+	err = s.WriteArtists(artists)
+	if err != outputErr {
+		t.Errorf("output error should be kept for following writes, got %v", err)
+	}
+
+	if Flush(s) != outputErr || Finish(s, nil) != outputErr {
+		t.Error("output error should be returned by Flush and Finish")
+	}
+}
+
 // ------------------------------------------------------- DATA -------------------------------------------------------
 
 var expectedArtist = `BEGIN;
@@ -209,3 +315,28 @@ INSERT INTO videos (master_id, release_id, duration, embed, src, title, descript
 INSERT INTO videos (master_id, release_id, duration, embed, src, title, description) VALUES ('', '2', '290', 'true', 'https://www.youtube.com/watch?v=x_Os7b-iWKs', 'Mr. James Barth & A.D. - Yeah Kid!', 'Mr. James Barth & A.D. - Yeah Kid!');
 COMMIT;
 `
+
+var expectedUpsertArtist = `BEGIN;
+DELETE FROM artist_aliases WHERE artist_id = '2';
+DELETE FROM artist_members WHERE artist_id = '2';
+DELETE FROM images WHERE artist_id = '2';
+INSERT INTO artists (artist_id, name, real_name, profile, data_quality, name_variations, urls) VALUES ('2', 'Mr. James Barth & A.D.', 'Cari Lekebusch & Alexi Delano', '', 'Correct', ARRAY['Mr Barth & A.D.','MR JAMES BARTH & A. D.','Mr. Barth & A.D.','Mr. James Barth & A. D.'], ARRAY['']) ON CONFLICT (artist_id) DO UPDATE SET name = EXCLUDED.name, real_name = EXCLUDED.real_name, profile = EXCLUDED.profile, data_quality = EXCLUDED.data_quality, name_variations = EXCLUDED.name_variations, urls = EXCLUDED.urls;
+INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('2', '2470', 'Puente Latino');
+INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('2', '19536', 'Yakari & Delano');
+INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('2', '103709', 'Crushed Insect & The Sick Puppy');
+INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('2', '384581', 'ADCL');
+INSERT INTO artist_aliases (artist_id, alias_id, name) VALUES ('2', '1779857', 'Alexi Delano & Cari Lekebusch');
+INSERT INTO artist_members (artist_id, member_id, name) VALUES ('2', '26', 'Alexi Delano');
+INSERT INTO artist_members (artist_id, member_id, name) VALUES ('2', '27', 'Cari Lekebusch');
+COMMIT;
+`
+var expectedUpsertMaster = `BEGIN;
+DELETE FROM release_artists WHERE master_id = '18512';
+DELETE FROM videos WHERE master_id = '18512';
+INSERT INTO masters (master_id, main_release, genres, styles, year, title, data_quality) VALUES ('18512', '33699', '["Electronic"]', '["Tribal","Techno"]', '2002', 'Psyche EP', 'Correct') ON DUPLICATE KEY UPDATE main_release = VALUES(main_release), genres = VALUES(genres), styles = VALUES(styles), year = VALUES(year), title = VALUES(title), data_quality = VALUES(data_quality);
+INSERT INTO release_artists (master_id, release_id, release_artist_id, name, extra, joiner, anv, role, tracks) VALUES ('18512', '', '212070', 'Samuel L Session', 'false', '', '', '', '');
+INSERT INTO videos (master_id, release_id, duration, embed, src, title, description) VALUES ('18512', '', '118', 'true', 'https://www.youtube.com/watch?v=QYf4j0Pd2FU', 'Samuel L. Session - Arrival', 'Samuel L. Session - Arrival');
+INSERT INTO videos (master_id, release_id, duration, embed, src, title, description) VALUES ('18512', '', '376', 'true', 'https://www.youtube.com/watch?v=c_AfLqTdncI', 'Samuel L. Session - Psyche Part 1', 'Samuel L. Session - Psyche Part 1');
+INSERT INTO videos (master_id, release_id, duration, embed, src, title, description) VALUES ('18512', '', '419', 'true', 'https://www.youtube.com/watch?v=0nxvR8Zl9wY', 'Samuel L. Session - Psyche Part 2', 'Samuel L. Session - Psyche Part 2');
+COMMIT;
+`
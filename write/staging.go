@@ -0,0 +1,186 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Staging options are used by DB writer only and enable full reloads without exposing half-loaded tables.
+//
+// When Suffix is set, all rows are written into staging tables named by the live table and the suffix, such as
+// releases_staging. Staging tables are created empty (any previous staging table is dropped) when the writer is
+// opened for the first time. After Runs successful runs (one run per dump file, the default is one) the Finish
+// function builds indexes of staging tables and swaps them in place of live tables. PostgreSQL and SQLite drop live
+// tables and rename staging tables within one transaction, MySQL renames all tables by one atomic RENAME TABLE
+// command. When a run fails, live tables stay untouched and the staging load starts over, the next run creates
+// staging tables empty again, so rows of the failed run are not loaded twice.
+//
+// All tables are swapped, so the staging load should contain all dump files (artists, labels, masters and releases)
+// as images, videos and release artists are shared among entities. Live tables have to exist, staging tables copy
+// their columns.
+type Staging struct {
+	Suffix string
+	Runs   int
+}
+
+// stageCommands returns commands creating empty staging tables. Document tables are created by their definition to
+// keep generated columns, other tables copy columns of live tables. Unique indexes are created in the Upsert mode as they
+// are needed during the load.
+func (o Options) stageCommands() []string {
+	var cmds []string
+	for _, t := range o.tables() {
+		s := o.tableName(t)
+		cmds = append(cmds, fmt.Sprintf("DROP TABLE IF EXISTS %s", s))
+		if o.Documents.Enabled {
+			cmds = append(cmds, o.createCommand(t, true))
+		} else {
+			cmds = append(cmds, fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s WHERE 1 = 0", s, o.liveName(t)))
+		}
+
+		if o.Mode == Upsert && t.key != "" {
+			cmds = append(cmds, o.indexCommand(t, t.key, true, true))
+		}
+	}
+
+	return cmds
+}
+
+// stagingIndexCommands returns commands building indexes of staging tables before they are swapped. SQLite is not
+// able to rename indexes, so its indexes are built within the swap transaction.
+func (o Options) stagingIndexCommands() []string {
+	if o.Dialect == SQLite {
+		return nil
+	}
+
+	var cmds []string
+	for _, t := range o.tables() {
+		for _, c := range t.indexes {
+			cmds = append(cmds, o.indexCommand(t, c, true, false))
+		}
+	}
+
+	return cmds
+}
+
+// swapCommands returns commands replacing live tables by staging tables. Commands are executed within one transaction
+// except the cleanup commands, which drop old tables after the atomic rename in MySQL.
+func (o Options) swapCommands() (swap, cleanup []string) {
+	if o.Dialect == MySQL {
+		renames := make([]string, 0, 2*len(o.tables()))
+		for _, t := range o.tables() {
+			live, old := o.liveName(t), o.qualify(o.localName(t, false)+"_old")
+			swap = append(swap, fmt.Sprintf("DROP TABLE IF EXISTS %s", old))
+			renames = append(renames, fmt.Sprintf("%s TO %s", live, old), fmt.Sprintf("%s TO %s", o.tableName(t), live))
+			cleanup = append(cleanup, fmt.Sprintf("DROP TABLE %s", old))
+		}
+
+		return append(swap, "RENAME TABLE "+strings.Join(renames, ", ")), cleanup
+	}
+
+	for _, t := range o.tables() {
+		swap = append(swap,
+			fmt.Sprintf("DROP TABLE %s", o.liveName(t)),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", o.tableName(t), o.localName(t, false)))
+
+		swap = append(swap, o.swapIndexCommands(t)...)
+	}
+
+	return swap, nil
+}
+
+// swapIndexCommands returns commands giving indexes of the swapped table their final names. PostgreSQL renames
+// indexes, SQLite drops the unique index needed during the load and builds all indexes.
+func (o Options) swapIndexCommands(t table) []string {
+	var cmds []string
+	if o.Mode == Upsert && t.key != "" {
+		from, to := o.qualify(o.indexName(t, t.key, true, true)), o.indexName(t, t.key, false, true)
+		if o.Dialect == SQLite {
+			cmds = append(cmds,
+				fmt.Sprintf("DROP INDEX %s", from),
+				o.indexCommand(t, t.key, false, true))
+		} else {
+			cmds = append(cmds, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", from, to))
+		}
+	}
+
+	for _, c := range t.indexes {
+		if o.Dialect == SQLite {
+			cmds = append(cmds, o.indexCommand(t, c, false, false))
+		} else {
+			cmds = append(cmds, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", o.qualify(o.indexName(t, c, true, false)),
+				o.indexName(t, c, false, false)))
+		}
+	}
+
+	return cmds
+}
+
+// ----------------------------------------------- DB WRITER -----------------------------------------------
+
+func (db *DBWriter) staging() bool {
+	return db.o.Staging.Suffix != ""
+}
+
+// stage creates staging tables before the first run.
+func (db *DBWriter) stage() error {
+	if !db.staging() || db.staged {
+		return nil
+	}
+
+	err := db.execute(db.o.stageCommands())
+	if err == nil {
+		db.staged = true
+	}
+
+	return err
+}
+
+// swap counts successful runs and swaps staging tables in place of live tables after the last run.
+func (db *DBWriter) swap() error {
+	if !db.staging() {
+		return nil
+	}
+
+	db.runs++
+	if db.runs < db.o.Staging.Runs {
+		return nil
+	}
+
+	err := db.execute(db.o.stagingIndexCommands())
+	if err != nil {
+		return err
+	}
+
+	swap, cleanup := db.o.swapCommands()
+	tx, err := db.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	for _, cmd := range swap {
+		_, err = tx.Exec(cmd)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	db.unstage()
+	return db.execute(cleanup)
+}
+
+// unstage makes the next run start the staging load over by creating empty staging tables.
+func (db *DBWriter) unstage() {
+	db.runs = 0
+	db.staged = false
+}
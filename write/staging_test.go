@@ -0,0 +1,174 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDBWriter_Staging(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{Staging: Staging{Suffix: "_staging"}})
+	_ = Open(w)
+	_ = w.WriteArtists(artists)
+	err := Finish(w, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	statements := strings.Join(f.recorded(), "\n")
+	expected := []string{
+		"DROP TABLE IF EXISTS artists_staging\nCREATE TABLE artists_staging AS SELECT * FROM artists WHERE 1 = 0\n",
+		"INSERT INTO artists_staging (artist_id",
+		"INSERT INTO artist_aliases_staging (artist_id",
+		"CREATE INDEX artists_staging_name ON artists_staging (name)\n",
+		"BEGIN\nDROP TABLE artists\nALTER TABLE artists_staging RENAME TO artists\n" +
+			"ALTER INDEX artists_staging_artist_id RENAME TO artists_artist_id\n",
+		"ALTER INDEX release_tracks_staging_release_id RENAME TO release_tracks_release_id\nCOMMIT",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(statements, e) {
+			t.Errorf("statements should contain %q", e)
+		}
+	}
+}
+
+func TestDBWriter_Staging_Failure(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{Staging: Staging{Suffix: "_new"}, PostLoad: []string{"ANALYZE"}})
+	_ = Open(w)
+
+	f.fail = failOn("ALTER TABLE releases_new RENAME")
+	err := Finish(w, nil)
+	if err == nil {
+		t.Error("failed swap should be returned")
+	}
+
+	statements := f.recorded()
+	if last := statements[len(statements)-1]; last != "ROLLBACK" {
+		t.Errorf("swap should be rolled back, got %s", last)
+	}
+
+	f.fail = nil
+	err = Finish(w, errors.New("decoding failed"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(f.recorded()) != len(statements) {
+		t.Error("nothing should be swapped when the run fails")
+	}
+}
+
+func TestDBWriter_Staging_Runs(t *testing.T) {
+	f, db := newFakeDB()
+	defer db.Close()
+
+	w := NewDBWriter(db, &Options{Staging: Staging{Suffix: "_staging", Runs: 2}})
+	_ = Open(w)
+	_ = w.WriteArtists(artists)
+	_ = Finish(w, nil)
+
+	_ = Open(w)
+	_ = w.WriteLabels(labels)
+	before := strings.Join(f.recorded(), "\n")
+	_ = Finish(w, nil)
+
+	if strings.Count(before, "CREATE TABLE artists_staging") != 1 {
+		t.Error("staging tables should be created only once")
+	}
+
+	if strings.Contains(before, "RENAME") {
+		t.Error("staging tables should not be swapped before the last run")
+	}
+
+	if !strings.Contains(strings.Join(f.recorded(), "\n"), "ALTER TABLE labels_staging RENAME TO labels") {
+		t.Error("staging tables should be swapped after the last run")
+	}
+}
+
+func TestDBWriter_Staging_Rerun(t *testing.T) {
+	o := &Options{Staging: Staging{Suffix: "_staging"}}
+	writers := []func(db *sql.DB) Writer{
+		func(db *sql.DB) Writer { return NewDBWriter(db, o) },
+		func(db *sql.DB) Writer { return NewConcurrentDBWriter(db, 2, o) },
+	}
+
+	for _, newWriter := range writers {
+		f, db := newFakeDB()
+		w := newWriter(db)
+
+		_ = Open(w)
+		_ = w.WriteArtists(artists)
+		_ = Finish(w, errors.New("decoding failed"))
+
+		_ = Open(w)
+		rerun := len(f.recorded())
+		_ = w.WriteArtists(artists)
+		err := Finish(w, nil)
+		if err != nil {
+			t.Error(err)
+		}
+
+		statements := strings.Join(f.recorded(), "\n")
+		if strings.Count(statements, "CREATE TABLE artists_staging") != 2 ||
+			!strings.Contains(strings.Join(f.recorded()[:rerun], "\n"), "DROP TABLE IF EXISTS artists_staging") {
+			t.Errorf("%T: staging tables should be created again after the failed run", w)
+		}
+
+		if !strings.Contains(statements, "ALTER TABLE artists_staging RENAME TO artists") {
+			t.Errorf("%T: staging tables should be swapped after the successful run", w)
+		}
+
+		_ = db.Close()
+	}
+}
+
+func TestOptions_SwapCommands_MySQL(t *testing.T) {
+	o := Options{Dialect: MySQL, Mode: Upsert, Staging: Staging{Suffix: "_staging"}}
+
+	stage := strings.Join(o.stageCommands(), "\n")
+	if !strings.Contains(stage, "CREATE UNIQUE INDEX releases_release_id_unique ON releases_staging (release_id)") {
+		t.Error("unique index should be created with the final name")
+	}
+
+	swap, cleanup := o.swapCommands()
+	rename := swap[len(swap)-1]
+	if !strings.HasPrefix(rename, "RENAME TABLE artists TO artists_old, artists_staging TO artists, ") {
+		t.Errorf("all tables should be renamed by one command, got %s", rename)
+	}
+
+	if len(cleanup) != len(allTables) || cleanup[0] != "DROP TABLE artists_old" {
+		t.Errorf("old tables should be dropped, got %v", cleanup)
+	}
+}
+
+func TestOptions_SwapCommands_SQLite(t *testing.T) {
+	o := Options{Dialect: SQLite, Mode: Upsert, Staging: Staging{Suffix: "_staging"}}
+
+	if len(o.stagingIndexCommands()) != 0 {
+		t.Error("SQLite indexes should be built within the swap transaction")
+	}
+
+	expected := []string{
+		"DROP INDEX masters_staging_master_id_unique",
+		"CREATE UNIQUE INDEX masters_master_id_unique ON masters (master_id)",
+		"CREATE INDEX masters_master_id ON masters (master_id)",
+		"CREATE INDEX masters_data_quality ON masters (data_quality)",
+	}
+
+	got := o.swapIndexCommands(mastersTable)
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected index commands %v", got)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"fmt"
+	"strings"
+)
+
+// table describes one of the tables created by the tables.sql script in the sql_scripts folder.
+type table struct {
+	name    string
+	key     string // unique column of main entity tables, empty for child tables
+	columns []string
+	types   []string // SQL types of columns, see the tables.sql script in the sql_scripts folder
+	indexes []string // indexed columns, see the indexes.sql script in the sql_scripts folder
+}
+
+// child binds a child table to the main entity by the column holding the entity ID.
+type child struct {
+	table  table
+	column string
+}
+
+var (
+	artistsTable = table{
+		name:    "artists",
+		key:     "artist_id",
+		columns: []string{"artist_id", "name", "real_name", "profile", "data_quality", "name_variations", "urls"},
+		types: []string{"VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(1024)", "TEXT", "VARCHAR(20)", "VARCHAR(1024)[]",
+			"VARCHAR(1024)[]"},
+		indexes: []string{"artist_id", "name", "real_name", "data_quality"},
+	}
+	artistAliasesTable = table{
+		name:    "artist_aliases",
+		columns: []string{"artist_id", "alias_id", "name"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)"},
+		indexes: []string{"artist_id", "alias_id"},
+	}
+	artistMembersTable = table{
+		name:    "artist_members",
+		columns: []string{"artist_id", "member_id", "name"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)"},
+		indexes: []string{"artist_id", "member_id"},
+	}
+	imagesTable = table{
+		name:    "images",
+		columns: []string{"artist_id", "label_id", "master_id", "release_id", "height", "width", "type", "uri", "uri_150"},
+		types: []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)",
+			"VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(1024)"},
+		indexes: []string{"artist_id", "label_id", "master_id", "release_id"},
+	}
+	labelsTable = table{
+		name:    "labels",
+		key:     "label_id",
+		columns: []string{"label_id", "name", "contact_info", "profile", "data_quality", "urls"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(1024)", "TEXT", "TEXT", "VARCHAR(20)", "VARCHAR(1024)[]"},
+		indexes: []string{"label_id", "name", "data_quality"},
+	}
+	labelLabelsTable = table{
+		name:    "label_labels",
+		columns: []string{"label_id", "sub_label_id", "name", "parent"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(5)"},
+		indexes: []string{"label_id", "sub_label_id", "name"},
+	}
+	mastersTable = table{
+		name:    "masters",
+		key:     "master_id",
+		columns: []string{"master_id", "main_release", "genres", "styles", "year", "title", "data_quality"},
+		types: []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)[]", "VARCHAR(1024)[]", "VARCHAR(4)",
+			"VARCHAR(1024)", "VARCHAR(20)"},
+		indexes: []string{"master_id", "data_quality"},
+	}
+	videosTable = table{
+		name:    "videos",
+		columns: []string{"master_id", "release_id", "duration", "embed", "src", "title", "description"},
+		types: []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)", "VARCHAR(5)", "VARCHAR(1024)", "VARCHAR(1024)",
+			"TEXT"},
+		indexes: []string{"master_id", "release_id", "title"},
+	}
+	releasesTable = table{
+		name: "releases",
+		key:  "release_id",
+		columns: []string{"release_id", "status", "title", "genres", "styles", "country", "released", "notes",
+			"data_quality", "master_id", "main_release"},
+		types: []string{"VARCHAR(10)", "VARCHAR(20)", "VARCHAR(100)", "VARCHAR(1024)[]", "VARCHAR(1024)[]",
+			"VARCHAR(50)", "VARCHAR(10)", "TEXT", "VARCHAR(20)", "VARCHAR(10)", "VARCHAR(10)"},
+		indexes: []string{"release_id", "status", "title", "country", "released", "master_id"},
+	}
+	releaseArtistsTable = table{
+		name:    "release_artists",
+		columns: []string{"master_id", "release_id", "release_artist_id", "name", "extra", "joiner", "anv", "role", "tracks"},
+		types: []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(5)", "TEXT", "TEXT",
+			"TEXT", "TEXT"},
+		indexes: []string{"master_id", "release_id", "name"},
+	}
+	releaseLabelsTable = table{
+		name:    "release_labels",
+		columns: []string{"release_id", "release_label_id", "name", "category"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(100)"},
+		indexes: []string{"release_id", "release_label_id", "name", "category"},
+	}
+	releaseIdentifiersTable = table{
+		name:    "release_identifiers",
+		columns: []string{"release_id", "description", "type", "value"},
+		types:   []string{"VARCHAR(10)", "TEXT", "TEXT", "TEXT"},
+		indexes: []string{"release_id"},
+	}
+	releaseFormatsTable = table{
+		name:    "release_formats",
+		columns: []string{"release_id", "name", "quantity", "text", "descriptions"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(10)", "TEXT", "TEXT[]"},
+		indexes: []string{"release_id", "name"},
+	}
+	releaseCompaniesTable = table{
+		name: "release_companies",
+		columns: []string{"release_id", "release_company_id", "name", "category", "entity_type", "entity_type_name",
+			"resource_url"},
+		types: []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(1024)", "VARCHAR(100)", "VARCHAR(1024)", "VARCHAR(1024)",
+			"VARCHAR(1024)"},
+		indexes: []string{"release_id", "release_company_id", "name", "category"},
+	}
+	releaseTracksTable = table{
+		name:    "release_tracks",
+		columns: []string{"release_id", "position", "title", "duration"},
+		types:   []string{"VARCHAR(10)", "VARCHAR(10)", "VARCHAR(100)", "VARCHAR(10)"},
+		indexes: []string{"release_id"},
+	}
+)
+
+// allTables lists all tables in the order of the tables.sql script.
+var allTables = []table{artistsTable, artistAliasesTable, artistMembersTable, imagesTable, labelsTable, labelLabelsTable,
+	mastersTable, videosTable, releasesTable, releaseArtistsTable, releaseLabelsTable, releaseIdentifiersTable,
+	releaseFormatsTable, releaseCompaniesTable, releaseTracksTable}
+
+var (
+	artistChildren = []child{
+		{table: artistAliasesTable, column: "artist_id"},
+		{table: artistMembersTable, column: "artist_id"},
+		{table: imagesTable, column: "artist_id"},
+	}
+	labelChildren = []child{
+		{table: labelLabelsTable, column: "label_id"},
+		{table: imagesTable, column: "label_id"},
+	}
+	masterChildren = []child{
+		{table: releaseArtistsTable, column: "master_id"},
+		{table: imagesTable, column: "master_id"},
+		{table: videosTable, column: "master_id"},
+	}
+	releaseChildren = []child{
+		{table: imagesTable, column: "release_id"},
+		{table: releaseArtistsTable, column: "release_id"},
+		{table: releaseFormatsTable, column: "release_id"},
+		{table: releaseTracksTable, column: "release_id"},
+		{table: releaseIdentifiersTable, column: "release_id"},
+		{table: releaseLabelsTable, column: "release_id"},
+		{table: releaseCompaniesTable, column: "release_id"},
+		{table: videosTable, column: "release_id"},
+	}
+)
+
+// conflictClause returns the upsert suffix of the insert command into the main entity table t. In the Insert mode
+// or for tables without the unique key the result is empty.
+func (o Options) conflictClause(t table) string {
+	if o.Mode != Upsert || t.key == "" {
+		return ""
+	}
+
+	key := o.column(t.key)
+	sets := make([]string, 0, len(t.columns))
+	for _, c := range o.columns(t) {
+		if c == key {
+			continue
+		}
+
+		if o.Dialect == MySQL {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", c, c))
+		} else {
+			sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", c, c))
+		}
+	}
+
+	if o.Dialect == MySQL {
+		return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(sets, ", "))
+}
+
+// insertCommand returns the insert command of rows into the table t, more rows result in a multi-row insert. Commands
+// inserting into main entity tables end with the conflict clause in the Upsert mode, rows with the same key are
+// inserted only once then, since one command can't update the same row twice.
+func (o Options) insertCommand(t table, rows []row) string {
+	if o.conflictClause(t) != "" {
+		rows = uniqueRows(rows)
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("INSERT INTO %s (%s) VALUES ", o.tableName(t), strings.Join(o.columns(t), ", ")))
+	for i, r := range rows {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+
+		sb.WriteString("(")
+		for j, v := range r.values {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+
+			sb.WriteString(o.sqlValue(v))
+		}
+		sb.WriteString(")")
+	}
+
+	sb.WriteString(o.conflictClause(t))
+	return sb.String()
+}
+
+// uniqueRows returns rows without duplicate keys, the last row with the key is kept at the position of the first one.
+func uniqueRows(rows []row) []row {
+	index := make(map[string]int, len(rows))
+	unique := make([]row, 0, len(rows))
+	for _, r := range rows {
+		i, ok := index[r.keyValue()]
+		if ok {
+			unique[i] = r
+			continue
+		}
+
+		index[r.keyValue()] = len(unique)
+		unique = append(unique, r)
+	}
+
+	return unique
+}
+
+// deleteCommands returns delete commands removing all child rows of the entity with the ID. Commands are created only
+// in the Upsert mode, child tables of excluded fields (images, videos, ...) are kept untouched, and so are extra
+// artists in the release_artists table when they are excluded.
+func (o Options) deleteCommands(children []child, id string) []string {
+	if o.Mode != Upsert {
+		return nil
+	}
+
+	cmds := make([]string, 0, len(children))
+	for _, c := range children {
+		if o.excludedTable(c.table) {
+			continue
+		}
+
+		cmd := fmt.Sprintf("DELETE FROM %s WHERE %s = '%s'", o.tableName(c.table), o.column(c.column), cleanText(id))
+		if c.table.name == releaseArtistsTable.name && o.excludes(ExtraArtists) {
+			cmd += fmt.Sprintf(" AND %s = 'false'", o.column("extra"))
+		}
+
+		cmds = append(cmds, cmd)
+	}
+
+	return cmds
+}
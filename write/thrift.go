@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Types of the Thrift compact protocol used by Parquet metadata.
+const (
+	thriftTypeI32    byte = 5
+	thriftTypeI64    byte = 6
+	thriftTypeBinary byte = 8
+	thriftTypeList   byte = 9
+	thriftTypeStruct byte = 12
+)
+
+// thriftStruct is a Thrift structure, fields have to be ordered by their IDs. Values of fields are int32, int64,
+// string, []byte, thriftStruct or thriftList.
+type thriftStruct []thriftField
+
+type thriftField struct {
+	id    int16
+	value interface{}
+}
+
+// thriftList is a Thrift list of values of the same type.
+type thriftList struct {
+	elem   byte
+	values []interface{}
+}
+
+// encodeThrift encodes the structure by the Thrift compact protocol.
+func encodeThrift(s thriftStruct) []byte {
+	b := &bytes.Buffer{}
+	writeThriftStruct(b, s)
+
+	return b.Bytes()
+}
+
+func writeThriftStruct(b *bytes.Buffer, s thriftStruct) {
+	var last int16
+	for _, f := range s {
+		typ := thriftTypeOf(f.value)
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			b.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			b.WriteByte(typ)
+			writeUvarint(b, zigzag(int64(f.id)))
+		}
+
+		writeThriftValue(b, f.value)
+		last = f.id
+	}
+
+	b.WriteByte(0)
+}
+
+func writeThriftValue(b *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case int32:
+		writeUvarint(b, zigzag(int64(val)))
+	case int64:
+		writeUvarint(b, zigzag(val))
+	case string:
+		writeUvarint(b, uint64(len(val)))
+		b.WriteString(val)
+	case []byte:
+		writeUvarint(b, uint64(len(val)))
+		b.Write(val)
+	case thriftStruct:
+		writeThriftStruct(b, val)
+	case thriftList:
+		if len(val.values) < 15 {
+			b.WriteByte(byte(len(val.values))<<4 | val.elem)
+		} else {
+			b.WriteByte(0xf0 | val.elem)
+			writeUvarint(b, uint64(len(val.values)))
+		}
+
+		for _, e := range val.values {
+			writeThriftValue(b, e)
+		}
+	}
+}
+
+func thriftTypeOf(v interface{}) byte {
+	switch v.(type) {
+	case int32:
+		return thriftTypeI32
+	case int64:
+		return thriftTypeI64
+	case thriftStruct:
+		return thriftTypeStruct
+	case thriftList:
+		return thriftTypeList
+	default:
+		return thriftTypeBinary
+	}
+}
+
+func writeUvarint(b *bytes.Buffer, x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	b.Write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+func zigzag(x int64) uint64 {
+	return uint64((x << 1) ^ (x >> 63))
+}
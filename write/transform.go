@@ -0,0 +1,232 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"github.com/lukasaron/data-discogs/model"
+	"regexp"
+)
+
+// TransformFunc changes or drops a record before it reaches the wrapped writer. The record is a pointer to a copy of
+// model.Artist, model.Label, model.Master or model.Release, which can be changed in place. Slices of the record are
+// shared with the caller, thus they should be replaced rather than changed element by element. When the function
+// returns false the record is dropped.
+type TransformFunc func(record interface{}) bool
+
+type transformWriter struct {
+	w   Writer
+	fns []TransformFunc
+}
+
+// Transform creates a writer middleware, which applies transform functions in the given order on each record before
+// it's passed to the wrapped writer. Options and the writer lifecycle (Opener, Flusher, Finisher and io.Closer) are
+// taken from the wrapped writer.
+func Transform(w Writer, fns ...TransformFunc) Writer {
+	return &transformWriter{
+		w:   w,
+		fns: fns,
+	}
+}
+
+// Options returns options of the wrapped writer.
+func (t *transformWriter) Options() Options {
+	return t.w.Options()
+}
+
+// WriteArtist transforms an artist and writes it to the wrapped writer.
+func (t *transformWriter) WriteArtist(artist model.Artist) error {
+	if !t.apply(&artist) {
+		return nil
+	}
+
+	return t.w.WriteArtist(artist)
+}
+
+// WriteArtists transforms a slice of artists and writes the rest of them to the wrapped writer.
+func (t *transformWriter) WriteArtists(artists []model.Artist) error {
+	as := make([]model.Artist, 0, len(artists))
+	for _, a := range artists {
+		if t.apply(&a) {
+			as = append(as, a)
+		}
+	}
+
+	return t.w.WriteArtists(as)
+}
+
+// WriteLabel transforms a label and writes it to the wrapped writer.
+func (t *transformWriter) WriteLabel(label model.Label) error {
+	if !t.apply(&label) {
+		return nil
+	}
+
+	return t.w.WriteLabel(label)
+}
+
+// WriteLabels transforms a slice of labels and writes the rest of them to the wrapped writer.
+func (t *transformWriter) WriteLabels(labels []model.Label) error {
+	ls := make([]model.Label, 0, len(labels))
+	for _, l := range labels {
+		if t.apply(&l) {
+			ls = append(ls, l)
+		}
+	}
+
+	return t.w.WriteLabels(ls)
+}
+
+// WriteMaster transforms a master and writes it to the wrapped writer.
+func (t *transformWriter) WriteMaster(master model.Master) error {
+	if !t.apply(&master) {
+		return nil
+	}
+
+	return t.w.WriteMaster(master)
+}
+
+// WriteMasters transforms a slice of masters and writes the rest of them to the wrapped writer.
+func (t *transformWriter) WriteMasters(masters []model.Master) error {
+	ms := make([]model.Master, 0, len(masters))
+	for _, m := range masters {
+		if t.apply(&m) {
+			ms = append(ms, m)
+		}
+	}
+
+	return t.w.WriteMasters(ms)
+}
+
+// WriteRelease transforms a release and writes it to the wrapped writer.
+func (t *transformWriter) WriteRelease(release model.Release) error {
+	if !t.apply(&release) {
+		return nil
+	}
+
+	return t.w.WriteRelease(release)
+}
+
+// WriteReleases transforms a slice of releases and writes the rest of them to the wrapped writer.
+func (t *transformWriter) WriteReleases(releases []model.Release) error {
+	rs := make([]model.Release, 0, len(releases))
+	for _, r := range releases {
+		if t.apply(&r) {
+			rs = append(rs, r)
+		}
+	}
+
+	return t.w.WriteReleases(rs)
+}
+
+// Open opens the wrapped writer.
+func (t *transformWriter) Open() error {
+	return Open(t.w)
+}
+
+// Flush flushes the wrapped writer.
+func (t *transformWriter) Flush() error {
+	return Flush(t.w)
+}
+
+// Finish finishes the wrapped writer.
+func (t *transformWriter) Finish(err error) error {
+	return Finish(t.w, err)
+}
+
+// Close closes the wrapped writer.
+func (t *transformWriter) Close() error {
+	return Close(t.w)
+}
+
+func (t *transformWriter) apply(record interface{}) bool {
+	for _, fn := range t.fns {
+		if !fn(record) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ----------------------------------------------- TRANSFORMS -----------------------------------------------
+
+// DropImages removes images of all entities.
+func DropImages(record interface{}) bool {
+	switch r := record.(type) {
+	case *model.Artist:
+		r.Images = nil
+	case *model.Label:
+		r.Images = nil
+	case *model.Master:
+		r.Images = nil
+	case *model.Release:
+		r.Images = nil
+	}
+
+	return true
+}
+
+// DropVideos removes videos of masters and releases.
+func DropVideos(record interface{}) bool {
+	switch r := record.(type) {
+	case *model.Master:
+		r.Videos = nil
+	case *model.Release:
+		r.Videos = nil
+	}
+
+	return true
+}
+
+// TruncateNotes creates a transform function that shortens release notes to the maximal number of characters.
+func TruncateNotes(max int) TransformFunc {
+	return func(record interface{}) bool {
+		if r, ok := record.(*model.Release); ok {
+			if notes := []rune(r.Notes); len(notes) > max {
+				r.Notes = string(notes[:max])
+			}
+		}
+
+		return true
+	}
+}
+
+// RedactContactInfo removes contact information of labels.
+func RedactContactInfo(record interface{}) bool {
+	if l, ok := record.(*model.Label); ok {
+		l.ContactInfo = ""
+	}
+
+	return true
+}
+
+var (
+	// named references such as [a=Carl Craig] or [l=Planet E] are replaced by the name
+	markupName = regexp.MustCompile(`\[[al]=([^\]]*)\]`)
+	// ID references such as [a12345], [r=12345] or [m12345] and images are removed
+	markupReference = regexp.MustCompile(`\[(?:[almr]=?\d+|img=[^\]]*)\]`)
+	// formatting tags such as [b], [/i] or [url=...] are removed, text between tags is kept
+	markupTag = regexp.MustCompile(`\[/?(?:b|i|u|s|url(?:=[^\]]*)?)\]`)
+)
+
+// StripMarkup removes Discogs markup from artist and label profiles and release notes, for instance the text
+// [a=Carl Craig]'s [b]classic[/b] label is transformed into Carl Craig's classic label.
+func StripMarkup(record interface{}) bool {
+	switch r := record.(type) {
+	case *model.Artist:
+		r.Profile = stripMarkup(r.Profile)
+	case *model.Label:
+		r.Profile = stripMarkup(r.Profile)
+	case *model.Release:
+		r.Notes = stripMarkup(r.Notes)
+	}
+
+	return true
+}
+
+func stripMarkup(str string) string {
+	str = markupName.ReplaceAllString(str, "$1")
+	str = markupReference.ReplaceAllString(str, "")
+	return markupTag.ReplaceAllString(str, "")
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/json"
+	"github.com/lukasaron/data-discogs/model"
+	"strings"
+	"testing"
+)
+
+func TestTransform_Options(t *testing.T) {
+	w := Transform(NewJSONWriter(nil, &Options{ExcludeImages: true}))
+	if !w.Options().ExcludeImages {
+		t.Error("options of the wrapped writer should be returned")
+	}
+}
+
+func TestTransform_WriteLabels(t *testing.T) {
+	b := &strings.Builder{}
+	w := Transform(NewJSONWriter(b, nil), DropImages, RedactContactInfo, StripMarkup)
+
+	err := w.WriteLabels(labels)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var ls []model.Label
+	err = json.Unmarshal([]byte(b.String()), &ls)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(ls) != 1 {
+		t.Fatalf("1 label expected, got %d", len(ls))
+	}
+
+	if ls[0].Images != nil || ls[0].ContactInfo != "" {
+		t.Error("images and contact info should be removed")
+	}
+
+	if ls[0].Profile != expectedTransformProfile {
+		t.Errorf("unexpected profile %q", ls[0].Profile)
+	}
+
+	if labels[0].Images == nil || labels[0].ContactInfo == "" {
+		t.Error("records of the caller should not be changed")
+	}
+}
+
+func TestTransform_Drop(t *testing.T) {
+	b := &strings.Builder{}
+	w := Transform(NewJSONWriter(b, &Options{JSON: JSON{Lines: true}}), DropVideos, func(record interface{}) bool {
+		r, ok := record.(*model.Release)
+		return !ok || r.ID != releases[0].ID
+	})
+
+	_ = w.WriteMaster(masters[0])
+	_ = w.WriteRelease(releases[0])
+	err := w.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("only the master expected, got %d lines", len(lines))
+	}
+
+	var m model.Master
+	_ = json.Unmarshal([]byte(lines[0]), &m)
+	if m.ID != masters[0].ID || m.Videos != nil {
+		t.Errorf("master without videos expected, got %v", m)
+	}
+}
+
+func TestTruncateNotes(t *testing.T) {
+	r := model.Release{Notes: "Žluťoučký kůň"}
+	TruncateNotes(4)(&r)
+	if r.Notes != "Žluť" {
+		t.Errorf("notes should be truncated by characters, got %q", r.Notes)
+	}
+
+	TruncateNotes(10)(&r)
+	if r.Notes != "Žluť" {
+		t.Errorf("short notes should stay the same, got %q", r.Notes)
+	}
+}
+
+func TestStripMarkup(t *testing.T) {
+	for in, expected := range markupTests {
+		if out := stripMarkup(in); out != expected {
+			t.Errorf("%q expected, got %q", expected, out)
+		}
+	}
+}
+
+// ---------------------------------------------------- DATA ----------------------------------------------------
+
+const expectedTransformProfile = "Carl Craig's classic techno label founded in 1991."
+
+var markupTests = map[string]string{
+	"[a=Carl Craig]'s [b]classic[/b] label":       "Carl Craig's classic label",
+	"Released on [l=Planet E] [l12345]":           "Released on Planet E ",
+	"See [r=123456] and [m12] or [a99]":           "See  and  or ",
+	"[url=https://discogs.com]Discogs[/url] [i]x": "Discogs x",
+	"[img=abc.jpg]plain [u]text[/u]":              "plain text",
+}
@@ -7,6 +7,7 @@ package write
 
 import (
 	"github.com/lukasaron/data-discogs/model"
+	"io"
 )
 
 // Writer interface specify all necessary methods for writing Disocgs data that could be useful
@@ -23,9 +24,166 @@ type Writer interface {
 	Options() Options
 }
 
-// Options related to writing settings. At this stage only one option is available - Exclude images.
-// This specific option is in connection to the Discogs dump data and their politics to provide data without images.
+// Opener is an optional interface implemented by writers that need to prepare the output before the first block
+// of the run is written, such as writing a header or starting a global JSON array.
+type Opener interface {
+	Open() error
+}
+
+// Flusher is an optional interface implemented by writers that are able to flush their output. Flush is called after
+// each written block.
+type Flusher interface {
+	Flush() error
+}
+
+// Finisher is an optional interface implemented by writers that need to complete the output after the last block
+// of the run, such as closing a global JSON array or creating indexes. Finish is called even when the run fails,
+// in that case the run error is passed as the parameter, otherwise the parameter is nil.
+type Finisher interface {
+	Finish(err error) error
+}
+
+// Skipper is an optional interface implemented by writers that count blocks from the beginning of the input. Skip is
+// called before the run is opened with the number of blocks skipped by the decoder (see the decoder Block options).
+type Skipper interface {
+	Skip(blocks int)
+}
+
+// Skip passes the number of skipped blocks to the writer when it implements the Skipper interface, otherwise it does
+// nothing.
+func Skip(w Writer, blocks int) {
+	if s, ok := w.(Skipper); ok {
+		s.Skip(blocks)
+	}
+}
+
+// Open opens the writer when it implements the Opener interface, otherwise it does nothing.
+func Open(w Writer) error {
+	if o, ok := w.(Opener); ok {
+		return o.Open()
+	}
+
+	return nil
+}
+
+// Flush flushes the writer when it implements the Flusher interface, otherwise it does nothing.
+func Flush(w Writer) error {
+	if f, ok := w.(Flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// Finish finishes the writer when it implements the Finisher interface, otherwise it does nothing.
+func Finish(w Writer, err error) error {
+	if f, ok := w.(Finisher); ok {
+		return f.Finish(err)
+	}
+
+	return nil
+}
+
+// Close closes the writer when it implements the io.Closer interface, otherwise it does nothing. Writers keeping
+// their outputs open across runs, such as CSV, Parquet and graph writers, complete them by Close after the last run.
+func Close(w Writer) error {
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// flushOutput flushes the output when it implements the Flusher interface, such as bufio.Writer or gzip.Writer.
+func flushOutput(output io.Writer) error {
+	if f, ok := output.(Flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// Mode determines how the SQL based writers store entities into tables.
+type Mode int
+
+// Insert mode creates plain insert commands, which is the default behaviour. Upsert mode makes the import idempotent,
+// the main entity row is inserted or updated when it already exists and all child rows of the touched entity
+// (tracks, images, aliases, etc.) are deleted and inserted again. Upsert mode requires unique indexes on the main
+// entity tables, for that purpose please run the SQL script named unique_indexes.sql in the sql_scripts folder.
+const (
+	Insert Mode = iota
+	Upsert
+)
+
+// Dialect specifies the SQL database flavour the SQL based writers generate commands for.
+type Dialect int
+
+// Dialect constants affect the syntax of upsert commands. PostgreSQL and SQLite use INSERT ... ON CONFLICT DO UPDATE,
+// MySQL uses INSERT ... ON DUPLICATE KEY UPDATE. PostgreSQL is the default value.
+const (
+	PostgreSQL Dialect = iota
+	SQLite
+	MySQL
+)
+
+// Options related to writing settings.
+//
+// Exclude images is in connection to the Discogs dump data and their politics to provide data without images.
 // However, provided data dumps still contains XML tags with property values which are mostly empty.
+//
+// Exclude is a set of fields (see Fields constants) left out of the output, such as Images | Videos. Excluded
+// sub-collections are omitted from JSON records and their child table rows are not written. ExcludeImages is kept
+// for backward compatibility and it's the same as excluding Images.
+//
+// Mode and Dialect are used by SQL and DB writers only and define whether the output is inserted or upserted.
+//
+// Names are used by SQL based writers and define the schema, the prefix and the mapping of table and column names.
+//
+// Batch is used by SQL and DB writers only and defines the size of transactions and multi-row insert commands.
+//
+// Retry is used by DB writers and defines how transactions failed due to transient errors (such as deadlocks,
+// serialization failures or dropped connections) are retried. The failed transaction is rolled back and retried
+// in full. Elasticsearch writers use it for failed bulk requests as well.
+//
+// Documents are used by DB writers only and switch them into the document mode, which stores each entity as a single
+// JSON document instead of rows of relational tables.
+//
+// Staging is used by DB writer only and enables loading into staging tables, which are swapped in place of live
+// tables at the end.
+//
+// CopyFrom is used by DB writer only. When the hook is set all rows are loaded by the COPY command in the Insert mode.
+//
+// Rotation is used by the rotating writer only and defines when the output is split into a new chunk.
+//
+// Elastic is used by Elasticsearch writers only and defines index names, the index template and the HTTP client.
+//
+// LinkedData is used by the linked data writer only and defines the format and the base URI of IRIs.
+//
+// Redis is used by Redis writers only and defines key patterns of entity hashes and artist release sets.
+//
+// CSV, JSON and Parquet options are used by CSV, JSON and Parquet writers respectively.
+//
+// PreLoad and PostLoad are SQL commands used by SQL based writers at the start and at the end of the run (see Opener
+// and Finisher interfaces). SQL and COPY writers write them into the output as a header and a footer, the DB writer
+// executes them. PostLoad commands, such as creating indexes, are skipped when the run fails.
 type Options struct {
 	ExcludeImages bool
+	Exclude       Fields
+	Mode          Mode
+	Dialect       Dialect
+	Names         Names
+	Batch         Batch
+	Retry         RetryPolicy
+	Staging       Staging
+	Documents     Documents
+	CopyFrom      CopyFromFunc
+	CSV           CSV
+	Rotation      Rotation
+	Elastic       Elastic
+	LinkedData    LinkedData
+	Redis         Redis
+	JSON          JSON
+	Parquet       Parquet
+	PreLoad       []string
+	PostLoad      []string
 }
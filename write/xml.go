@@ -0,0 +1,469 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"bytes"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strings"
+)
+
+var (
+	xmlTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#13;")
+	xmlAttrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;", "\r", "&#13;",
+		"\n", "&#10;", "\t", "&#9;")
+)
+
+// XMLWriter is one of few provided writers that implements the Writer interface and provides the ability to save
+// decoded data back into the Discogs dump XML layout, such as filtered subsets of dumps or test fixtures. The output
+// can be decoded by the XMLDecoder again.
+type XMLWriter struct {
+	o      Options
+	w      io.Writer
+	b      bytes.Buffer
+	stream bool
+	root   string
+	err    error
+}
+
+// NewXMLWriter creates a new Writer instance based on the provided output writer (for instance a file).
+// Options with ExcludeImages or Exclude fields can be set when we don't want images (or other sub-collections) as
+// part of the final solution.
+//
+// By default each written slice results in one XML document with the root element of the entity kind, such as
+// <releases>, and a single written entity results in its element only. When the writer is opened (see Opener
+// interface), all records written until the writer is finished are part of one document, the same as the Discogs
+// dump is, thus all records of the run should be of one entity kind. Each record is written on its own line.
+func NewXMLWriter(output io.Writer, options *Options) Writer {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	return &XMLWriter{
+		b: bytes.Buffer{},
+		o: *options,
+		w: output,
+	}
+}
+
+// Options function returns the current options. It could be useful to get the default options.
+func (x XMLWriter) Options() Options {
+	return x.o
+}
+
+// WriteArtist function writes an artist to the XML output.
+func (x *XMLWriter) WriteArtist(artist model.Artist) error {
+	x.writeInitial("artists", true)
+	x.writeArtist(x.o.excludeArtist(artist))
+	x.flush()
+
+	return x.err
+}
+
+// WriteArtists function writes a slice of artists to the XML output.
+func (x *XMLWriter) WriteArtists(artists []model.Artist) error {
+	x.writeInitial("artists", false)
+	for _, a := range artists {
+		x.writeArtist(x.o.excludeArtist(a))
+	}
+
+	x.writeClosing("artists")
+	x.flush()
+
+	return x.err
+}
+
+// WriteLabel function writes a label to the XML output.
+func (x *XMLWriter) WriteLabel(label model.Label) error {
+	x.writeInitial("labels", true)
+	x.writeLabel(x.o.excludeLabel(label))
+	x.flush()
+
+	return x.err
+}
+
+// WriteLabels function writes a slice of labels to the XML output.
+func (x *XMLWriter) WriteLabels(labels []model.Label) error {
+	x.writeInitial("labels", false)
+	for _, l := range labels {
+		x.writeLabel(x.o.excludeLabel(l))
+	}
+
+	x.writeClosing("labels")
+	x.flush()
+
+	return x.err
+}
+
+// WriteMaster function writes a master to the XML output.
+func (x *XMLWriter) WriteMaster(master model.Master) error {
+	x.writeInitial("masters", true)
+	x.writeMaster(x.o.excludeMaster(master))
+	x.flush()
+
+	return x.err
+}
+
+// WriteMasters function writes a slice of masters to the XML output.
+func (x *XMLWriter) WriteMasters(masters []model.Master) error {
+	x.writeInitial("masters", false)
+	for _, m := range masters {
+		x.writeMaster(x.o.excludeMaster(m))
+	}
+
+	x.writeClosing("masters")
+	x.flush()
+
+	return x.err
+}
+
+// WriteRelease function writes a release to the XML output.
+func (x *XMLWriter) WriteRelease(release model.Release) error {
+	x.writeInitial("releases", true)
+	x.writeRelease(x.o.excludeRelease(release))
+	x.flush()
+
+	return x.err
+}
+
+// WriteReleases function writes a slice of releases to the XML output.
+func (x *XMLWriter) WriteReleases(releases []model.Release) error {
+	x.writeInitial("releases", false)
+	for _, r := range releases {
+		x.writeRelease(x.o.excludeRelease(r))
+	}
+
+	x.writeClosing("releases")
+	x.flush()
+
+	return x.err
+}
+
+// Open starts the document, its root element is written together with the first record.
+func (x *XMLWriter) Open() error {
+	x.stream = true
+	x.root = ""
+
+	return x.err
+}
+
+// Flush flushes the output when it implements the Flusher interface.
+func (x *XMLWriter) Flush() error {
+	if x.err != nil {
+		return x.err
+	}
+
+	return flushOutput(x.w)
+}
+
+// Finish closes the root element of the document started by the Open function. The root element is closed even when
+// the run fails to keep the output valid.
+func (x *XMLWriter) Finish(error) error {
+	if !x.stream {
+		return nil
+	}
+
+	x.stream = false
+	if x.root != "" {
+		x.b.WriteString("</" + x.root + ">\n")
+		x.flush()
+	}
+
+	return x.err
+}
+
+// ----------------------------------------------- UNPUBLISHED FUNCTIONS -----------------------------------------------
+
+// writeInitial writes the root element when the document is started, which is the first record of the stream or
+// the written slice.
+func (x *XMLWriter) writeInitial(root string, single bool) {
+	if x.stream {
+		if x.root == "" {
+			x.root = root
+			x.b.WriteString("<" + root + ">\n")
+		}
+
+		return
+	}
+
+	if !single {
+		x.b.WriteString("<" + root + ">\n")
+	}
+}
+
+func (x *XMLWriter) writeClosing(root string) {
+	if !x.stream {
+		x.b.WriteString("</" + root + ">\n")
+	}
+}
+
+func (x *XMLWriter) flush() {
+	if x.err == nil {
+		_, x.err = x.w.Write(x.b.Bytes())
+	}
+
+	x.b.Reset()
+}
+
+// start writes the start tag with attributes given by pairs of names and values.
+func (x *XMLWriter) start(name string, attrs ...string) {
+	x.b.WriteString("<" + name)
+	x.attributes(attrs)
+	x.b.WriteString(">")
+}
+
+func (x *XMLWriter) end(name string) {
+	x.b.WriteString("</" + name + ">")
+}
+
+// empty writes the empty element with attributes given by pairs of names and values.
+func (x *XMLWriter) empty(name string, attrs ...string) {
+	x.b.WriteString("<" + name)
+	x.attributes(attrs)
+	x.b.WriteString("/>")
+}
+
+func (x *XMLWriter) attributes(attrs []string) {
+	for i := 0; i+1 < len(attrs); i += 2 {
+		x.b.WriteString(" " + attrs[i] + "=\"" + xmlAttrReplacer.Replace(attrs[i+1]) + "\"")
+	}
+}
+
+// element writes the element with the text value.
+func (x *XMLWriter) element(name, value string, attrs ...string) {
+	x.start(name, attrs...)
+	x.b.WriteString(xmlTextReplacer.Replace(value))
+	x.end(name)
+}
+
+// optional writes the element with the text value, unless the value is empty.
+func (x *XMLWriter) optional(name, value string) {
+	if value != "" {
+		x.element(name, value)
+	}
+}
+
+// values writes the wrapper element with child elements of values, unless there are no values.
+func (x *XMLWriter) values(wrapper, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+
+	x.start(wrapper)
+	for _, v := range values {
+		x.element(name, v)
+	}
+	x.end(wrapper)
+}
+
+// ----------------------------------------------- ARTIST -----------------------------------------------
+
+func (x *XMLWriter) writeArtist(a model.Artist) {
+	x.start("artist")
+	x.writeImages(a.Images)
+	x.element("id", a.ID)
+	x.element("name", a.Name)
+	x.optional("realname", a.RealName)
+	x.element("profile", a.Profile)
+	x.element("data_quality", a.DataQuality)
+	x.values("urls", "url", a.Urls)
+	x.values("namevariations", "name", a.NameVariations)
+
+	if len(a.Aliases) > 0 {
+		x.start("aliases")
+		for _, al := range a.Aliases {
+			x.element("name", al.Name, "id", al.ID)
+		}
+		x.end("aliases")
+	}
+
+	if len(a.Members) > 0 {
+		x.start("members")
+		for _, m := range a.Members {
+			x.element("id", m.ID)
+			x.element("name", m.Name, "id", m.ID)
+		}
+		x.end("members")
+	}
+
+	x.end("artist")
+	x.b.WriteString("\n")
+}
+
+// ----------------------------------------------- LABEL -----------------------------------------------
+
+func (x *XMLWriter) writeLabel(l model.Label) {
+	x.start("label")
+	x.writeImages(l.Images)
+	x.element("id", l.ID)
+	x.element("name", l.Name)
+	x.optional("contactinfo", l.ContactInfo)
+	x.optional("profile", l.Profile)
+	x.element("data_quality", l.DataQuality)
+	x.values("urls", "url", l.Urls)
+
+	if l.ParentLabel != nil {
+		x.element("parentLabel", l.ParentLabel.Name, "id", l.ParentLabel.ID)
+	}
+
+	if len(l.SubLabels) > 0 {
+		x.start("sublabels")
+		for _, sl := range l.SubLabels {
+			x.element("label", sl.Name, "id", sl.ID)
+		}
+		x.end("sublabels")
+	}
+
+	x.end("label")
+	x.b.WriteString("\n")
+}
+
+// ----------------------------------------------- MASTER -----------------------------------------------
+
+func (x *XMLWriter) writeMaster(m model.Master) {
+	x.start("master", "id", m.ID)
+	x.element("main_release", m.MainRelease)
+	x.writeImages(m.Images)
+	x.writeReleaseArtists("artists", m.Artists)
+	x.values("genres", "genre", m.Genres)
+	x.values("styles", "style", m.Styles)
+	x.optional("year", m.Year)
+	x.element("title", m.Title)
+	x.element("data_quality", m.DataQuality)
+	x.writeVideos(m.Videos)
+	x.end("master")
+	x.b.WriteString("\n")
+}
+
+// ----------------------------------------------- RELEASE -----------------------------------------------
+
+func (x *XMLWriter) writeRelease(r model.Release) {
+	x.start("release", "id", r.ID, "status", r.Status)
+	x.writeImages(r.Images)
+	x.writeReleaseArtists("artists", r.Artists)
+	x.element("title", r.Title)
+
+	if len(r.Labels) > 0 {
+		x.start("labels")
+		for _, l := range r.Labels {
+			x.empty("label", "catno", l.Category, "id", l.ID, "name", l.Name)
+		}
+		x.end("labels")
+	}
+
+	x.writeReleaseArtists("extraartists", r.ExtraArtists)
+
+	if len(r.Formats) > 0 {
+		x.start("formats")
+		for _, f := range r.Formats {
+			x.start("format", "name", f.Name, "qty", f.Quantity, "text", f.Text)
+			x.values("descriptions", "description", f.Descriptions)
+			x.end("format")
+		}
+		x.end("formats")
+	}
+
+	x.values("genres", "genre", r.Genres)
+	x.values("styles", "style", r.Styles)
+	x.optional("country", r.Country)
+	x.optional("released", r.Released)
+	x.optional("notes", r.Notes)
+	x.element("data_quality", r.DataQuality)
+
+	if r.MasterID != "" {
+		x.element("master_id", r.MasterID, "is_main_release", r.MainRelease)
+	}
+
+	if len(r.TrackList) > 0 {
+		x.start("tracklist")
+		for _, t := range r.TrackList {
+			x.start("track")
+			x.element("position", t.Position)
+			x.element("title", t.Title)
+			x.element("duration", t.Duration)
+			x.end("track")
+		}
+		x.end("tracklist")
+	}
+
+	if len(r.Identifiers) > 0 {
+		x.start("identifiers")
+		for _, i := range r.Identifiers {
+			x.empty("identifier", "description", i.Description, "type", i.Type, "value", i.Value)
+		}
+		x.end("identifiers")
+	}
+
+	x.writeVideos(r.Videos)
+
+	if len(r.Companies) > 0 {
+		x.start("companies")
+		for _, c := range r.Companies {
+			x.start("company")
+			x.element("id", c.ID)
+			x.element("name", c.Name)
+			x.element("catno", c.Category)
+			x.element("entity_type", c.EntityType)
+			x.element("entity_type_name", c.EntityTypeName)
+			x.element("resource_url", c.ResourceURL)
+			x.end("company")
+		}
+		x.end("companies")
+	}
+
+	x.end("release")
+	x.b.WriteString("\n")
+}
+
+func (x *XMLWriter) writeReleaseArtists(wrapper string, artists []model.ReleaseArtist) {
+	if len(artists) == 0 {
+		return
+	}
+
+	x.start(wrapper)
+	for _, a := range artists {
+		x.start("artist")
+		x.element("id", a.ID)
+		x.element("name", a.Name)
+		x.element("anv", a.Anv)
+		x.element("join", a.Join)
+		x.element("role", a.Role)
+		x.element("tracks", a.Tracks)
+		x.end("artist")
+	}
+	x.end(wrapper)
+}
+
+// ----------------------------------------------- SHARED -----------------------------------------------
+
+func (x *XMLWriter) writeImages(images []model.Image) {
+	if len(images) == 0 {
+		return
+	}
+
+	x.start("images")
+	for _, img := range images {
+		x.empty("image", "height", img.Height, "type", img.Type, "uri", img.URI, "uri150", img.URI150, "width",
+			img.Width)
+	}
+	x.end("images")
+}
+
+func (x *XMLWriter) writeVideos(videos []model.Video) {
+	if len(videos) == 0 {
+		return
+	}
+
+	x.start("videos")
+	for _, v := range videos {
+		x.start("video", "duration", v.Duration, "embed", v.Embed, "src", v.Src)
+		x.element("title", v.Title)
+		x.element("description", v.Description)
+		x.end("video")
+	}
+	x.end("videos")
+}
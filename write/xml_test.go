@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Lukas Aron. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package write
+
+import (
+	"encoding/xml"
+	"github.com/lukasaron/data-discogs/model"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXMLWriter_Options(t *testing.T) {
+	x := NewXMLWriter(nil, nil)
+	opt := x.Options()
+
+	if opt.ExcludeImages {
+		t.Error("exclude images should be false as a default value")
+	}
+}
+
+func TestXMLWriter_WriteArtist(t *testing.T) {
+	b := &strings.Builder{}
+	x := NewXMLWriter(b, nil)
+	err := x.WriteArtist(artists[0])
+	if err != nil {
+		t.Error(err)
+	}
+
+	get := b.String()
+	if !strings.HasPrefix(get, "<artist><id>2</id><name>Mr. James Barth &amp; A.D.</name>") ||
+		!strings.HasSuffix(get, "</artist>\n") {
+		t.Errorf("unexpected artist element %s", get)
+	}
+
+	if !strings.Contains(get, `<aliases><name id="2470">Puente Latino</name>`) ||
+		!strings.Contains(get, `<members><id>26</id><name id="26">Alexi Delano</name>`) {
+		t.Errorf("aliases and members should have id attributes %s", get)
+	}
+
+	checkXML(t, get)
+}
+
+func TestXMLWriter_WriteReleases(t *testing.T) {
+	b := &strings.Builder{}
+	x := NewXMLWriter(b, nil)
+	err := x.WriteReleases(releases)
+	if err != nil {
+		t.Error(err)
+	}
+
+	get := b.String()
+	if !strings.HasPrefix(get, "<releases>\n<release id=\""+releases[0].ID+"\" status=\"Accepted\">") ||
+		!strings.HasSuffix(get, "</release>\n</releases>\n") {
+		t.Errorf("unexpected releases document %s", get)
+	}
+
+	if !strings.Contains(get, `<master_id is_main_release="true">713738</master_id>`) {
+		t.Errorf("master id should have is_main_release attribute %s", get)
+	}
+
+	checkXML(t, get)
+}
+
+func TestXMLWriter_WriteMasters(t *testing.T) {
+	b := &strings.Builder{}
+	x := NewXMLWriter(b, &Options{ExcludeImages: true})
+	err := x.WriteMasters(masters)
+	if err != nil {
+		t.Error(err)
+	}
+
+	get := b.String()
+	if !strings.HasPrefix(get, "<masters>\n<master id=\"18512\"><main_release>33699</main_release>") {
+		t.Errorf("unexpected masters document %s", get)
+	}
+
+	if strings.Contains(get, "<images>") {
+		t.Error("images should be excluded")
+	}
+
+	checkXML(t, get)
+}
+
+func TestXMLWriter_Stream(t *testing.T) {
+	b := &strings.Builder{}
+	x := NewXMLWriter(b, nil)
+
+	_ = Open(x)
+	_ = x.WriteLabels(labels)
+	_ = x.WriteLabel(labels[0])
+	err := Finish(x, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	get := b.String()
+	if strings.Count(get, "<labels>") != 1 || strings.Count(get, "</labels>") != 1 ||
+		strings.Count(get, "<label>") != len(labels)+1 {
+		t.Errorf("all labels should be written in one document %s", get)
+	}
+
+	checkXML(t, get)
+}
+
+func TestXMLWriter_Escape(t *testing.T) {
+	b := &strings.Builder{}
+	x := NewXMLWriter(b, nil)
+	err := x.WriteRelease(model.Release{
+		ID:    "1",
+		Title: "<A & B>\r\n",
+		Formats: []model.Format{
+			{Name: "Vinyl", Quantity: "1", Text: "12\" \"Special\"\n"},
+		},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	get := b.String()
+	if !strings.Contains(get, "<title>&lt;A &amp; B&gt;&#13;\n</title>") ||
+		!strings.Contains(get, `text="12&quot; &quot;Special&quot;&#10;"`) {
+		t.Errorf("special characters should be escaped %s", get)
+	}
+
+	checkXML(t, get)
+}
+
+// checkXML reports an error when the document is not well-formed.
+func checkXML(t *testing.T, doc string) {
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			t.Errorf("document should be well-formed: %v", err)
+			return
+		}
+	}
+}
@@ -10,19 +10,11 @@ import (
 	"github.com/lukasaron/data-discogs/model"
 	"github.com/lukasaron/data-discogs/write"
 	"io"
-	"log"
 	"strings"
 )
 
-const (
-	defaultBlockSize  = 10
-	defaultBlockLimit = int(^uint(0) >> 1)
-)
-
 var (
 	// Errors returned when failure occurs
-	errReaderIsNull          = errors.New("reader is null")
-	errWrongTypeSpecified    = errors.New("wrong file type specified")
 	errNotCorrectStarElement = errors.New("token is not a correct start element")
 )
 
@@ -63,19 +55,7 @@ func (x *XMLDecoder) Options() Options {
 
 // SetOptions sets new options
 func (x *XMLDecoder) SetOptions(opt Options) {
-	x.o = opt
-
-	if x.o.Block.Limit < 1 {
-		x.o.Block.Limit = defaultBlockLimit
-	}
-
-	if x.o.Block.ItemSize < 1 {
-		x.o.Block.ItemSize = defaultBlockSize
-	}
-
-	if x.o.Block.Skip < 0 {
-		x.o.Block.Skip = 0
-	}
+	x.o = defaultOptions(opt)
 }
 
 // Decode function parses data and saves the result into the writer. The type of data is already defined by Option passed during creating
@@ -89,42 +69,16 @@ func (x *XMLDecoder) SetOptions(opt Options) {
 // And the last block option that can be set is Skip that expresses how many blocks from the beginning will be omitted.
 //
 // Results of this function are logged with success or failure message indicating the block number for future running.
+//
+// The writer lifecycle is driven by this function as well. When the writer implements the write.Opener interface it's
+// opened before the first block, write.Flusher is flushed after each written block and write.Finisher is finished
+// after the last block, even when the decoding fails.
 func (x *XMLDecoder) Decode(w write.Writer) error {
 	if x.err != nil {
 		return x.err
 	}
 
-	var df func(write.Writer, bool) (int, error)
-	var num int
-
-	// get decode function based on the file type
-	df, x.err = x.decodeFunction()
-	if x.err != nil {
-		return x.err
-	}
-
-	for blockCount := 1; blockCount <= x.o.Block.Limit; blockCount++ {
-		// call appropriate decoder function
-		num, x.err = df(w, blockCount > x.o.Block.Skip)
-		// error occurs (not the end of stream)
-		if x.err != nil && x.err != io.EOF {
-			log.Printf("Block %d failed [%d]\n", blockCount, num)
-			return x.err
-		}
-
-		// no data anymore, end of stream
-		if num == 0 && x.err == io.EOF {
-			break
-		}
-
-		// we have data and no error (except end of stream)
-		if blockCount > x.o.Block.Skip {
-			log.Printf("Block %d written [%d]\n", blockCount, num)
-		} else {
-			log.Printf("Block %d skipped [%d]\n", blockCount, num)
-		}
-	}
-
+	x.err = decode(x, w)
 	return x.err
 }
 
@@ -139,7 +93,7 @@ func (x *XMLDecoder) Artists() (int, []model.Artist, error) {
 
 	artists := x.parseArtists()
 	if x.err == nil || x.err == io.EOF {
-		artists = x.filterArtists(artists)
+		artists = x.o.QualityLevel.filterArtists(artists)
 	}
 	return len(artists), artists, x.err
 }
@@ -155,7 +109,7 @@ func (x *XMLDecoder) Labels() (int, []model.Label, error) {
 
 	labels := x.parseLabels()
 	if x.err == nil || x.err == io.EOF {
-		labels = x.filterLabels(labels)
+		labels = x.o.QualityLevel.filterLabels(labels)
 	}
 	return len(labels), labels, x.err
 }
@@ -171,7 +125,7 @@ func (x *XMLDecoder) Masters() (int, []model.Master, error) {
 
 	masters := x.parseMasters()
 	if x.err == nil || x.err == io.EOF {
-		masters = x.filterMasters(masters)
+		masters = x.o.QualityLevel.filterMasters(masters)
 	}
 
 	return len(masters), masters, x.err
@@ -188,128 +142,11 @@ func (x *XMLDecoder) Releases() (int, []model.Release, error) {
 
 	releases := x.parseReleases()
 	if x.err == nil || x.err == io.EOF {
-		releases = x.filterReleases(releases)
+		releases = x.o.QualityLevel.filterReleases(releases)
 	}
 	return len(releases), releases, x.err
 }
 
-//--------------------------------------------------- FILTERS ---------------------------------------------------
-
-func (x *XMLDecoder) filterArtists(as []model.Artist) []model.Artist {
-	fa := make([]model.Artist, 0, len(as))
-	for _, a := range as {
-		if x.o.QualityLevel.Includes(ToQualityLevel(a.DataQuality)) {
-			fa = append(fa, a)
-		}
-	}
-
-	return fa
-}
-
-func (x *XMLDecoder) filterLabels(ls []model.Label) []model.Label {
-	fl := make([]model.Label, 0, len(ls))
-	for _, l := range ls {
-		if x.o.QualityLevel.Includes(ToQualityLevel(l.DataQuality)) {
-			fl = append(fl, l)
-		}
-	}
-
-	return fl
-}
-
-func (x *XMLDecoder) filterMasters(ms []model.Master) []model.Master {
-	fm := make([]model.Master, 0, len(ms))
-	for _, m := range ms {
-		if x.o.QualityLevel.Includes(ToQualityLevel(m.DataQuality)) {
-			fm = append(fm, m)
-		}
-	}
-
-	return fm
-}
-
-func (x *XMLDecoder) filterReleases(rs []model.Release) []model.Release {
-	fr := make([]model.Release, 0, len(rs))
-	for _, r := range rs {
-		if x.o.QualityLevel.Includes(ToQualityLevel(r.DataQuality)) {
-			fr = append(fr, r)
-		}
-	}
-
-	return fr
-}
-
-//--------------------------------------------------- Decoders ---------------------------------------------------
-
-func (x *XMLDecoder) decodeFunction() (func(write.Writer, bool) (int, error), error) {
-	switch x.o.FileType {
-	case Artists:
-		return x.decodeArtists, nil
-	case Labels:
-		return x.decodeLabels, nil
-	case Masters:
-		return x.decodeMasters, nil
-	case Releases:
-		return x.decodeReleases, nil
-	case Unknown:
-		fallthrough
-	default:
-		return nil, errWrongTypeSpecified
-	}
-}
-
-func (x *XMLDecoder) decodeArtists(w write.Writer, write bool) (int, error) {
-	num, a, err := x.Artists()
-	if (err != nil && err != io.EOF) || num == 0 {
-		return num, err
-	}
-
-	if write {
-		return num, w.WriteArtists(a)
-	}
-
-	return num, err
-}
-
-func (x *XMLDecoder) decodeLabels(w write.Writer, write bool) (int, error) {
-	num, l, err := x.Labels()
-	if (err != nil && err != io.EOF) || num == 0 {
-		return num, err
-	}
-
-	if write {
-		return num, w.WriteLabels(l)
-	}
-
-	return num, err
-}
-
-func (x *XMLDecoder) decodeMasters(w write.Writer, write bool) (int, error) {
-	num, m, err := x.Masters()
-	if (err != nil && err != io.EOF) || num == 0 {
-		return num, err
-	}
-
-	if write {
-		return num, w.WriteMasters(m)
-	}
-
-	return num, err
-}
-
-func (x *XMLDecoder) decodeReleases(w write.Writer, write bool) (int, error) {
-	num, r, err := x.Releases()
-	if (err != nil && err != io.EOF) || num == 0 {
-		return num, err
-	}
-
-	if write {
-		return num, w.WriteReleases(r)
-	}
-
-	return num, err
-}
-
 //--------------------------------------------------- Helpers ---------------------------------------------------
 
 func (x *XMLDecoder) startElement(token xml.Token) bool {
@@ -5,8 +5,16 @@
 package discogs
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/lukasaron/data-discogs/model"
+	"github.com/lukasaron/data-discogs/write"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
 	"testing"
@@ -1178,6 +1186,224 @@ func TestXMLDecoder_Releases_Second(t *testing.T) {
 	}
 }
 
+func TestXMLDecoder_Decode_Lifecycle(t *testing.T) {
+	d := NewXMLDecoder(strings.NewReader(artists), &Options{
+		FileType: Artists,
+		Block:    Block{ItemSize: 1},
+	})
+
+	w := &lifecycleWriter{}
+	err := d.Decode(w)
+	if err != io.EOF {
+		t.Errorf("decode should end with the end of stream, got %v", err)
+	}
+
+	expected := "open,artists,flush,artists,flush,finish:<nil>"
+	if got := strings.Join(w.calls, ","); got != expected {
+		t.Errorf("writer lifecycle should be %s, got %s", expected, got)
+	}
+}
+
+func TestXMLDecoder_Decode_Lifecycle_Failure(t *testing.T) {
+	d := NewXMLDecoder(strings.NewReader(artists), &Options{
+		FileType: Artists,
+		Block:    Block{ItemSize: 1},
+	})
+
+	w := &lifecycleWriter{fail: errors.New("write failed")}
+	err := d.Decode(w)
+	if err != w.fail {
+		t.Errorf("decode should fail with the writer error, got %v", err)
+	}
+
+	expected := "open,artists,finish:write failed"
+	if got := strings.Join(w.calls, ","); got != expected {
+		t.Errorf("writer lifecycle should be %s, got %s", expected, got)
+	}
+}
+
+func TestXMLDecoder_Decode_JSONWriter(t *testing.T) {
+	d := NewXMLDecoder(strings.NewReader(artists), &Options{
+		FileType: Artists,
+		Block:    Block{ItemSize: 1},
+	})
+
+	b := &strings.Builder{}
+	_ = d.Decode(write.NewJSONWriter(b, nil))
+
+	var as []model.Artist
+	err := json.Unmarshal([]byte(b.String()), &as)
+	if err != nil {
+		t.Errorf("decoded artists should be one valid JSON array: %v", err)
+	}
+
+	if len(as) != 2 {
+		t.Errorf("2 artists expected, got %d", len(as))
+	}
+}
+
+func TestXMLDecoder_Decode_CSVWriter_Runs(t *testing.T) {
+	// outputs are replaced when the table is opened again, the same way as files created by os.Create
+	outputs := make(map[string]*strings.Builder)
+	c := write.NewCSVWriterFunc(func(table string) (io.Writer, error) {
+		outputs[table] = &strings.Builder{}
+		return outputs[table], nil
+	}, nil)
+
+	runs := []struct {
+		data string
+		ft   FileType
+	}{{artists, Artists}, {labels, Labels}}
+
+	for _, r := range runs {
+		err := NewXMLDecoder(strings.NewReader(r.data), &Options{FileType: r.ft}).Decode(c)
+		if err != io.EOF {
+			t.Fatalf("decode should end with the end of stream, got %v", err)
+		}
+	}
+
+	err := c.(io.Closer).Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	images := strings.Split(strings.TrimSuffix(outputs["images"].String(), "\n"), "\n")
+	if len(images) != 1+2+7 {
+		t.Fatalf("images of both runs should be kept, got %d lines:\n%s", len(images), outputs["images"])
+	}
+
+	if !strings.HasPrefix(images[1], "1,,,,") || !strings.HasPrefix(images[len(images)-1], ",1,,,") {
+		t.Errorf("artist images should be followed by label images:\n%s", outputs["images"])
+	}
+}
+
+func TestXMLDecoder_Decode_ConcurrentDBWriter_Resume(t *testing.T) {
+	_, db := newFakeDB()
+	defer db.Close()
+
+	w := write.NewConcurrentDBWriter(db, 2, nil)
+	c := w.(*write.ConcurrentDBWriter)
+
+	// the first run is interrupted by the limit, following runs resume from the last checkpoint
+	limits := []int{1, 0, 0}
+	expected := []int{1, 2, 2}
+	for i, limit := range limits {
+		o := &Options{FileType: Artists, Block: Block{ItemSize: 1, Limit: limit, Skip: c.Committed()}}
+		_ = NewXMLDecoder(strings.NewReader(artists), o).Decode(w)
+
+		if got := c.Committed(); got != expected[i] {
+			t.Errorf("run %d: %d committed blocks from the beginning expected, got %d", i+1, expected[i], got)
+		}
+	}
+}
+
+func TestXMLDecoder_Decode_ElasticWriter_Refused(t *testing.T) {
+	// artist 1 is refused, other documents are indexed
+	var bulks []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bulks = append(bulks, string(b))
+		if strings.Contains(string(b), `"_id":"1"`) {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"_index":"artists","_id":"1","status":400,` +
+				`"error":{"type":"mapper_parsing_exception","reason":"refused by the test"}}}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer s.Close()
+
+	o := &Options{FileType: Artists, Block: Block{ItemSize: 1}}
+	err := NewXMLDecoder(strings.NewReader(artists), o).Decode(write.NewElasticWriter(s.URL, nil))
+
+	var be *write.BulkError
+	if !errors.As(err, &be) || len(bulks) != 1 {
+		t.Errorf("the run should stop by the bulk error after 1 block, got %v after %d blocks", err, len(bulks))
+	}
+
+	bulks = nil
+	var refused []*write.BulkError
+	wo := &write.Options{Elastic: write.Elastic{OnRefused: func(err *write.BulkError) { refused = append(refused, err) }}}
+	err = NewXMLDecoder(strings.NewReader(artists), o).Decode(write.NewElasticWriter(s.URL, wo))
+	if err != io.EOF {
+		t.Errorf("there should be EOF error instead of %v", err)
+	}
+
+	if len(bulks) < 2 || len(refused) != 1 || refused[0].Items[0].ID != "1" {
+		t.Errorf("all blocks should be sent and the refused artist reported, got %d blocks and %v", len(bulks),
+			refused)
+	}
+}
+
+func TestXMLDecoder_RoundTrip(t *testing.T) {
+	samples := map[FileType]string{
+		Artists:  "data_samples/artists.xml",
+		Labels:   "data_samples/labels.xml",
+		Masters:  "data_samples/masters.xml",
+		Releases: "data_samples/releases.xml",
+	}
+
+	for ft, file := range samples {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xml := &bytes.Buffer{}
+		expected := &bytes.Buffer{}
+		err = NewXMLDecoder(bytes.NewReader(data), &Options{FileType: ft}).
+			Decode(write.MultiWriter(write.NewXMLWriter(xml, nil), write.NewJSONWriter(expected, nil)))
+		if err != io.EOF {
+			t.Fatalf("%s: there should be EOF error instead of %v", file, err)
+		}
+
+		got := &bytes.Buffer{}
+		err = NewXMLDecoder(bytes.NewReader(xml.Bytes()), &Options{FileType: ft}).
+			Decode(write.NewJSONWriter(got, nil))
+		if err != io.EOF {
+			t.Fatalf("%s: there should be EOF error instead of %v", file, err)
+		}
+
+		if got.String() != expected.String() {
+			t.Errorf("%s: written XML should be decoded the same way\n%s", file, xml)
+		}
+	}
+}
+
+// lifecycleWriter records calls of the writer lifecycle.
+type lifecycleWriter struct {
+	calls []string
+	fail  error
+}
+
+func (l *lifecycleWriter) Open() error {
+	l.calls = append(l.calls, "open")
+	return nil
+}
+
+func (l *lifecycleWriter) Flush() error {
+	l.calls = append(l.calls, "flush")
+	return nil
+}
+
+func (l *lifecycleWriter) Finish(err error) error {
+	l.calls = append(l.calls, fmt.Sprintf("finish:%v", err))
+	return nil
+}
+
+func (l *lifecycleWriter) WriteArtist(model.Artist) error { return l.fail }
+func (l *lifecycleWriter) WriteArtists([]model.Artist) error {
+	l.calls = append(l.calls, "artists")
+	return l.fail
+}
+func (l *lifecycleWriter) WriteLabel(model.Label) error        { return l.fail }
+func (l *lifecycleWriter) WriteLabels([]model.Label) error     { return l.fail }
+func (l *lifecycleWriter) WriteMaster(model.Master) error      { return l.fail }
+func (l *lifecycleWriter) WriteMasters([]model.Master) error   { return l.fail }
+func (l *lifecycleWriter) WriteRelease(model.Release) error    { return l.fail }
+func (l *lifecycleWriter) WriteReleases([]model.Release) error { return l.fail }
+func (l *lifecycleWriter) Options() write.Options              { return write.Options{} }
+
 // ------------------------------------------------------- DATA -------------------------------------------------------
 
 var artists = `